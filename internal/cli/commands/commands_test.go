@@ -2,6 +2,7 @@ package commands
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/pirakansa/vorbere/internal/cli/manifest"
 	"github.com/pirakansa/vorbere/internal/cli/shared"
 )
 
@@ -43,6 +45,9 @@ func TestInitCommandCreatesFilesAndFailsOnSecondRun(t *testing.T) {
 	if _, err := os.Stat(filepath.Join(temp, "vorbere.yaml")); err != nil {
 		t.Fatalf("vorbere.yaml missing: %v", err)
 	}
+	if _, err := os.Stat(filepath.Join(temp, ".vorbere", "base", "README.md")); err != nil {
+		t.Fatalf("merge base directory missing: %v", err)
+	}
 
 	cmd = newInitCmd()
 	cmd.SetArgs(nil)
@@ -115,6 +120,67 @@ tasks:
 	}
 }
 
+func TestTaskArgsTemplatesOnlyAffectTheirTask(t *testing.T) {
+	temp := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+	configPath := filepath.Join(temp, "vorbere.yaml")
+	cfg := `version: 1
+tasks:
+  greet:
+    run: echo "hello ${{ index .args 0 }}" > greet.txt
+  other:
+    run: echo other > other.txt
+    depends_on: [prepare]
+  prepare:
+    run: echo "${{ len .args }}" > prepare.txt
+repositories:
+  - url: ` + server.URL + `
+    files:
+      - file_name: a.txt
+        out_dir: .
+`
+	if err := os.WriteFile(configPath, []byte(cfg), 0o644); err != nil {
+		t.Fatalf("write vorbere.yaml failed: %v", err)
+	}
+	ctx := &appContext{configPath: configPath, out: &bytes.Buffer{}}
+
+	cmd := newTasksListCmd(ctx)
+	cmd.SetArgs(nil)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("tasks list failed: %v", err)
+	}
+	if err := runSyncWithOptions(ctx, syncCommandOptions{overwrite: true}); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	cmd = newRunCmd(ctx)
+	cmd.SetArgs([]string{"other", "--", "x"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("run other failed: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(temp, "prepare.txt")); string(got) != "0\n" {
+		t.Fatalf("expected .args to be bound only for the selected task, got %q", got)
+	}
+
+	cmd = newRunCmd(ctx)
+	cmd.SetArgs([]string{"greet", "--", "world"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("run greet failed: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(temp, "greet.txt")); string(got) != "hello world\n" {
+		t.Fatalf("unexpected greet output %q", got)
+	}
+
+	cmd = newRunCmd(ctx)
+	cmd.SetArgs([]string{"greet"})
+	var exitErr *exitCodeError
+	if err := cmd.Execute(); !errors.As(err, &exitErr) || exitErr.code != shared.ExitConfigError {
+		t.Fatalf("expected a config error for greet without args, got %v", err)
+	}
+}
+
 func TestRunCommandReturnsConfigErrorWhenTaskConfigMissing(t *testing.T) {
 	ctx := &appContext{configPath: filepath.Join(t.TempDir(), "missing-vorbere.yaml")}
 
@@ -157,7 +223,7 @@ func TestLoadTaskAndRootUsesCWDForRemoteConfig(t *testing.T) {
 		}),
 	}
 
-	taskCfg, rootDir, err := loadTaskAndRoot("https://example.com/vorbere.yaml")
+	taskCfg, rootDir, err := loadTaskAndRoot("https://example.com/vorbere.yaml", manifest.LoadOptions{})
 	if err != nil {
 		t.Fatalf("loadTaskAndRoot returned error: %v", err)
 	}
@@ -169,6 +235,94 @@ func TestLoadTaskAndRootUsesCWDForRemoteConfig(t *testing.T) {
 	}
 }
 
+func TestRunCommandDiscoversConfigFromSubdirectory(t *testing.T) {
+	temp := t.TempDir()
+	t.Setenv("HOME", temp)
+	cfg := `version: 1
+tasks:
+  where:
+    run: "pwd > root.txt"
+  here:
+    cwd: "{{.USER_WORKING_DIR}}"
+    run: "pwd > here.txt"
+`
+	if err := os.WriteFile(filepath.Join(temp, "vorbere.yml"), []byte(cfg), 0o644); err != nil {
+		t.Fatalf("write vorbere.yml failed: %v", err)
+	}
+	sub := filepath.Join(temp, "a", "b")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	oldwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd failed: %v", err)
+	}
+	defer func() { _ = os.Chdir(oldwd) }()
+	if err := os.Chdir(sub); err != nil {
+		t.Fatalf("Chdir failed: %v", err)
+	}
+
+	for _, task := range []string{"where", "here"} {
+		cmd := newRunCmd(&appContext{})
+		cmd.SetArgs([]string{task})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("run %s failed: %v", task, err)
+		}
+	}
+	if got, _ := os.ReadFile(filepath.Join(temp, "root.txt")); strings.TrimSpace(string(got)) != temp {
+		t.Fatalf("expected task to run in config dir, got %q", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(sub, "here.txt")); strings.TrimSpace(string(got)) != sub {
+		t.Fatalf("expected task to run in user working dir, got %q", got)
+	}
+
+	if err := os.Remove(filepath.Join(temp, "vorbere.yml")); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	cmd := newRunCmd(&appContext{})
+	cmd.SetArgs([]string{"where"})
+	var exitErr *exitCodeError
+	if err := cmd.Execute(); !errors.As(err, &exitErr) || exitErr.code != shared.ExitConfigError {
+		t.Fatalf("expected ExitConfigError without config, err=%v", err)
+	}
+}
+
+func TestRunCommandAppliesVarOverrides(t *testing.T) {
+	temp := t.TempDir()
+	configPath := filepath.Join(temp, "vorbere.yaml")
+	cfg := `version: 1
+vars:
+  GREETING: hello
+  TARGET:
+    env: VORBERE_TEST_TARGET
+    default: world
+tasks:
+  greet:
+    run: "echo ${{ .vars.GREETING }} ${{ .vars.TARGET }} > out.txt"
+`
+	if err := os.WriteFile(configPath, []byte(cfg), 0o644); err != nil {
+		t.Fatalf("write vorbere.yaml failed: %v", err)
+	}
+	t.Setenv("VORBERE_VAR_GREETING", "hi")
+	t.Setenv("VORBERE_TEST_TARGET", "ci")
+
+	cmd := NewRootCmd("dev")
+	cmd.SetArgs([]string{"--config", configPath, "--var", "TARGET=flag", "run", "greet"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("run greet failed: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(temp, "out.txt")); string(got) != "hi flag\n" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+
+	cmd = NewRootCmd("dev")
+	cmd.SetArgs([]string{"--config", configPath, "--var", "TARGET", "run", "greet"})
+	var exitErr *exitCodeError
+	if err := cmd.Execute(); !errors.As(err, &exitErr) || exitErr.code != shared.ExitConfigError {
+		t.Fatalf("expected ExitConfigError for malformed --var, err=%v", err)
+	}
+}
+
 type commandsTestRoundTripFunc func(*http.Request) (*http.Response, error)
 
 func (f commandsTestRoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -201,6 +355,536 @@ repositories:
 	}
 }
 
+func TestSyncCommandReturnsConfigErrorForMissingChecksumEntry(t *testing.T) {
+	temp := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/SHA256SUMS" {
+			_, _ = w.Write([]byte(shared.SHA256Hex([]byte("other")) + "  other.txt\n"))
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	taskBody := `version: 1
+repositories:
+  - url: ` + server.URL + `
+    files:
+      - file_name: a.txt
+        download_digest_from: SHA256SUMS
+        out_dir: .
+`
+	taskPath := filepath.Join(temp, "vorbere.yaml")
+	if err := os.WriteFile(taskPath, []byte(taskBody), 0o644); err != nil {
+		t.Fatalf("write task config: %v", err)
+	}
+
+	err := runSyncWithOptions(&appContext{configPath: taskPath}, syncCommandOptions{})
+	var exitErr *exitCodeError
+	if !errors.As(err, &exitErr) || exitErr.code != shared.ExitConfigError {
+		t.Fatalf("expected ExitConfigError, err=%v", err)
+	}
+}
+
+func TestSyncCommandSyncsOnlySelectedFiles(t *testing.T) {
+	temp := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	taskBody := `version: 1
+repositories:
+  - url: ` + server.URL + `
+    tags: [docs]
+    files:
+      - file_name: a.txt
+        out_dir: .
+      - file_name: b.txt
+        out_dir: .
+        tags: [slow]
+  - url: ` + server.URL + `
+    files:
+      - file_name: c.txt
+        out_dir: .
+`
+	taskPath := filepath.Join(temp, "vorbere.yaml")
+	if err := os.WriteFile(taskPath, []byte(taskBody), 0o644); err != nil {
+		t.Fatalf("write task config: %v", err)
+	}
+
+	ctx := &appContext{configPath: taskPath}
+	if err := runSyncWithOptions(ctx, syncCommandOptions{only: []string{"docs"}, skip: []string{"slow"}}); err != nil {
+		t.Fatalf("expected filtered sync success, err=%v", err)
+	}
+	for name, want := range map[string]bool{"a.txt": true, "b.txt": false, "c.txt": false} {
+		_, err := os.Stat(filepath.Join(temp, name))
+		if got := err == nil; got != want {
+			t.Fatalf("expected %s written=%v, got %v", name, want, got)
+		}
+	}
+
+	err := runSyncWithOptions(ctx, syncCommandOptions{only: []string{"unknown"}})
+	var exitErr *exitCodeError
+	if !errors.As(err, &exitErr) || exitErr.code != shared.ExitConfigError {
+		t.Fatalf("expected ExitConfigError for unmatched filter, err=%v", err)
+	}
+}
+
+func TestSyncCommandEmitsJSONEvents(t *testing.T) {
+	temp := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	taskBody := `version: 1
+repositories:
+  - url: ` + server.URL + `
+    files:
+      - file_name: a.txt
+        out_dir: .
+`
+	taskPath := filepath.Join(temp, "vorbere.yaml")
+	if err := os.WriteFile(taskPath, []byte(taskBody), 0o644); err != nil {
+		t.Fatalf("write task config: %v", err)
+	}
+
+	var out bytes.Buffer
+	ctx := &appContext{configPath: taskPath, output: outputJSON, out: &out}
+	if err := runSyncWithOptions(ctx, syncCommandOptions{}); err != nil {
+		t.Fatalf("expected sync success, err=%v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected file and result events, got %q", out.String())
+	}
+	var file map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &file); err != nil {
+		t.Fatalf("decode file event: %v", err)
+	}
+	if file["event"] != eventSyncFile || file["outcome"] != "created" || file["output_digest"] != "sha256:"+shared.SHA256Hex([]byte("content")) || file["output_bytes"] != float64(7) {
+		t.Fatalf("unexpected file event: %v", file)
+	}
+	var result struct {
+		Event   string                      `json:"event"`
+		Created int                         `json:"created"`
+		Files   []manifest.SyncFileProgress `json:"files"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &result); err != nil {
+		t.Fatalf("decode result event: %v", err)
+	}
+	if result.Event != eventSyncResult || result.Created != 1 || len(result.Files) != 1 {
+		t.Fatalf("unexpected result event: %+v", result)
+	}
+}
+
+func TestTasksListEmitsJSON(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "vorbere.yaml")
+	cfg := `version: 1
+tasks:
+  build:
+    desc: Build binaries
+    env:
+      GOOS: linux
+      CGO_ENABLED: "0"
+  ci:
+    depends_on: [build]
+`
+	if err := os.WriteFile(configPath, []byte(cfg), 0o644); err != nil {
+		t.Fatalf("write vorbere.yaml failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	cmd := newTasksListCmd(&appContext{configPath: configPath, json: true, out: &out})
+	cmd.SetArgs(nil)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("tasks list failed: %v", err)
+	}
+	want := `[{"name":"build","desc":"Build binaries","depends_on":[],"env_keys":["CGO_ENABLED","GOOS"]},{"name":"ci","depends_on":["build"],"env_keys":[]}]` + "\n"
+	if out.String() != want {
+		t.Fatalf("unexpected tasks json:\n got: %s\nwant: %s", out.String(), want)
+	}
+}
+
+func TestValidateCommandReportsProblems(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "vorbere.yaml")
+	cfg := `version: 1
+tasks:
+  ci:
+    depends_on: [build, lint]
+  build:
+    run: go build
+`
+	if err := os.WriteFile(configPath, []byte(cfg), 0o644); err != nil {
+		t.Fatalf("write vorbere.yaml failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	cmd := newValidateCmd(&appContext{configPath: configPath, out: &out})
+	cmd.SetArgs(nil)
+	err := cmd.Execute()
+	if got := mapExitCode(err); got != shared.ExitConfigError {
+		t.Fatalf("expected exit code %d, got %d (%v)", shared.ExitConfigError, got, err)
+	}
+	want := configPath + `:4:25: tasks.ci.depends_on[1] references undefined task "lint"` + "\n"
+	if out.String() != want {
+		t.Fatalf("unexpected validate output:\n got: %s\nwant: %s", out.String(), want)
+	}
+
+	out.Reset()
+	cmd = newValidateCmd(&appContext{configPath: configPath, json: true, out: &out})
+	cmd.SetArgs(nil)
+	if err := cmd.Execute(); mapExitCode(err) != shared.ExitConfigError {
+		t.Fatalf("expected config error, got %v", err)
+	}
+	if !strings.HasPrefix(out.String(), `{"valid":false,"issues":[{"file":`) || !strings.Contains(out.String(), `"line":4,"column":25`) {
+		t.Fatalf("unexpected validate json: %s", out.String())
+	}
+}
+
+func TestValidateCommandAcceptsValidConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "vorbere.yaml")
+	if err := os.WriteFile(configPath, []byte("version: 1\ntasks:\n  build:\n    run: go build\n"), 0o644); err != nil {
+		t.Fatalf("write vorbere.yaml failed: %v", err)
+	}
+	var out bytes.Buffer
+	cmd := newValidateCmd(&appContext{configPath: configPath, json: true, out: &out})
+	cmd.SetArgs(nil)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	if out.String() != `{"valid":true,"issues":[]}`+"\n" {
+		t.Fatalf("unexpected validate json: %s", out.String())
+	}
+}
+
+func TestSchemaCommandPrintsJSONSchema(t *testing.T) {
+	var out bytes.Buffer
+	cmd := newSchemaCmd(&appContext{out: &out})
+	cmd.SetArgs(nil)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("schema failed: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(out.Bytes(), &schema); err != nil {
+		t.Fatalf("schema output is not JSON: %v", err)
+	}
+	if schema["$schema"] != "https://json-schema.org/draft/2020-12/schema" || schema["type"] != "object" {
+		t.Fatalf("unexpected schema: %v", schema)
+	}
+}
+
+func TestTasksGraphPrintsDependencies(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "vorbere.yaml")
+	cfg := `version: 1
+tasks:
+  build:
+    run: go build
+  test:
+    run: go test ./...
+    depends_on: [build]
+  ci:
+    depends_on: [test]
+`
+	if err := os.WriteFile(configPath, []byte(cfg), 0o644); err != nil {
+		t.Fatalf("write vorbere.yaml failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	cmd := newTasksGraphCmd(&appContext{configPath: configPath, out: &out})
+	cmd.SetArgs([]string{"ci", "--format", "mermaid"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("tasks graph failed: %v", err)
+	}
+	want := "flowchart TD\n  t0[\"build\"]\n  t1[\"ci\"]\n  t2[\"test\"]\n  t1 --> t2\n  t2 --> t0\n"
+	if out.String() != want {
+		t.Fatalf("unexpected graph:\n got: %s\nwant: %s", out.String(), want)
+	}
+
+	cmd = newTasksGraphCmd(&appContext{configPath: configPath, out: &out})
+	cmd.SetArgs([]string{"missing"})
+	if err := cmd.Execute(); mapExitCode(err) != shared.ExitTaskUndefined {
+		t.Fatalf("expected ExitTaskUndefined, err=%v", err)
+	}
+}
+
+func TestRunCommandEmitsJSONEvents(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "vorbere.yaml")
+	cfg := `version: 1
+tasks:
+  fail:
+    run: "exit 7"
+`
+	if err := os.WriteFile(configPath, []byte(cfg), 0o644); err != nil {
+		t.Fatalf("write vorbere.yaml failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	cmd := newRunCmd(&appContext{configPath: configPath, output: outputJSON, out: &out})
+	cmd.SetArgs([]string{"fail"})
+	err := cmd.Execute()
+	var exitErr *exitCodeError
+	if !errors.As(err, &exitErr) || exitErr.code != shared.ExitTaskFailed {
+		t.Fatalf("expected ExitTaskFailed, err=%v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || lines[0] != `{"event":"task_start","task":"fail"}` {
+		t.Fatalf("unexpected run events: %q", out.String())
+	}
+	var finished taskEvent
+	if err := json.Unmarshal([]byte(lines[1]), &finished); err != nil {
+		t.Fatalf("decode finish event: %v", err)
+	}
+	if finished.Event != eventTaskFinish || finished.ExitCode == nil || *finished.ExitCode != 7 || finished.DurationMS == nil || finished.Error == "" {
+		t.Fatalf("unexpected finish event: %+v", finished)
+	}
+}
+
+func writeWorkspaceFixture(t *testing.T) string {
+	t.Helper()
+	temp := t.TempDir()
+	files := map[string]string{
+		"vorbere.yaml":                 "version: 1\nworkspaces: [services/*]\ntasks: {}\n",
+		"services/api/vorbere.yaml":    "tasks:\n  test:\n    run: pwd > out.txt\n",
+		"services/web/vorbere.yaml":    "tasks:\n  test:\n    run: \"false\"\n",
+		"services/worker/vorbere.yaml": "tasks:\n  build:\n    run: \"true\"\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(temp, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s failed: %v", name, err)
+		}
+	}
+	return temp
+}
+
+func TestRunCommandRunsWorkspaceTaskInWorkspaceDir(t *testing.T) {
+	temp := writeWorkspaceFixture(t)
+	ctx := &appContext{configPath: filepath.Join(temp, "vorbere.yaml"), out: &bytes.Buffer{}}
+
+	cmd := newRunCmd(ctx)
+	cmd.SetArgs([]string{"api:test"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("run api:test failed: %v", err)
+	}
+	apiDir := filepath.Join(temp, "services", "api")
+	got, err := os.ReadFile(filepath.Join(apiDir, "out.txt"))
+	if err != nil || strings.TrimSpace(string(got)) != apiDir {
+		t.Fatalf("expected task cwd %s, got %q (%v)", apiDir, got, err)
+	}
+
+	cmd = newRunCmd(ctx)
+	cmd.SetArgs([]string{"worker:test"})
+	var exitErr *exitCodeError
+	if err := cmd.Execute(); !errors.As(err, &exitErr) || exitErr.code != shared.ExitTaskUndefined {
+		t.Fatalf("expected ExitTaskUndefined, err=%v", err)
+	}
+}
+
+func TestRunCommandAllAggregatesWorkspaceResults(t *testing.T) {
+	temp := writeWorkspaceFixture(t)
+	var out bytes.Buffer
+	cmd := newRunCmd(&appContext{configPath: filepath.Join(temp, "vorbere.yaml"), out: &out})
+	cmd.SetArgs([]string{"--all", "test"})
+	err := cmd.Execute()
+	var exitErr *exitCodeError
+	if !errors.As(err, &exitErr) || exitErr.code != shared.ExitTaskFailed || !strings.Contains(err.Error(), "failed in 1 of 2 workspaces") {
+		t.Fatalf("expected ExitTaskFailed for one workspace, err=%v", err)
+	}
+	text := out.String()
+	if !containsAll(text, []string{"workspace api\n", "workspace web\n", "api\tok\t", "web\tfailed\t", "ok=1 failed=1\n"}) || strings.Contains(text, "worker") {
+		t.Fatalf("unexpected summary output: %q", text)
+	}
+
+	out.Reset()
+	cmd = newRunCmd(&appContext{configPath: filepath.Join(temp, "vorbere.yaml"), output: outputJSON, out: &out})
+	cmd.SetArgs([]string{"--all", "test"})
+	_ = cmd.Execute()
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if lines[0] != `{"event":"task_start","task":"api:test"}` {
+		t.Fatalf("expected workspace qualified task events, got %q", lines[0])
+	}
+	var summary runSummaryEvent
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &summary); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if summary.Event != eventRunSummary || summary.OK != 1 || summary.Failed != 1 || len(summary.Workspaces) != 2 || summary.Workspaces[1].Error == "" {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestRootCommandRejectsUnknownOutputFormat(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	cmd.SetArgs([]string{"--output", "yaml", "tasks", "list"})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), `unsupported output format "yaml"`) {
+		t.Fatalf("expected unsupported output format error, got %v", err)
+	}
+}
+
+func TestSyncCommandRunsChangeHooks(t *testing.T) {
+	temp := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	taskBody := `version: 1
+tasks:
+  record:
+    run: "echo changed >> hooks.log"
+  fail:
+    run: "exit 1"
+sync:
+  after: [record]
+repositories:
+  - url: ` + server.URL + `
+    files:
+      - file_name: a.txt
+        out_dir: .
+`
+	taskPath := filepath.Join(temp, "vorbere.yaml")
+	if err := os.WriteFile(taskPath, []byte(taskBody), 0o644); err != nil {
+		t.Fatalf("write task config: %v", err)
+	}
+
+	var out bytes.Buffer
+	ctx := &appContext{configPath: taskPath, out: &out}
+	for i := 0; i < 2; i++ {
+		if err := runSyncWithOptions(ctx, syncCommandOptions{}); err != nil {
+			t.Fatalf("sync %d failed: %v", i, err)
+		}
+	}
+	log, err := os.ReadFile(filepath.Join(temp, "hooks.log"))
+	if err != nil {
+		t.Fatalf("read hooks log: %v", err)
+	}
+	if string(log) != "changed\n" {
+		t.Fatalf("expected hook to run only for the first sync, got %q", string(log))
+	}
+
+	failingBody := strings.Replace(taskBody, "after: [record]", "after: [fail]", 1)
+	if err := os.WriteFile(taskPath, []byte(failingBody), 0o644); err != nil {
+		t.Fatalf("write task config: %v", err)
+	}
+	if err := os.Remove(filepath.Join(temp, "a.txt")); err != nil {
+		t.Fatalf("remove output: %v", err)
+	}
+	err = runSyncWithOptions(ctx, syncCommandOptions{})
+	var exitErr *exitCodeError
+	if !errors.As(err, &exitErr) || exitErr.code != shared.ExitHookFailed {
+		t.Fatalf("expected ExitHookFailed, err=%v", err)
+	}
+}
+
+func TestSyncCommandReturnsConflictExitCode(t *testing.T) {
+	temp := t.TempDir()
+	upstream := "line\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(upstream))
+	}))
+	defer server.Close()
+
+	taskBody := `version: 1
+repositories:
+  - url: ` + server.URL + `
+    files:
+      - file_name: a.txt
+        out_dir: .
+        merge: three-way
+`
+	taskPath := filepath.Join(temp, "vorbere.yaml")
+	if err := os.WriteFile(taskPath, []byte(taskBody), 0o644); err != nil {
+		t.Fatalf("write task config: %v", err)
+	}
+	ctx := &appContext{configPath: taskPath, out: io.Discard}
+	if err := runSyncWithOptions(ctx, syncCommandOptions{overwrite: true}); err != nil {
+		t.Fatalf("initial sync failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(temp, "a.txt"), []byte("local\n"), 0o644); err != nil {
+		t.Fatalf("write local edit: %v", err)
+	}
+	upstream = "upstream\n"
+
+	err := runSyncWithOptions(ctx, syncCommandOptions{overwrite: true})
+	var exitErr *exitCodeError
+	if !errors.As(err, &exitErr) || exitErr.code != shared.ExitSyncConflict {
+		t.Fatalf("expected ExitSyncConflict, err=%v", err)
+	}
+}
+
+func TestDigestCommandWritesDigests(t *testing.T) {
+	temp := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	taskBody := `version: 1
+repositories:
+  # remote files
+  - url: ` + server.URL + `
+    files:
+      - file_name: a.txt
+        out_dir: .
+`
+	taskPath := filepath.Join(temp, "vorbere.yaml")
+	if err := os.WriteFile(taskPath, []byte(taskBody), 0o644); err != nil {
+		t.Fatalf("write task config: %v", err)
+	}
+
+	ctx := &appContext{configPath: taskPath, out: &bytes.Buffer{}}
+	if err := runDigestWithOptions(ctx, "a.txt", digestCommandOptions{algorithm: "sha256", write: true}); err != nil {
+		t.Fatalf("expected digest success, err=%v", err)
+	}
+	got, err := os.ReadFile(taskPath)
+	if err != nil {
+		t.Fatalf("read task config: %v", err)
+	}
+	if !strings.Contains(string(got), "# remote files") || !strings.Contains(string(got), "download_digest: sha256:"+shared.SHA256Hex([]byte("content"))) {
+		t.Fatalf("unexpected rewritten config:\n%s", string(got))
+	}
+	if err := runSyncWithOptions(ctx, syncCommandOptions{}); err != nil {
+		t.Fatalf("expected sync to verify written digest, err=%v", err)
+	}
+
+	err = runDigestWithOptions(ctx, "missing", digestCommandOptions{algorithm: "sha256"})
+	var exitErr *exitCodeError
+	if !errors.As(err, &exitErr) || exitErr.code != shared.ExitConfigError {
+		t.Fatalf("expected ExitConfigError for unmatched selector, err=%v", err)
+	}
+}
+
+func TestDigestCommandEmitsJSON(t *testing.T) {
+	temp := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	taskPath := filepath.Join(temp, "vorbere.yaml")
+	taskBody := "version: 1\nrepositories:\n  - url: " + server.URL + "\n    files:\n      - file_name: a.txt\n        out_dir: .\n"
+	if err := os.WriteFile(taskPath, []byte(taskBody), 0o644); err != nil {
+		t.Fatalf("write task config: %v", err)
+	}
+
+	var out bytes.Buffer
+	ctx := &appContext{configPath: taskPath, json: true, out: &out}
+	if err := runDigestWithOptions(ctx, "", digestCommandOptions{algorithm: "sha256", write: true}); err != nil {
+		t.Fatalf("expected digest success, err=%v", err)
+	}
+	digest := "sha256:" + shared.SHA256Hex([]byte("content"))
+	want := `{"event":"digest","field":"repositories[0].files[0]","path":"a.txt","download_digest":"` + digest + `","output_digest":"` + digest + `"}` + "\n" +
+		`{"event":"digest_write","config":"` + taskPath + `"}` + "\n"
+	if out.String() != want {
+		t.Fatalf("unexpected digest json:\n got: %s\nwant: %s", out.String(), want)
+	}
+}
+
 func TestVersionCommandPrintsVersion(t *testing.T) {
 	cmd := newVersionCmd("v0.2.0")
 	var out bytes.Buffer
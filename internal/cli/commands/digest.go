@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pirakansa/vorbere/internal/cli/manifest"
+	"github.com/pirakansa/vorbere/internal/cli/shared"
+	"github.com/spf13/cobra"
+)
+
+type digestCommandOptions struct {
+	algorithm string
+	write     bool
+}
+
+func newDigestCmd(ctx *appContext) *cobra.Command {
+	opts := &digestCommandOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "digest [selector]",
+		Short: "Compute download and output digests of repository files",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			selector := ""
+			if len(args) == 1 {
+				selector = args[0]
+			}
+			return runDigestWithOptions(ctx, selector, *opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.algorithm, "algo", manifest.DigestAlgorithmSHA256, "digest algorithm (blake3, sha256, sha384, sha512, sha1, md5)")
+	cmd.Flags().BoolVar(&opts.write, "write", false, "rewrite download_digest and output_digest in the config file")
+	return cmd
+}
+
+func runDigestWithOptions(ctx *appContext, selector string, opts digestCommandOptions) error {
+	if opts.write && manifest.IsRemoteConfigLocation(ctx.configPath) {
+		return newExitCodeError(shared.ExitConfigError, errors.New("--write requires a local config file"))
+	}
+	taskCfg, rootDir, err := ctx.loadTask()
+	if err != nil {
+		return err
+	}
+	syncCfg, err := manifest.ResolveSyncConfig(taskCfg, ctx.configPath)
+	if err != nil {
+		return newExitCodeError(shared.ExitConfigError, err)
+	}
+
+	digests, err := manifest.ComputeDigests(syncCfg, manifest.DigestOptions{
+		RootDir:   rootDir,
+		Algorithm: opts.algorithm,
+		Selector:  selector,
+	})
+	if err != nil {
+		var configErr *manifest.ConfigError
+		if errors.As(err, &configErr) {
+			return newExitCodeError(shared.ExitConfigError, err)
+		}
+		return newExitCodeError(shared.ExitSyncFailed, err)
+	}
+	if len(digests) == 0 {
+		return newExitCodeError(shared.ExitConfigError, fmt.Errorf("no repository file matches %q", selector))
+	}
+	for _, digest := range digests {
+		if ctx.jsonOutput() {
+			if err := writeJSONLine(ctx.stdout(), newDigestEvent(digest)); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintln(ctx.stdout(), formatFileDigest(digest))
+	}
+
+	if !opts.write {
+		return nil
+	}
+	configPath, err := filepath.Abs(ctx.configPath)
+	if err != nil {
+		return err
+	}
+	warnings, err := manifest.RewriteDigests(configPath, taskCfg, digests)
+	if err != nil {
+		return newExitCodeError(shared.ExitConfigError, err)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	if ctx.jsonOutput() {
+		return writeJSONLine(ctx.stdout(), digestWriteEvent{Event: eventDigestWrite, Config: ctx.configPath})
+	}
+	fmt.Fprintf(ctx.stdout(), "updated %s\n", ctx.configPath)
+	return nil
+}
+
+func formatFileDigest(digest manifest.FileDigest) string {
+	line := fmt.Sprintf("%s %s", digest.FieldPath(), digest.Path)
+	if digest.Origin != "" {
+		line = digest.Origin + ": " + line
+	}
+	if digest.Skipped != "" {
+		return line + fmt.Sprintf("\n  skipped: %s", digest.Skipped)
+	}
+	line += fmt.Sprintf("\n  download_digest: %s", digest.DownloadDigest)
+	if digest.OutputDigest != "" {
+		line += fmt.Sprintf("\n  output_digest: %s", digest.OutputDigest)
+	}
+	return line
+}
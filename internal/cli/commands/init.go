@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/pirakansa/vorbere/internal/cli/manifest"
 	"github.com/spf13/cobra"
 )
 
@@ -18,6 +19,15 @@ func newInitCmd() *cobra.Command {
 				return err
 			}
 			fmt.Println("initialized: vorbere.yaml")
+			// Merge bases must be committed, so the directory is created up
+			// front with a note explaining why.
+			readme := filepath.Join(filepath.FromSlash(manifest.MergeBaseDir), "README.md")
+			if _, err := os.Stat(readme); errors.Is(err, os.ErrNotExist) {
+				if err := writeIfNotExists(readme, mergeBaseReadme); err != nil {
+					return err
+				}
+				fmt.Printf("initialized: %s\n", filepath.ToSlash(readme))
+			}
 			return nil
 		},
 	}
@@ -38,6 +48,11 @@ func writeIfNotExists(path, content string) error {
 	return os.WriteFile(path, []byte(content), 0o644)
 }
 
+const mergeBaseReadme = `This directory holds the upstream content last synced for files with
+merge: three-way. Commit it: without these bases, vorbere sync cannot tell
+local edits from upstream changes and reports such files as conflicts.
+`
+
 func taskTemplate() string {
 	return `version: 1
 tasks:
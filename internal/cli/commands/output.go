@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pirakansa/vorbere/internal/cli/manifest"
+	"github.com/pirakansa/vorbere/internal/cli/taskrun"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+const (
+	eventSyncFile    = "sync_file"
+	eventSyncResult  = "sync_result"
+	eventTaskStart   = "task_start"
+	eventTaskFinish  = "task_finish"
+	eventRunSummary  = "run_summary"
+	eventDigest      = "digest"
+	eventDigestWrite = "digest_write"
+)
+
+func validateOutputFormat(ctx *appContext) error {
+	switch ctx.output {
+	case outputText, outputJSON:
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q (want %s or %s)", ctx.output, outputText, outputJSON)
+	}
+}
+
+func (ctx *appContext) jsonOutput() bool {
+	return ctx.json || ctx.output == outputJSON
+}
+
+func (ctx *appContext) stdout() io.Writer {
+	if ctx.out != nil {
+		return ctx.out
+	}
+	return os.Stdout
+}
+
+// writeJSONLine writes one JSON document per line so the stream can be
+// consumed incrementally.
+func writeJSONLine(w io.Writer, value any) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", body)
+	return err
+}
+
+type syncFileEvent struct {
+	Event string `json:"event"`
+	manifest.SyncFileProgress
+}
+
+type syncResultEvent struct {
+	Event string `json:"event"`
+	*manifest.SyncResult
+}
+
+type taskListEntry struct {
+	Name      string   `json:"name"`
+	Desc      string   `json:"desc,omitempty"`
+	DependsOn []string `json:"depends_on"`
+	EnvKeys   []string `json:"env_keys"`
+}
+
+type taskEvent struct {
+	Event      string `json:"event"`
+	Task       string `json:"task"`
+	DurationMS *int64 `json:"duration_ms,omitempty"`
+	ExitCode   *int   `json:"exit_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type runSummaryEvent struct {
+	Event      string            `json:"event"`
+	Task       string            `json:"task"`
+	OK         int               `json:"ok"`
+	Failed     int               `json:"failed"`
+	Workspaces []workspaceResult `json:"workspaces"`
+}
+
+type workspaceResult struct {
+	Name       string `json:"name"`
+	Dir        string `json:"dir"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+type digestEvent struct {
+	Event          string `json:"event"`
+	Field          string `json:"field"`
+	Origin         string `json:"origin,omitempty"`
+	Path           string `json:"path"`
+	DownloadDigest string `json:"download_digest,omitempty"`
+	OutputDigest   string `json:"output_digest,omitempty"`
+	Skipped        string `json:"skipped,omitempty"`
+}
+
+type digestWriteEvent struct {
+	Event  string `json:"event"`
+	Config string `json:"config"`
+}
+
+func newTaskListEntry(name string, task manifest.TaskDef) taskListEntry {
+	entry := taskListEntry{
+		Name:      name,
+		Desc:      task.Desc,
+		DependsOn: append([]string{}, task.DependsOn...),
+		EnvKeys:   make([]string, 0, len(task.Env)),
+	}
+	for key := range task.Env {
+		entry.EnvKeys = append(entry.EnvKeys, key)
+	}
+	sort.Strings(entry.EnvKeys)
+	return entry
+}
+
+func newTaskStartEvent(event taskrun.TaskEvent) taskEvent {
+	return taskEvent{Event: eventTaskStart, Task: event.Task}
+}
+
+func newTaskFinishEvent(event taskrun.TaskEvent) taskEvent {
+	durationMS := event.Duration.Round(time.Millisecond).Milliseconds()
+	exitCode := event.ExitCode
+	finished := taskEvent{Event: eventTaskFinish, Task: event.Task, DurationMS: &durationMS, ExitCode: &exitCode}
+	if event.Err != nil {
+		finished.Error = event.Err.Error()
+	}
+	return finished
+}
+
+func newDigestEvent(digest manifest.FileDigest) digestEvent {
+	return digestEvent{
+		Event:          eventDigest,
+		Field:          digest.FieldPath(),
+		Origin:         digest.Origin,
+		Path:           digest.Path,
+		DownloadDigest: digest.DownloadDigest,
+		OutputDigest:   digest.OutputDigest,
+		Skipped:        digest.Skipped,
+	}
+}
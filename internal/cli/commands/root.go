@@ -3,8 +3,10 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/pirakansa/vorbere/internal/cli/manifest"
 	"github.com/pirakansa/vorbere/internal/cli/shared"
@@ -13,6 +15,10 @@ import (
 
 type appContext struct {
 	configPath string
+	output     string
+	json       bool
+	vars       []string
+	out        io.Writer
 }
 
 func NewRootCmd(version string) *cobra.Command {
@@ -23,14 +29,23 @@ func NewRootCmd(version string) *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return cmd.Help()
 		},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateOutputFormat(ctx)
+		},
 		SilenceUsage:  true,
 		SilenceErrors: true,
 	}
-	cmd.PersistentFlags().StringVar(&ctx.configPath, "config", "vorbere.yaml", "path to task config")
+	cmd.PersistentFlags().StringVar(&ctx.configPath, "config", "", "path or URL to task config (default: vorbere.yaml found in the current directory or a parent)")
+	cmd.PersistentFlags().StringVar(&ctx.output, "output", outputText, "output format: text or json")
+	cmd.PersistentFlags().BoolVar(&ctx.json, "json", false, "shorthand for --output json")
+	cmd.PersistentFlags().StringArrayVar(&ctx.vars, "var", nil, "override a var as NAME=value (repeatable)")
 
 	cmd.AddCommand(newRunCmd(ctx))
 	cmd.AddCommand(newSyncCmd(ctx))
+	cmd.AddCommand(newDigestCmd(ctx))
 	cmd.AddCommand(newTasksCmd(ctx))
+	cmd.AddCommand(newValidateCmd(ctx))
+	cmd.AddCommand(newSchemaCmd(ctx))
 	cmd.AddCommand(newInitCmd())
 	cmd.AddCommand(newVersionCmd(version))
 
@@ -53,9 +68,56 @@ func mapExitCode(err error) int {
 	return 1
 }
 
-func loadTaskAndRoot(configPath string) (*manifest.TaskConfig, string, error) {
+// resolveConfigPath sets ctx.configPath to the discovered manifest when
+// --config was not given.
+func (ctx *appContext) resolveConfigPath() error {
+	if ctx.configPath != "" {
+		return nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	home, _ := os.UserHomeDir()
+	configPath, err := manifest.DiscoverConfig(cwd, home)
+	if err != nil {
+		return newExitCodeError(shared.ExitConfigError, err)
+	}
+	ctx.configPath = configPath
+	return nil
+}
+
+// loadTask resolves the config path and loads the manifest.
+func (ctx *appContext) loadTask() (*manifest.TaskConfig, string, error) {
+	if err := ctx.resolveConfigPath(); err != nil {
+		return nil, "", err
+	}
+	opts, err := ctx.loadOptions()
+	if err != nil {
+		return nil, "", err
+	}
+	return loadTaskAndRoot(ctx.configPath, opts)
+}
+
+// loadOptions parses the --var flags.
+func (ctx *appContext) loadOptions() (manifest.LoadOptions, error) {
+	opts := manifest.LoadOptions{}
+	for _, entry := range ctx.vars {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			return manifest.LoadOptions{}, newExitCodeError(shared.ExitConfigError, fmt.Errorf("--var %q must be NAME=value", entry))
+		}
+		if opts.Vars == nil {
+			opts.Vars = map[string]string{}
+		}
+		opts.Vars[name] = value
+	}
+	return opts, nil
+}
+
+func loadTaskAndRoot(configPath string, opts manifest.LoadOptions) (*manifest.TaskConfig, string, error) {
 	if manifest.IsRemoteConfigLocation(configPath) {
-		taskCfg, err := manifest.LoadTaskConfig(configPath)
+		taskCfg, err := manifest.LoadTaskConfigWithOptions(configPath, opts)
 		if err != nil {
 			return nil, "", newExitCodeError(shared.ExitConfigError, err)
 		}
@@ -70,7 +132,7 @@ func loadTaskAndRoot(configPath string) (*manifest.TaskConfig, string, error) {
 	if err != nil {
 		return nil, "", err
 	}
-	taskCfg, err := manifest.LoadTaskConfig(abs)
+	taskCfg, err := manifest.LoadTaskConfigWithOptions(abs, opts)
 	if err != nil {
 		return nil, "", newExitCodeError(shared.ExitConfigError, err)
 	}
@@ -2,35 +2,168 @@ package commands
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"time"
 
+	"github.com/pirakansa/vorbere/internal/cli/manifest"
 	"github.com/pirakansa/vorbere/internal/cli/shared"
 	"github.com/pirakansa/vorbere/internal/cli/taskrun"
 	"github.com/spf13/cobra"
 )
 
 func newRunCmd(ctx *appContext) *cobra.Command {
+	var all bool
 	cmd := &cobra.Command{
 		Use:   "run <task> [-- args...]",
 		Short: "Run a common task",
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			taskCfg, rootDir, err := loadTaskAndRoot(ctx.configPath)
-			if err != nil {
-				return err
-			}
 			taskName := args[0]
 			taskArgs := []string{}
 			if len(args) > 1 {
 				taskArgs = args[1:]
 			}
+			taskCfg, rootDir, err := ctx.loadTask()
+			if err != nil {
+				return err
+			}
+			if all {
+				return runAllWorkspaces(ctx, taskCfg, rootDir, taskName, taskArgs)
+			}
 			if _, ok := taskCfg.Tasks[taskName]; !ok {
+				workspaceName, workspaceTask, ok := manifest.SplitWorkspaceTask(taskName)
+				if ok && len(taskCfg.Workspaces) > 0 {
+					return runWorkspaceTask(ctx, taskCfg, rootDir, workspaceName, workspaceTask, taskArgs)
+				}
 				return newExitCodeError(shared.ExitTaskUndefined, errors.New("task is not defined"))
 			}
-			if err := taskrun.RunTask(taskCfg, taskName, rootDir, taskArgs); err != nil {
-				return newExitCodeError(shared.ExitTaskFailed, err)
+			if err := taskrun.RunTaskWithOptions(taskCfg, taskName, rootDir, taskArgs, newRunOptions(ctx, "")); err != nil {
+				return runTaskError(err)
 			}
 			return nil
 		},
 	}
+	cmd.Flags().BoolVar(&all, "all", false, "run the task in every workspace that defines it")
 	return cmd
 }
+
+// runTaskError maps a failed run to its exit code. A task whose templates
+// fail to expand is a config error rather than a failed task.
+func runTaskError(err error) error {
+	var expandErr *taskrun.ExpandError
+	if errors.As(err, &expandErr) {
+		return newExitCodeError(shared.ExitConfigError, err)
+	}
+	return newExitCodeError(shared.ExitTaskFailed, err)
+}
+
+// newRunOptions streams task start/finish events in JSON mode. Task output
+// is sent to stderr then so stdout carries only JSON lines. Task names are
+// prefixed with "<workspace>:" when workspace is set.
+func newRunOptions(ctx *appContext, workspace string) taskrun.RunOptions {
+	if !ctx.jsonOutput() {
+		return taskrun.RunOptions{}
+	}
+	qualify := func(event taskrun.TaskEvent) taskrun.TaskEvent {
+		if workspace != "" {
+			event.Task = workspace + ":" + event.Task
+		}
+		return event
+	}
+	return taskrun.RunOptions{
+		Stdout: os.Stderr,
+		OnStart: func(event taskrun.TaskEvent) {
+			_ = writeJSONLine(ctx.stdout(), newTaskStartEvent(qualify(event)))
+		},
+		OnFinish: func(event taskrun.TaskEvent) {
+			_ = writeJSONLine(ctx.stdout(), newTaskFinishEvent(qualify(event)))
+		},
+	}
+}
+
+func runWorkspaceTask(ctx *appContext, taskCfg *manifest.TaskConfig, rootDir, workspaceName, taskName string, taskArgs []string) error {
+	workspaces, err := manifest.ResolveWorkspaces(taskCfg, rootDir)
+	if err != nil {
+		return newExitCodeError(shared.ExitConfigError, err)
+	}
+	loadOpts, err := ctx.loadOptions()
+	if err != nil {
+		return err
+	}
+	for _, workspace := range workspaces {
+		if workspace.Name != workspaceName {
+			continue
+		}
+		workspaceCfg, err := workspace.Load(loadOpts)
+		if err != nil {
+			return newExitCodeError(shared.ExitConfigError, err)
+		}
+		if _, ok := workspaceCfg.Tasks[taskName]; !ok {
+			return newExitCodeError(shared.ExitTaskUndefined, fmt.Errorf("task %q is not defined in workspace %q", taskName, workspaceName))
+		}
+		if err := taskrun.RunTaskWithOptions(workspaceCfg, taskName, workspace.Dir, taskArgs, newRunOptions(ctx, workspace.Name)); err != nil {
+			return runTaskError(err)
+		}
+		return nil
+	}
+	return newExitCodeError(shared.ExitTaskUndefined, fmt.Errorf("workspace %q is not defined", workspaceName))
+}
+
+// runAllWorkspaces runs taskName in every workspace that defines it, keeps
+// going after failures and reports one summary at the end.
+func runAllWorkspaces(ctx *appContext, taskCfg *manifest.TaskConfig, rootDir, taskName string, taskArgs []string) error {
+	workspaces, err := manifest.ResolveWorkspaces(taskCfg, rootDir)
+	if err != nil {
+		return newExitCodeError(shared.ExitConfigError, err)
+	}
+	loadOpts, err := ctx.loadOptions()
+	if err != nil {
+		return err
+	}
+	summary := runSummaryEvent{Event: eventRunSummary, Task: taskName, Workspaces: []workspaceResult{}}
+	for _, workspace := range workspaces {
+		workspaceCfg, err := workspace.Load(loadOpts)
+		if err != nil {
+			return newExitCodeError(shared.ExitConfigError, err)
+		}
+		if _, ok := workspaceCfg.Tasks[taskName]; !ok {
+			continue
+		}
+		if !ctx.jsonOutput() {
+			fmt.Fprintf(ctx.stdout(), "workspace %s\n", workspace.Name)
+		}
+		started := time.Now()
+		err = taskrun.RunTaskWithOptions(workspaceCfg, taskName, workspace.Dir, taskArgs, newRunOptions(ctx, workspace.Name))
+		result := workspaceResult{Name: workspace.Name, Dir: workspace.Dir, DurationMS: time.Since(started).Round(time.Millisecond).Milliseconds()}
+		if err != nil {
+			result.Error = err.Error()
+			summary.Failed++
+		} else {
+			summary.OK++
+		}
+		summary.Workspaces = append(summary.Workspaces, result)
+	}
+	if len(summary.Workspaces) == 0 {
+		return newExitCodeError(shared.ExitTaskUndefined, fmt.Errorf("task %q is not defined in any workspace", taskName))
+	}
+
+	if ctx.jsonOutput() {
+		if err := writeJSONLine(ctx.stdout(), summary); err != nil {
+			return err
+		}
+	} else {
+		for _, result := range summary.Workspaces {
+			status := "ok"
+			if result.Error != "" {
+				status = "failed"
+			}
+			fmt.Fprintf(ctx.stdout(), "%s\t%s\t%dms\n", result.Name, status, result.DurationMS)
+		}
+		fmt.Fprintf(ctx.stdout(), "ok=%d failed=%d\n", summary.OK, summary.Failed)
+	}
+	if summary.Failed > 0 {
+		return newExitCodeError(shared.ExitTaskFailed, fmt.Errorf("task %q failed in %d of %d workspaces", taskName, summary.Failed, len(summary.Workspaces)))
+	}
+	return nil
+}
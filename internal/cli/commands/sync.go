@@ -1,16 +1,21 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/pirakansa/vorbere/internal/cli/manifest"
 	"github.com/pirakansa/vorbere/internal/cli/shared"
+	"github.com/pirakansa/vorbere/internal/cli/taskrun"
 	"github.com/spf13/cobra"
 )
 
 type syncCommandOptions struct {
 	overwrite bool
 	dryRun    bool
+	only      []string
+	skip      []string
 }
 
 func newSyncCmd(ctx *appContext) *cobra.Command {
@@ -25,41 +30,88 @@ func newSyncCmd(ctx *appContext) *cobra.Command {
 	}
 	cmd.Flags().BoolVar(&opts.overwrite, "overwrite", false, "overwrite existing files without timestamp backup")
 	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "show actions without writing files")
+	cmd.Flags().StringArrayVar(&opts.only, "only", nil, "sync only files matching a tag, repository _comment or output path glob (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.skip, "skip", nil, "skip files matching a tag, repository _comment or output path glob (repeatable)")
 	return cmd
 }
 
 func runSyncWithOptions(ctx *appContext, opts syncCommandOptions) error {
-	taskCfg, rootDir, err := loadTaskAndRoot(ctx.configPath)
+	taskCfg, rootDir, err := ctx.loadTask()
 	if err != nil {
 		return err
 	}
-	syncCfg, err := manifest.ResolveSyncConfig(taskCfg, ctx.configPath)
+	syncCfg, err := manifest.ResolveFilteredSyncConfig(taskCfg, ctx.configPath, manifest.SyncFilter{
+		Only: opts.only,
+		Skip: opts.skip,
+	})
 	if err != nil {
 		return newExitCodeError(shared.ExitConfigError, err)
 	}
+	for _, warning := range syncCfg.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
 
 	res, err := manifest.Sync(syncCfg, manifest.SyncOptions{
 		RootDir:   rootDir,
 		Overwrite: opts.overwrite,
 		DryRun:    opts.dryRun,
 		OnFile: func(progress manifest.SyncFileProgress) {
-			fmt.Println(formatSyncProgress(progress))
+			if ctx.jsonOutput() {
+				_ = writeJSONLine(ctx.stdout(), syncFileEvent{Event: eventSyncFile, SyncFileProgress: progress})
+				return
+			}
+			fmt.Fprintln(ctx.stdout(), formatSyncProgress(progress))
 		},
 	})
-	printSyncResult(res)
+	printSyncResult(ctx, res)
 	if err != nil {
+		var configErr *manifest.ConfigError
+		if errors.As(err, &configErr) {
+			return newExitCodeError(shared.ExitConfigError, err)
+		}
 		return newExitCodeError(shared.ExitSyncFailed, err)
 	}
+	if res.Conflicts > 0 {
+		return newExitCodeError(shared.ExitSyncConflict, fmt.Errorf("%d file(s) have merge conflicts; resolve the conflict markers and run sync again", res.Conflicts))
+	}
+	if opts.dryRun {
+		return nil
+	}
+	return runSyncHooks(ctx, taskCfg, rootDir, manifest.ChangedHooks(syncCfg, res))
+}
+
+// runSyncHooks runs on_change and sync.after tasks after a successful sync.
+func runSyncHooks(ctx *appContext, taskCfg *manifest.TaskConfig, rootDir string, hooks []string) error {
+	for _, name := range hooks {
+		if !ctx.jsonOutput() {
+			fmt.Fprintf(ctx.stdout(), "hook %s\n", name)
+		}
+		if err := taskrun.RunTaskWithOptions(taskCfg, name, rootDir, nil, newRunOptions(ctx, "")); err != nil {
+			return newExitCodeError(shared.ExitHookFailed, fmt.Errorf("sync hook: %w", err))
+		}
+	}
 	return nil
 }
 
-func printSyncResult(res *manifest.SyncResult) {
+func printSyncResult(ctx *appContext, res *manifest.SyncResult) {
 	if res == nil {
 		return
 	}
-	fmt.Printf("created=%d updated=%d unchanged=%d\n", res.Created, res.Updated, res.Unchanged)
+	if ctx.jsonOutput() {
+		_ = writeJSONLine(ctx.stdout(), syncResultEvent{Event: eventSyncResult, SyncResult: res})
+		return
+	}
+	line := fmt.Sprintf("created=%d updated=%d unchanged=%d", res.Created, res.Updated, res.Unchanged)
+	if res.Conflicts > 0 {
+		line += fmt.Sprintf(" conflict=%d", res.Conflicts)
+	}
+	fmt.Fprintln(ctx.stdout(), line)
 }
 
 func formatSyncProgress(progress manifest.SyncFileProgress) string {
-	return fmt.Sprintf("[%d/%d] %s %s", progress.Index, progress.Total, progress.Outcome, progress.Path)
+	line := fmt.Sprintf("[%d/%d] %s %s", progress.Index, progress.Total, progress.Outcome, progress.Path)
+	if progress.Revision != "" {
+		line += fmt.Sprintf(" (%s)", progress.Revision)
+	}
+	return line
 }
@@ -3,6 +3,7 @@ package commands
 import (
 	"fmt"
 
+	"github.com/pirakansa/vorbere/internal/cli/shared"
 	"github.com/pirakansa/vorbere/internal/cli/taskrun"
 	"github.com/spf13/cobra"
 )
@@ -13,6 +14,7 @@ func newTasksCmd(ctx *appContext) *cobra.Command {
 		Short: "Task helpers",
 	}
 	cmd.AddCommand(newTasksListCmd(ctx))
+	cmd.AddCommand(newTasksGraphCmd(ctx))
 	return cmd
 }
 
@@ -21,19 +23,52 @@ func newTasksListCmd(ctx *appContext) *cobra.Command {
 		Use:   "list",
 		Short: "List available tasks",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			taskCfg, _, err := loadTaskAndRoot(ctx.configPath)
+			taskCfg, _, err := ctx.loadTask()
 			if err != nil {
 				return err
 			}
-			for _, name := range taskrun.ListTaskNames(taskCfg) {
+			names := taskrun.ListTaskNames(taskCfg)
+			if ctx.jsonOutput() {
+				entries := make([]taskListEntry, 0, len(names))
+				for _, name := range names {
+					entries = append(entries, newTaskListEntry(name, taskCfg.Tasks[name]))
+				}
+				return writeJSONLine(ctx.stdout(), entries)
+			}
+			for _, name := range names {
 				desc := taskCfg.Tasks[name].Desc
 				if desc == "" {
-					fmt.Println(name)
+					fmt.Fprintln(ctx.stdout(), name)
 					continue
 				}
-				fmt.Printf("%s\t%s\n", name, desc)
+				fmt.Fprintf(ctx.stdout(), "%s\t%s\n", name, desc)
 			}
 			return nil
 		},
 	}
 }
+
+func newTasksGraphCmd(ctx *appContext) *cobra.Command {
+	format := taskrun.GraphFormatTree
+	cmd := &cobra.Command{
+		Use:   "graph [task]",
+		Short: "Show the depends_on graph of a task or of all tasks",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			taskCfg, _, err := ctx.loadTask()
+			if err != nil {
+				return err
+			}
+			root := ""
+			if len(args) == 1 {
+				root = args[0]
+				if _, ok := taskCfg.Tasks[root]; !ok {
+					return newExitCodeError(shared.ExitTaskUndefined, fmt.Errorf("task %q is not defined", root))
+				}
+			}
+			return taskrun.WriteTaskGraph(ctx.stdout(), taskCfg, root, format)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", taskrun.GraphFormatTree, "output format: tree, dot or mermaid")
+	return cmd
+}
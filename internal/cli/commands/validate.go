@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pirakansa/vorbere/internal/cli/manifest"
+	"github.com/pirakansa/vorbere/internal/cli/shared"
+	"github.com/spf13/cobra"
+)
+
+type validateResult struct {
+	Valid  bool                       `json:"valid"`
+	Issues []manifest.ValidationIssue `json:"issues"`
+}
+
+func newValidateCmd(ctx *appContext) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Check the config and report every problem found",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ctx.resolveConfigPath(); err != nil {
+				return err
+			}
+			opts, err := ctx.loadOptions()
+			if err != nil {
+				return err
+			}
+			issues, err := manifest.ValidateConfig(ctx.configPath, opts)
+			if err != nil {
+				return newExitCodeError(shared.ExitConfigError, err)
+			}
+			if ctx.jsonOutput() {
+				if issues == nil {
+					issues = []manifest.ValidationIssue{}
+				}
+				if err := writeJSONLine(ctx.stdout(), validateResult{Valid: len(issues) == 0, Issues: issues}); err != nil {
+					return err
+				}
+			} else if len(issues) == 0 {
+				fmt.Fprintf(ctx.stdout(), "%s: ok\n", ctx.configPath)
+			} else {
+				for _, issue := range issues {
+					fmt.Fprintln(ctx.stdout(), issue.String())
+				}
+			}
+			if len(issues) > 0 {
+				return newExitCodeError(shared.ExitConfigError, fmt.Errorf("%d problem(s) found in %s", len(issues), ctx.configPath))
+			}
+			return nil
+		},
+	}
+}
+
+func newSchemaCmd(ctx *appContext) *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of vorbere.yaml",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			body, err := json.MarshalIndent(manifest.JSONSchema(), "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(ctx.stdout(), "%s\n", body)
+			return err
+		},
+	}
+}
@@ -0,0 +1,132 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+)
+
+// DigestOptions controls digest computation.
+type DigestOptions struct {
+	RootDir   string
+	CacheDir  string
+	Algorithm string
+	// Selector limits computation to matching entries; see MatchesDigestSelector.
+	Selector string
+}
+
+// FileDigest holds computed digests for one repositories[].files[] entry.
+type FileDigest struct {
+	RepositoryIndex int
+	FileIndex       int
+	// Origin and OriginRepositoryIndex locate the entry in the manifest
+	// that declares it; see FileRule.
+	Origin                string
+	OriginRepositoryIndex int
+	Path                  string
+	DownloadDigest        string
+	// OutputDigest is empty when the entry resolves to several files.
+	OutputDigest string
+	// Encoded reports that the output differs from the download because an
+	// encoding is applied.
+	Encoded bool
+	// Skipped explains why no digest could be computed.
+	Skipped string
+}
+
+// FieldPath returns the path of the entry in the manifest that declares it,
+// for example "repositories[0].files[1]".
+func (d FileDigest) FieldPath() string {
+	return fmt.Sprintf("repositories[%d].files[%d]", d.OriginRepositoryIndex, d.FileIndex)
+}
+
+// ComputeDigests downloads every selected entry and computes its download and
+// output digests with the same fetch and decode pipeline as Sync. Digests
+// already in the manifest are ignored; digests published by the source are
+// still verified.
+func ComputeDigests(cfg *SyncConfig, opts DigestOptions) ([]FileDigest, error) {
+	if opts.RootDir == "" {
+		return nil, errors.New("root dir is required")
+	}
+	if opts.Algorithm == "" {
+		opts.Algorithm = DigestAlgorithmSHA256
+	}
+	if _, err := computeDigest(nil, opts.Algorithm); err != nil {
+		return nil, err
+	}
+	if err := ValidateSyncConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	digests := make([]FileDigest, 0, len(cfg.Files))
+	fetcher := newSourceFetcher(opts.RootDir, opts.CacheDir)
+	for _, rule := range cfg.Files {
+		if !MatchesDigestSelector(rule, opts.Selector) {
+			continue
+		}
+		digest := FileDigest{
+			RepositoryIndex:       rule.RepositoryIndex,
+			FileIndex:             rule.FileIndex,
+			Origin:                rule.Origin,
+			OriginRepositoryIndex: rule.OriginRepositoryIndex,
+			Path:                  rule.Path,
+			Encoded:               rule.Encoding != "",
+		}
+		if rule.ExpandDirectory {
+			digest.Skipped = "directory and glob selections have no single digest"
+			digests = append(digests, digest)
+			continue
+		}
+
+		fetched, err := fetcher.fetch(cfg.Sources[rule.Source])
+		if err != nil {
+			return nil, err
+		}
+		if err := fetcher.verifyPublishedChecksums(cfg, rule, fetched); err != nil {
+			return nil, err
+		}
+		if digest.DownloadDigest, err = formatDigest(fetched.content, opts.Algorithm); err != nil {
+			return nil, err
+		}
+		processed, err := processArtifact(fetched.content, rule)
+		if err != nil {
+			return nil, err
+		}
+		if processed.single != nil {
+			if digest.OutputDigest, err = formatDigest(processed.single.content, opts.Algorithm); err != nil {
+				return nil, err
+			}
+		}
+		digests = append(digests, digest)
+	}
+	return digests, nil
+}
+
+// MatchesDigestSelector reports whether rule matches selector: an entry path
+// such as "repositories[0]" or "repositories[0].files[1]", or a glob matched
+// against the output path or its base name. An empty selector matches all.
+func MatchesDigestSelector(rule FileRule, selector string) bool {
+	if selector == "" {
+		return true
+	}
+	repositoryPath := fmt.Sprintf("repositories[%d]", rule.RepositoryIndex)
+	if selector == repositoryPath || selector == fmt.Sprintf("%s.files[%d]", repositoryPath, rule.FileIndex) {
+		return true
+	}
+	target := filepath.ToSlash(rule.Path)
+	for _, candidate := range []string{target, path.Base(target)} {
+		if ok, _ := path.Match(selector, candidate); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func formatDigest(content []byte, algorithm string) (string, error) {
+	digest, err := computeDigest(content, algorithm)
+	if err != nil {
+		return "", err
+	}
+	return algorithm + ":" + digest, nil
+}
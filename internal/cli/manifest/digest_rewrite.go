@@ -0,0 +1,319 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	pkgmanifest "github.com/pirakansa/vorbere/pkg/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// lineEdit replaces lines [start, end] (1-based, inclusive) with lines. An
+// insertion uses end == start-1.
+type lineEdit struct {
+	start int
+	end   int
+	lines []string
+}
+
+type digestField struct {
+	key   string
+	value string
+}
+
+// RewriteDigests writes computed digests back into the config file at
+// configPath. The file is parsed with the yaml.v3 node API to locate each
+// repositories[].files[] entry and only the affected values are edited in
+// place, so comments, blank lines and formatting elsewhere are preserved.
+// output_digest is written when the entry applies an encoding or already
+// has one. taskCfg is the loaded config the digests were computed from;
+// entries of included files are not written and are returned as warnings.
+func RewriteDigests(configPath string, taskCfg *TaskConfig, digests []FileDigest) ([]string, error) {
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	writable := make([]FileDigest, 0, len(digests))
+	for _, digest := range digests {
+		if digest.Skipped != "" || digest.DownloadDigest == "" {
+			continue
+		}
+		if origin := taskCfg.Repositories[digest.RepositoryIndex].Origin; origin != "" {
+			warnings = append(warnings, fmt.Sprintf("%s is declared in %s and was not written", digest.FieldPath(), origin))
+			continue
+		}
+		writable = append(writable, digest)
+	}
+
+	edits := make([]lineEdit, 0, len(writable))
+	verified := make([]FileDigest, 0, len(writable))
+	for _, digest := range writable {
+		repoIndex := pkgmanifest.OriginRepositoryIndex(taskCfg.Repositories, digest.RepositoryIndex)
+		fileNode, err := findRepositoryFileNode(&document, repoIndex, digest.FileIndex)
+		if err != nil {
+			return nil, err
+		}
+		fields := []digestField{{key: "download_digest", value: digest.DownloadDigest}}
+		if _, existing := mappingValue(fileNode, "output_digest"); digest.OutputDigest != "" && (digest.Encoded || existing != nil) {
+			fields = append(fields, digestField{key: "output_digest", value: digest.OutputDigest})
+		}
+		fields, templated := skipTemplatedDigests(fileNode, fields, fmt.Sprintf("repositories[%d].files[%d]", repoIndex, digest.FileIndex))
+		warnings = append(warnings, templated...)
+		if len(fields) > 0 && fields[0].key == "download_digest" {
+			verified = append(verified, digest)
+		}
+		fileEdits, err := digestFieldEdits(string(content), fileNode, fields)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", digest.FieldPath(), err)
+		}
+		edits = append(edits, fileEdits...)
+	}
+	if len(edits) == 0 {
+		return warnings, nil
+	}
+
+	updated := applyLineEdits(string(content), edits)
+	if err := verifyRewrittenDigests([]byte(updated), taskCfg.Repositories, verified); err != nil {
+		return nil, err
+	}
+	return warnings, os.WriteFile(configPath, []byte(updated), info.Mode().Perm())
+}
+
+// skipTemplatedDigests drops the fields whose current value is a ${{ }}
+// template: writing a literal digest would replace the template, so a
+// warning names the vars to update instead.
+func skipTemplatedDigests(fileNode *yaml.Node, fields []digestField, filePath string) ([]digestField, []string) {
+	var warnings []string
+	kept := fields[:0]
+	for _, field := range fields {
+		_, valueNode := mappingValue(fileNode, field.key)
+		names, templated := templateDigestVars(valueNode)
+		if !templated {
+			kept = append(kept, field)
+			continue
+		}
+		target := "it"
+		if len(names) > 0 {
+			target = "var(s) " + strings.Join(names, ", ")
+		}
+		warnings = append(warnings, fmt.Sprintf("%s.%s is a template and was not written; update %s to %s", filePath, field.key, target, field.value))
+	}
+	return kept, warnings
+}
+
+// templateDigestVars reports whether a digest value, a scalar or a list of
+// scalars, contains a template and returns the vars it references.
+func templateDigestVars(node *yaml.Node) ([]string, bool) {
+	if node == nil {
+		return nil, false
+	}
+	values := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		values = node.Content
+	}
+	var names []string
+	templated := false
+	for _, value := range values {
+		refs, ok := pkgmanifest.TemplateVars(value.Value)
+		if ok {
+			templated = true
+			names = append(names, refs...)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names), templated
+}
+
+func findRepositoryFileNode(document *yaml.Node, repoIndex, fileIndex int) (*yaml.Node, error) {
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return nil, errors.New("config is empty")
+	}
+	_, repositories := mappingValue(document.Content[0], "repositories")
+	if repositories == nil || repositories.Kind != yaml.SequenceNode || repoIndex >= len(repositories.Content) {
+		return nil, fmt.Errorf("repositories[%d] not found in config", repoIndex)
+	}
+	_, files := mappingValue(repositories.Content[repoIndex], "files")
+	if files == nil || files.Kind != yaml.SequenceNode || fileIndex >= len(files.Content) {
+		return nil, fmt.Errorf("repositories[%d].files[%d] not found in config", repoIndex, fileIndex)
+	}
+	fileNode := files.Content[fileIndex]
+	if fileNode.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("repositories[%d].files[%d] is not a mapping", repoIndex, fileIndex)
+	}
+	if fileNode.Style&yaml.FlowStyle != 0 {
+		return nil, fmt.Errorf("repositories[%d].files[%d] uses flow style; update digests manually", repoIndex, fileIndex)
+	}
+	return fileNode, nil
+}
+
+func mappingValue(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for index := 0; index+1 < len(node.Content); index += 2 {
+		if node.Content[index].Value == key {
+			return node.Content[index], node.Content[index+1]
+		}
+	}
+	return nil, nil
+}
+
+func digestFieldEdits(content string, fileNode *yaml.Node, fields []digestField) ([]lineEdit, error) {
+	lines := strings.SplitAfter(content, "\n")
+	edits := make([]lineEdit, 0, len(fields))
+	var inserted []string
+	for _, field := range fields {
+		keyNode, valueNode := mappingValue(fileNode, field.key)
+		if keyNode == nil {
+			indent := strings.Repeat(" ", fileNode.Content[0].Column-1)
+			inserted = append(inserted, indent+field.key+": "+field.value+lineEnding(content))
+			continue
+		}
+		line := []rune(lines[keyNode.Line-1])
+		if valueNode.Kind == yaml.ScalarNode && valueNode.Line == keyNode.Line && !isBlockScalar(valueNode) {
+			start := valueNode.Column - 1
+			end := scalarEnd(line, start)
+			replacement := string(line[:start]) + quoteLike(line[start:end], field.value) + string(line[end:])
+			edits = append(edits, lineEdit{start: keyNode.Line, end: keyNode.Line, lines: []string{replacement}})
+			continue
+		}
+		if valueNode.Kind == yaml.AliasNode {
+			return nil, fmt.Errorf("%s uses an alias; update it manually", field.key)
+		}
+		// Block sequences and multi-line values are replaced by one scalar line.
+		prefix := string(line[:keyNode.Column-1])
+		edits = append(edits, lineEdit{
+			start: keyNode.Line,
+			end:   lastLine(valueNode),
+			lines: []string{prefix + field.key + ": " + field.value + lineEnding(content)},
+		})
+	}
+	if len(inserted) > 0 {
+		after := lastLine(fileNode)
+		edits = append(edits, lineEdit{start: after + 1, end: after, lines: inserted})
+	}
+	return edits, nil
+}
+
+// applyLineEdits applies non-overlapping edits from the bottom of the file up
+// so earlier line numbers stay valid.
+func applyLineEdits(content string, edits []lineEdit) string {
+	lines := strings.SplitAfter(content, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		lines[len(lines)-1] += lineEnding(content)
+	}
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+	for _, edit := range edits {
+		rest := append([]string{}, lines[edit.end:]...)
+		lines = append(append(lines[:edit.start-1], edit.lines...), rest...)
+	}
+	return strings.Join(lines, "")
+}
+
+func verifyRewrittenDigests(content []byte, repos []Repository, digests []FileDigest) error {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return fmt.Errorf("rewritten config is invalid: %w", err)
+	}
+	for _, digest := range digests {
+		repoIndex := pkgmanifest.OriginRepositoryIndex(repos, digest.RepositoryIndex)
+		fileNode, err := findRepositoryFileNode(&document, repoIndex, digest.FileIndex)
+		if err != nil {
+			return fmt.Errorf("rewritten config is invalid: %w", err)
+		}
+		if _, value := mappingValue(fileNode, "download_digest"); value == nil || value.Value != digest.DownloadDigest {
+			return fmt.Errorf("rewritten config is invalid: %s.download_digest was not updated", digest.FieldPath())
+		}
+	}
+	return nil
+}
+
+// lastLine returns the last line occupied by node and its children.
+func lastLine(node *yaml.Node) int {
+	last := node.Line
+	if isBlockScalar(node) {
+		last += strings.Count(strings.TrimRight(node.Value, "\n"), "\n") + 1
+	}
+	for _, child := range node.Content {
+		if line := lastLine(child); line > last {
+			last = line
+		}
+	}
+	return last
+}
+
+func isBlockScalar(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0
+}
+
+// scalarEnd returns the index after a single-line scalar that starts at start.
+func scalarEnd(line []rune, start int) int {
+	switch line[start] {
+	case '"':
+		for index := start + 1; index < len(line); index++ {
+			if line[index] == '\\' {
+				index++
+				continue
+			}
+			if line[index] == '"' {
+				return index + 1
+			}
+		}
+	case '\'':
+		for index := start + 1; index < len(line); index++ {
+			if line[index] != '\'' {
+				continue
+			}
+			if index+1 < len(line) && line[index+1] == '\'' {
+				index++
+				continue
+			}
+			return index + 1
+		}
+	}
+	end := len(line)
+	for index := start; index < len(line); index++ {
+		if line[index] == '#' && index > start && (line[index-1] == ' ' || line[index-1] == '\t') {
+			end = index
+			break
+		}
+	}
+	for end > start && strings.ContainsRune(" \t\r\n", line[end-1]) {
+		end--
+	}
+	return end
+}
+
+// quoteLike formats value with the quoting style of the scalar it replaces.
+func quoteLike(original []rune, value string) string {
+	if len(original) > 0 && (original[0] == '"' || original[0] == '\'') {
+		return string(original[0]) + value + string(original[0])
+	}
+	return value
+}
+
+func lineEnding(content string) string {
+	if strings.Contains(content, "\r\n") {
+		return "\r\n"
+	}
+	return "\n"
+}
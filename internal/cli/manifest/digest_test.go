@@ -0,0 +1,258 @@
+package manifest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pirakansa/vorbere/internal/cli/shared"
+)
+
+func TestComputeDigestsUsesSyncPipeline(t *testing.T) {
+	plain := []byte("plain")
+	decoded := []byte("decoded-tool")
+	encoded := mustEncodeZstd(t, decoded)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/plain.txt":
+			_, _ = w.Write(plain)
+		case "/tool.zst":
+			_, _ = w.Write(encoded)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{
+			"plain": {URL: server.URL + "/plain.txt"},
+			"tool":  {URL: server.URL + "/tool.zst"},
+		},
+		Files: []FileRule{
+			{Source: "plain", Path: "plain.txt", DownloadChecksum: "sha256:stale", FileIndex: 0},
+			{Source: "tool", Path: "bin/tool", Encoding: EncodingZstd, FileIndex: 1},
+		},
+	}
+	digests, err := ComputeDigests(cfg, DigestOptions{RootDir: t.TempDir(), Algorithm: DigestAlgorithmBLAKE3})
+	if err != nil {
+		t.Fatalf("ComputeDigests returned error: %v", err)
+	}
+	if len(digests) != 2 {
+		t.Fatalf("expected 2 digests, got %+v", digests)
+	}
+	if got := digests[0].DownloadDigest; got != "blake3:"+shared.BLAKE3Hex(plain) {
+		t.Fatalf("unexpected download digest: %s", got)
+	}
+	if digests[1].DownloadDigest != "blake3:"+shared.BLAKE3Hex(encoded) || digests[1].OutputDigest != "blake3:"+shared.BLAKE3Hex(decoded) {
+		t.Fatalf("unexpected encoded digests: %+v", digests[1])
+	}
+	if !digests[1].Encoded || digests[1].FieldPath() != "repositories[0].files[1]" {
+		t.Fatalf("unexpected digest metadata: %+v", digests[1])
+	}
+
+	selected, err := ComputeDigests(cfg, DigestOptions{RootDir: t.TempDir(), Selector: "bin/*"})
+	if err != nil {
+		t.Fatalf("ComputeDigests returned error: %v", err)
+	}
+	if len(selected) != 1 || selected[0].Path != "bin/tool" || !strings.HasPrefix(selected[0].DownloadDigest, "sha256:") {
+		t.Fatalf("unexpected selection: %+v", selected)
+	}
+}
+
+func TestMatchesDigestSelector(t *testing.T) {
+	rule := FileRule{Path: "bin/tool", RepositoryIndex: 1, FileIndex: 2}
+	for _, selector := range []string{"", "repositories[1]", "repositories[1].files[2]", "bin/*", "tool", "t*"} {
+		if !MatchesDigestSelector(rule, selector) {
+			t.Fatalf("expected %q to match", selector)
+		}
+	}
+	for _, selector := range []string{"repositories[0]", "repositories[1].files[0]", "lib/*", "other"} {
+		if MatchesDigestSelector(rule, selector) {
+			t.Fatalf("expected %q not to match", selector)
+		}
+	}
+}
+
+func TestRewriteDigestsPreservesCommentsAndFormatting(t *testing.T) {
+	original := `version: 1
+
+# Shared tooling.
+repositories:
+  - _comment: tools # pinned
+    url: https://example.com/
+
+    files:
+      - file_name: a.txt   # first file
+        out_dir: .
+        download_digest: "sha256:old"   # keep me
+
+      - file_name: tool.zst
+        encoding: zstd
+        out_dir: bin
+        download_digest:
+          - sha256:old
+          - md5:old
+        mode: "0755"
+      - out_dir: .
+        file_name: plain.txt
+# trailing comment
+`
+	configPath := filepath.Join(t.TempDir(), "vorbere.yaml")
+	if err := os.WriteFile(configPath, []byte(original), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	digests := []FileDigest{
+		{FileIndex: 0, DownloadDigest: "sha256:aaa", OutputDigest: "sha256:aaa"},
+		{FileIndex: 1, DownloadDigest: "sha256:bbb", OutputDigest: "sha256:ccc", Encoded: true},
+		{FileIndex: 2, DownloadDigest: "sha256:ddd", OutputDigest: "sha256:ddd"},
+	}
+	taskCfg := &TaskConfig{Repositories: []Repository{{}}}
+	if _, err := RewriteDigests(configPath, taskCfg, digests); err != nil {
+		t.Fatalf("RewriteDigests returned error: %v", err)
+	}
+
+	got, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	expected := `version: 1
+
+# Shared tooling.
+repositories:
+  - _comment: tools # pinned
+    url: https://example.com/
+
+    files:
+      - file_name: a.txt   # first file
+        out_dir: .
+        download_digest: "sha256:aaa"   # keep me
+
+      - file_name: tool.zst
+        encoding: zstd
+        out_dir: bin
+        download_digest: sha256:bbb
+        mode: "0755"
+        output_digest: sha256:ccc
+      - out_dir: .
+        file_name: plain.txt
+        download_digest: sha256:ddd
+# trailing comment
+`
+	if string(got) != expected {
+		t.Fatalf("unexpected rewritten config:\n%s", string(got))
+	}
+	info, err := os.Stat(configPath)
+	if err != nil {
+		t.Fatalf("stat config: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected file mode to be preserved, got %v", info.Mode().Perm())
+	}
+}
+
+func TestRewriteDigestsRejectsFlowStyleEntries(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "vorbere.yaml")
+	content := "version: 1\nrepositories:\n  - url: https://example.com/\n    files: [{file_name: a.txt, out_dir: .}]\n"
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	taskCfg := &TaskConfig{Repositories: []Repository{{}}}
+	_, err := RewriteDigests(configPath, taskCfg, []FileDigest{{DownloadDigest: "sha256:aaa"}})
+	if err == nil || !strings.Contains(err.Error(), "flow style") {
+		t.Fatalf("expected flow style error, got %v", err)
+	}
+}
+
+func TestRewriteDigestsLeavesTemplatedValues(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "vorbere.yaml")
+	content := `version: 1
+vars:
+  TOOL_DIGEST: sha256:old
+repositories:
+  - url: https://example.com/
+    files:
+      - file_name: tool.zst
+        encoding: zstd
+        out_dir: bin
+        download_digest: ${{ .vars.TOOL_DIGEST }}
+        output_digest: sha256:old
+`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	taskCfg := &TaskConfig{Repositories: []Repository{{}}}
+	digests := []FileDigest{{DownloadDigest: "sha256:aaa", OutputDigest: "sha256:bbb", Encoded: true}}
+	warnings, err := RewriteDigests(configPath, taskCfg, digests)
+	if err != nil {
+		t.Fatalf("RewriteDigests returned error: %v", err)
+	}
+	want := "repositories[0].files[0].download_digest is a template and was not written; update var(s) TOOL_DIGEST to sha256:aaa"
+	if len(warnings) != 1 || warnings[0] != want {
+		t.Fatalf("expected warning %q, got %v", want, warnings)
+	}
+	got, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if want := strings.Replace(content, "output_digest: sha256:old", "output_digest: sha256:bbb", 1); string(got) != want {
+		t.Fatalf("expected only output_digest to be rewritten, got:\n%s", got)
+	}
+}
+
+func TestRewriteDigestsMapsIncludedRepositories(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "vorbere.yaml")
+	writeConfigFile(t, filepath.Join(dir, "shared.yaml"), `version: 1
+repositories:
+  - url: https://example.com/shared/
+    files:
+      - file_name: shared.txt
+        out_dir: .
+`)
+	writeConfigFile(t, configPath, `version: 1
+includes:
+  - shared.yaml
+repositories:
+  - url: https://example.com/root/
+    files:
+      - file_name: root.txt
+        out_dir: .
+`)
+	taskCfg, err := LoadTaskConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTaskConfig returned error: %v", err)
+	}
+
+	digests := []FileDigest{
+		{RepositoryIndex: 0, DownloadDigest: "sha256:aaa"},
+		{RepositoryIndex: 1, DownloadDigest: "sha256:bbb"},
+	}
+	warnings, err := RewriteDigests(configPath, taskCfg, digests)
+	if err != nil {
+		t.Fatalf("RewriteDigests returned error: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "repositories[0].files[0] is declared in") || !strings.Contains(warnings[0], "shared.yaml") {
+		t.Fatalf("expected a warning for the included entry, got %v", warnings)
+	}
+
+	got, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if !strings.Contains(string(got), "out_dir: .\n        download_digest: sha256:bbb\n") || strings.Contains(string(got), "sha256:aaa") {
+		t.Fatalf("expected only the root entry to be rewritten, got:\n%s", got)
+	}
+	shared, err := os.ReadFile(filepath.Join(dir, "shared.yaml"))
+	if err != nil {
+		t.Fatalf("read include: %v", err)
+	}
+	if strings.Contains(string(shared), "download_digest") {
+		t.Fatalf("expected the include to be left untouched, got:\n%s", shared)
+	}
+}
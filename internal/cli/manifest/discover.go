@@ -0,0 +1,65 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigFileNames are the manifest file names looked up by config discovery
+// and in workspace directories, in order of preference.
+var ConfigFileNames = []string{"vorbere.yaml", "vorbere.yml", ".vorbere.yaml"}
+
+// DiscoverConfig searches dir and its parents for a manifest. The search
+// stops after the git root (a directory containing .git) or home.
+func DiscoverConfig(dir, home string) (string, error) {
+	start, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if home != "" {
+		if abs, err := filepath.Abs(home); err == nil {
+			home = abs
+		}
+	}
+	for current := start; ; {
+		path, err := findConfigFile(current)
+		if err != nil || path != "" {
+			return path, err
+		}
+		if current == home || pathExists(filepath.Join(current, ".git")) {
+			break
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+	return "", fmt.Errorf("no %s found in %s or its parents", strings.Join(ConfigFileNames, ", "), start)
+}
+
+// findConfigFile returns the first manifest present in dir, or "".
+func findConfigFile(dir string) (string, error) {
+	for _, name := range ConfigFileNames {
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,58 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiscoverConfigWalksUpToNearestManifest(t *testing.T) {
+	temp := t.TempDir()
+	writeConfigFile(t, filepath.Join(temp, ".vorbere.yaml"), "tasks: {}\n")
+	writeConfigFile(t, filepath.Join(temp, "repo", "vorbere.yml"), "tasks: {}\n")
+	nested := filepath.Join(temp, "repo", "pkg", "sub")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	got, err := DiscoverConfig(nested, temp)
+	if err != nil {
+		t.Fatalf("DiscoverConfig returned error: %v", err)
+	}
+	if want := filepath.Join(temp, "repo", "vorbere.yml"); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	writeConfigFile(t, filepath.Join(temp, "repo", "vorbere.yaml"), "tasks: {}\n")
+	got, _ = DiscoverConfig(nested, temp)
+	if want := filepath.Join(temp, "repo", "vorbere.yaml"); got != want {
+		t.Fatalf("expected vorbere.yaml to be preferred, got %s", got)
+	}
+}
+
+func TestDiscoverConfigStopsAtGitRootAndHome(t *testing.T) {
+	temp := t.TempDir()
+	writeConfigFile(t, filepath.Join(temp, "vorbere.yaml"), "tasks: {}\n")
+	repo := filepath.Join(temp, "repo")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	nested := filepath.Join(repo, "sub")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	_, err := DiscoverConfig(nested, "")
+	if err == nil || !strings.Contains(err.Error(), "no vorbere.yaml, vorbere.yml, .vorbere.yaml found in "+nested) {
+		t.Fatalf("expected search to stop at git root, got %v", err)
+	}
+
+	home := filepath.Join(temp, "home")
+	if err := os.MkdirAll(filepath.Join(home, "work"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if _, err := DiscoverConfig(filepath.Join(home, "work"), home); err == nil {
+		t.Fatalf("expected search to stop at home")
+	}
+}
@@ -0,0 +1,232 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	pkgmanifest "github.com/pirakansa/vorbere/pkg/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// includeLoader resolves includes depth first. stack holds the locations
+// currently being loaded and is used for cycle detection; loaded holds every
+// location read so far, so a file reached twice is merged only once.
+type includeLoader struct {
+	root   string
+	stack  []string
+	loaded map[string]bool
+}
+
+// load reads the config at location and merges its includes. It returns nil
+// when location was already loaded through another include.
+func (l *includeLoader) load(location, digest string) (*TaskConfig, error) {
+	for index, entry := range l.stack {
+		if entry == location {
+			chain := append(append([]string{}, l.stack[index:]...), location)
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+	if l.loaded[location] {
+		return nil, nil
+	}
+	if l.loaded == nil {
+		l.loaded = map[string]bool{}
+	}
+	l.loaded[location] = true
+
+	content, err := readConfig(location)
+	if err != nil {
+		return nil, l.wrap(location, err)
+	}
+	if digest != "" {
+		if err := verifyChecksum(content, digest); err != nil {
+			return nil, l.wrap(location, err)
+		}
+	}
+
+	var cfg TaskConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, l.wrap(location, err)
+	}
+	if IsRemoteConfigLocation(location) {
+		for name, def := range cfg.VarDefs {
+			if def.Sh != "" {
+				return nil, l.wrap(location, fmt.Errorf("vars.%s.sh is not allowed in a remote config", name))
+			}
+		}
+	}
+	if location != l.root && cfg.Version != 0 && cfg.Version != pkgmanifest.DefaultTaskConfigVersion {
+		return nil, l.wrap(location, fmt.Errorf("unsupported config version %d (supported: %d)", cfg.Version, pkgmanifest.DefaultTaskConfigVersion))
+	}
+	if len(cfg.Includes) == 0 {
+		return &cfg, nil
+	}
+
+	l.stack = append(l.stack, location)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+	return l.mergeIncludes(location, &cfg)
+}
+
+// mergeIncludes merges every include of cfg in order. Later includes
+// override earlier ones and cfg itself overrides all of them; included
+// repositories come before the ones declared in cfg.
+func (l *includeLoader) mergeIncludes(location string, cfg *TaskConfig) (*TaskConfig, error) {
+	merged := &TaskConfig{
+		Version:    cfg.Version,
+		Includes:   cfg.Includes,
+		Workspaces: cfg.Workspaces,
+		VarDefs:    map[string]VarDef{},
+		Tasks:      map[string]TaskDef{},
+	}
+	for index, spec := range cfg.Includes {
+		spec, err := spec.Normalize()
+		if err != nil {
+			return nil, l.wrap(location, fmt.Errorf("includes[%d].%w", index, err))
+		}
+		target, err := resolveIncludeLocation(location, spec.Path)
+		if err != nil {
+			return nil, l.wrap(location, fmt.Errorf("includes[%d].path %w", index, err))
+		}
+		included, err := l.load(target, string(spec.Digest))
+		if err != nil {
+			return nil, err
+		}
+		if included == nil {
+			continue
+		}
+		if spec.Namespace != "" {
+			applyIncludeNamespace(included, spec.Namespace)
+		}
+		mergeTaskConfig(merged, included, target)
+	}
+	mergeTaskConfig(merged, cfg, "")
+	return merged, nil
+}
+
+func (l *includeLoader) wrap(location string, err error) error {
+	if location == l.root {
+		return err
+	}
+	return fmt.Errorf("%s: %w", location, err)
+}
+
+// resolveIncludeLocation resolves path against the directory or URL of the
+// including config.
+func resolveIncludeLocation(parent, path string) (string, error) {
+	if IsRemoteConfigLocation(path) {
+		return path, nil
+	}
+	if IsRemoteConfigLocation(parent) {
+		base, err := url.Parse(parent)
+		if err != nil {
+			return "", err
+		}
+		ref, err := url.Parse(filepath.ToSlash(path))
+		if err != nil {
+			return "", err
+		}
+		return base.ResolveReference(ref).String(), nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(parent), path)
+	}
+	return filepath.Abs(path)
+}
+
+// resolveIncludedRepositoryURLs makes relative local repository URLs of
+// local includes absolute against the directory of the including file, as
+// sync resolves the remaining relative URLs against the root config
+// directory.
+func resolveIncludedRepositoryURLs(cfg *TaskConfig) error {
+	for index, repo := range cfg.Repositories {
+		if repo.Origin == "" || IsRemoteConfigLocation(repo.Origin) || strings.TrimSpace(repo.URL) == "" {
+			continue
+		}
+		dir := filepath.Dir(repo.Origin)
+		switch strings.TrimSpace(strings.ToLower(repo.Type)) {
+		case "", pkgmanifest.RepositoryTypeHTTP:
+			if !pkgmanifest.IsLocalSourceLocation(repo.URL) {
+				continue
+			}
+			location, err := resolveLocalSourcePath(repo.URL, dir)
+			if err != nil {
+				return fmt.Errorf("%s: repositories[%d].url %w", repo.Origin, pkgmanifest.OriginRepositoryIndex(cfg.Repositories, index), err)
+			}
+			cfg.Repositories[index].URL = location
+		case pkgmanifest.RepositoryTypeGit:
+			cfg.Repositories[index].URL = resolveGitRemote(strings.TrimSpace(repo.URL), dir)
+		}
+	}
+	return nil
+}
+
+// applyIncludeNamespace prefixes task names with namespace and rewrites
+// task references that point at tasks of the same config.
+func applyIncludeNamespace(cfg *TaskConfig, namespace string) {
+	rename := func(names []string) []string {
+		if names == nil {
+			return nil
+		}
+		out := make([]string, len(names))
+		for index, name := range names {
+			if _, ok := cfg.Tasks[name]; ok {
+				name = namespace + pkgmanifest.IncludeNamespaceSeparator + name
+			}
+			out[index] = name
+		}
+		return out
+	}
+
+	tasks := make(map[string]TaskDef, len(cfg.Tasks))
+	for name, task := range cfg.Tasks {
+		task.DependsOn = rename(task.DependsOn)
+		tasks[namespace+pkgmanifest.IncludeNamespaceSeparator+name] = task
+	}
+	repositories := make([]Repository, len(cfg.Repositories))
+	for repoIndex, repo := range cfg.Repositories {
+		repo.OnChange = rename(repo.OnChange)
+		files := make([]RepositoryFile, len(repo.Files))
+		for fileIndex, file := range repo.Files {
+			file.OnChange = rename(file.OnChange)
+			files[fileIndex] = file
+		}
+		repo.Files = files
+		repositories[repoIndex] = repo
+	}
+	if cfg.Sync != nil {
+		cfg.Sync = &pkgmanifest.SyncSettings{After: rename(cfg.Sync.After)}
+	}
+	cfg.Tasks = tasks
+	cfg.Repositories = repositories
+}
+
+// mergeTaskConfig merges src into dst. origin is recorded on tasks and
+// repositories that do not already carry one.
+func mergeTaskConfig(dst, src *TaskConfig, origin string) {
+	for name, value := range src.VarDefs {
+		dst.VarDefs[name] = value
+	}
+	for name, task := range src.Tasks {
+		if task.Origin == "" {
+			task.Origin = origin
+		}
+		dst.Tasks[name] = task
+	}
+	for _, repo := range src.Repositories {
+		if repo.Origin == "" {
+			repo.Origin = origin
+		}
+		dst.Repositories = append(dst.Repositories, repo)
+	}
+	if src.Sync != nil {
+		if dst.Sync == nil {
+			dst.Sync = &pkgmanifest.SyncSettings{}
+		}
+		dst.Sync.After = append(dst.Sync.After, src.Sync.After...)
+	}
+}
@@ -0,0 +1,353 @@
+package manifest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pirakansa/vorbere/internal/cli/shared"
+)
+
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestLoadTaskConfigMergesIncludes(t *testing.T) {
+	temp := t.TempDir()
+	writeConfigFile(t, filepath.Join(temp, "shared", "base.yaml"), `
+vars:
+  GREETING: hello
+  TARGET: base
+tasks:
+  build:
+    run: echo base
+  greet:
+    run: echo ${{ .vars.GREETING }} ${{ .vars.TARGET }}
+repositories:
+  - url: https://example.com
+    files:
+      - file_name: base.txt
+`)
+	writeConfigFile(t, filepath.Join(temp, "shared", "extra.yaml"), `
+vars:
+  TARGET: extra
+tasks:
+  build:
+    run: echo extra
+`)
+	root := filepath.Join(temp, "vorbere.yaml")
+	writeConfigFile(t, root, `
+version: 1
+includes:
+  - shared/base.yaml
+  - path: shared/extra.yaml
+vars:
+  GREETING: hi
+repositories:
+  - url: https://example.com
+    files:
+      - file_name: local.txt
+`)
+
+	cfg, err := LoadTaskConfig(root)
+	if err != nil {
+		t.Fatalf("LoadTaskConfig returned error: %v", err)
+	}
+	if got := mustExpandTask(t, cfg, "greet", nil).Run; got != "echo hi extra" {
+		t.Fatalf("expected root and later include vars to win, got %q", got)
+	}
+	if got := mustExpandTask(t, cfg, "build", nil).Run; got != "echo extra" {
+		t.Fatalf("expected later include task to win, got %q", got)
+	}
+	if len(cfg.Repositories) != 2 || cfg.Repositories[0].Files[0].FileName != "base.txt" || cfg.Repositories[1].Files[0].FileName != "local.txt" {
+		t.Fatalf("expected included repositories before local ones, got %+v", cfg.Repositories)
+	}
+	if cfg.Repositories[0].Origin != filepath.Join(temp, "shared", "base.yaml") || cfg.Repositories[1].Origin != "" {
+		t.Fatalf("unexpected repository origins: %q %q", cfg.Repositories[0].Origin, cfg.Repositories[1].Origin)
+	}
+}
+
+func TestLoadTaskConfigNamespacesIncludedTasks(t *testing.T) {
+	temp := t.TempDir()
+	writeConfigFile(t, filepath.Join(temp, "lint.yaml"), `
+sync:
+  after: [fmt]
+tasks:
+  fmt:
+    run: gofmt -l .
+  go:
+    depends_on: [fmt, setup]
+repositories:
+  - url: https://example.com
+    on_change: [fmt]
+    files:
+      - file_name: .golangci.yml
+        out_dir: .
+`)
+	root := filepath.Join(temp, "vorbere.yaml")
+	writeConfigFile(t, root, `
+includes:
+  - path: lint.yaml
+    namespace: lint
+tasks:
+  setup:
+    run: echo setup
+  all:
+    depends_on: ["lint:go"]
+`)
+
+	cfg, err := LoadTaskConfig(root)
+	if err != nil {
+		t.Fatalf("LoadTaskConfig returned error: %v", err)
+	}
+	task, ok := cfg.Tasks["lint:go"]
+	if !ok {
+		t.Fatalf("expected namespaced task, got %v", cfg.Tasks)
+	}
+	if strings.Join(task.DependsOn, ",") != "lint:fmt,setup" {
+		t.Fatalf("unexpected depends_on: %v", task.DependsOn)
+	}
+	if _, ok := cfg.Tasks["fmt"]; ok {
+		t.Fatalf("expected un-namespaced task to be absent")
+	}
+	if strings.Join(cfg.Repositories[0].OnChange, ",") != "lint:fmt" || strings.Join(cfg.Sync.After, ",") != "lint:fmt" {
+		t.Fatalf("expected hook references to be namespaced, got %v %v", cfg.Repositories[0].OnChange, cfg.Sync.After)
+	}
+	if _, err := ResolveSyncConfig(cfg, root); err != nil {
+		t.Fatalf("ResolveSyncConfig returned error: %v", err)
+	}
+}
+
+func TestLoadTaskConfigDetectsIncludeCycle(t *testing.T) {
+	temp := t.TempDir()
+	a := filepath.Join(temp, "a.yaml")
+	b := filepath.Join(temp, "b.yaml")
+	writeConfigFile(t, a, "includes: [b.yaml]\n")
+	writeConfigFile(t, b, "includes: [a.yaml]\n")
+
+	_, err := LoadTaskConfig(a)
+	if err == nil {
+		t.Fatalf("expected include cycle error")
+	}
+	if want := "include cycle: " + a + " -> " + b + " -> " + a; err.Error() != want {
+		t.Fatalf("expected %q, got %q", want, err.Error())
+	}
+}
+
+func TestLoadTaskConfigMergesDiamondIncludeOnce(t *testing.T) {
+	temp := t.TempDir()
+	shared := filepath.Join(temp, "shared", "shared.yaml")
+	writeConfigFile(t, shared, `
+repositories:
+  - url: https://example.com/
+    files:
+      - file_name: shared.txt
+        out_dir: .
+`)
+	writeConfigFile(t, filepath.Join(temp, "b.yaml"), "includes: [shared/shared.yaml]\n")
+	writeConfigFile(t, filepath.Join(temp, "c.yaml"), "includes: [shared/shared.yaml]\n")
+	root := filepath.Join(temp, "vorbere.yaml")
+	writeConfigFile(t, root, `
+includes: [b.yaml, c.yaml]
+repositories:
+  - url: https://example.com/
+    files:
+      - file_name: root.txt
+        out_dir: .
+`)
+
+	cfg, err := LoadTaskConfig(root)
+	if err != nil {
+		t.Fatalf("LoadTaskConfig returned error: %v", err)
+	}
+	if len(cfg.Repositories) != 2 || cfg.Repositories[0].Origin != shared || cfg.Repositories[1].Origin != "" {
+		t.Fatalf("expected the shared repository to be merged once, got %+v", cfg.Repositories)
+	}
+	syncCfg, err := ResolveSyncConfig(cfg, root)
+	if err != nil {
+		t.Fatalf("ResolveSyncConfig returned error: %v", err)
+	}
+	if len(syncCfg.Files) != 2 {
+		t.Fatalf("expected one target per declared file, got %+v", syncCfg.Files)
+	}
+}
+
+func TestLoadTaskConfigResolvesIncludedLocalURLs(t *testing.T) {
+	temp := t.TempDir()
+	included := filepath.Join(temp, "shared", "tools.yaml")
+	writeConfigFile(t, included, `
+repositories:
+  - url: files
+    files:
+      - file_name: a.txt
+        out_dir: .
+  - type: git
+    url: ../repo
+    files:
+      - file_name: b.txt
+        out_dir: .
+  - type: git
+    url: https://example.com/repo.git
+    files:
+      - file_name: c.txt
+        out_dir: .
+`)
+	root := filepath.Join(temp, "vorbere.yaml")
+	writeConfigFile(t, root, `
+includes: [shared/tools.yaml]
+repositories:
+  - url: files
+    files:
+      - file_name: d.txt
+        out_dir: .
+`)
+
+	cfg, err := LoadTaskConfig(root)
+	if err != nil {
+		t.Fatalf("LoadTaskConfig returned error: %v", err)
+	}
+	want := []string{filepath.Join(temp, "shared", "files"), filepath.Join(temp, "repo"), "https://example.com/repo.git", "files"}
+	for index, repo := range cfg.Repositories {
+		if repo.URL != want[index] {
+			t.Fatalf("expected repositories[%d].url %q, got %q", index, want[index], repo.URL)
+		}
+	}
+}
+
+func TestLoadTaskConfigErrorsNameIncludedFile(t *testing.T) {
+	temp := t.TempDir()
+	included := filepath.Join(temp, "tasks.yaml")
+	writeConfigFile(t, included, `
+tasks:
+  broken:
+    run: echo ${{ .vars.MISSING }}
+`)
+	root := filepath.Join(temp, "vorbere.yaml")
+	writeConfigFile(t, root, "includes: [tasks.yaml]\n")
+
+	cfg, err := LoadTaskConfig(root)
+	if err != nil {
+		t.Fatalf("LoadTaskConfig returned error: %v", err)
+	}
+	_, err = ExpandTask(cfg, "broken", nil)
+	if err == nil || !strings.HasPrefix(err.Error(), included+": ") || !strings.Contains(err.Error(), "tasks.broken.run") {
+		t.Fatalf("expected error naming %s, got %v", included, err)
+	}
+
+	writeConfigFile(t, included, "tasks:\n  broken:\n    unknown: true\n")
+	_, err = LoadTaskConfig(root)
+	if err == nil || !strings.HasPrefix(err.Error(), included+": ") {
+		t.Fatalf("expected decode error naming %s, got %v", included, err)
+	}
+}
+
+func TestIncludedRepositoryErrorsUseDeclaredIndex(t *testing.T) {
+	temp := t.TempDir()
+	writeConfigFile(t, filepath.Join(temp, "base.yaml"), `
+repositories:
+  - url: https://example.com/base/
+    files:
+      - file_name: base.txt
+        out_dir: .
+`)
+	included := filepath.Join(temp, "tools.yaml")
+	writeConfigFile(t, included, `
+repositories:
+  - url: https://example.com/tools/
+    files:
+      - file_name: ${{ .vars.MISSING }}
+        out_dir: .
+`)
+	root := filepath.Join(temp, "vorbere.yaml")
+	writeConfigFile(t, root, "includes: [base.yaml, tools.yaml]\n")
+
+	_, err := LoadTaskConfig(root)
+	if want := included + ": repositories[0].files[0].file_name references undefined var(s): MISSING"; err == nil || err.Error() != want {
+		t.Fatalf("expected %q, got %v", want, err)
+	}
+
+	writeConfigFile(t, included, `
+repositories:
+  - url: https://example.com/tools/
+    files:
+      - file_name: tool.txt
+`)
+	taskCfg, err := LoadTaskConfig(root)
+	if err != nil {
+		t.Fatalf("LoadTaskConfig returned error: %v", err)
+	}
+	_, err = ResolveSyncConfig(taskCfg, root)
+	if want := included + ": repositories[0].files[0].out_dir is required"; err == nil || err.Error() != want {
+		t.Fatalf("expected %q, got %v", want, err)
+	}
+
+	writeConfigFile(t, root, `
+includes: [base.yaml]
+repositories:
+  - url: https://example.com/root/
+    files:
+      - file_name: root.txt
+`)
+	taskCfg, err = LoadTaskConfig(root)
+	if err != nil {
+		t.Fatalf("LoadTaskConfig returned error: %v", err)
+	}
+	_, err = ResolveSyncConfig(taskCfg, root)
+	if want := "repositories[0].files[0].out_dir is required"; err == nil || err.Error() != want {
+		t.Fatalf("expected %q, got %v", want, err)
+	}
+
+	taskCfg.Repositories[1].Files[0].OutDir = "."
+	syncCfg, err := ResolveSyncConfig(taskCfg, root)
+	if err != nil {
+		t.Fatalf("ResolveSyncConfig returned error: %v", err)
+	}
+	if got := syncCfg.Files[1]; got.FieldPath() != "repositories[0].files[0]" || got.Origin != "" {
+		t.Fatalf("expected the root entry at its declared index, got %q from %q", got.FieldPath(), got.Origin)
+	}
+}
+
+func TestLoadTaskConfigVerifiesRemoteIncludeDigest(t *testing.T) {
+	const included = "tasks:\n  remote:\n    run: echo remote\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(included))
+	}))
+	defer server.Close()
+
+	temp := t.TempDir()
+	root := filepath.Join(temp, "vorbere.yaml")
+	writeConfigFile(t, root, "includes:\n  - path: "+server.URL+"/tasks.yaml\n    digest: sha256:"+shared.SHA256Hex([]byte(included))+"\n")
+	cfg, err := LoadTaskConfig(root)
+	if err != nil {
+		t.Fatalf("LoadTaskConfig returned error: %v", err)
+	}
+	if cfg.Tasks["remote"].Origin != server.URL+"/tasks.yaml" {
+		t.Fatalf("unexpected origin: %q", cfg.Tasks["remote"].Origin)
+	}
+
+	writeConfigFile(t, root, "includes:\n  - path: "+server.URL+"/tasks.yaml\n    digest: sha256:"+strings.Repeat("0", 64)+"\n")
+	_, err = LoadTaskConfig(root)
+	if err == nil || !strings.Contains(err.Error(), server.URL+"/tasks.yaml: checksum mismatch") {
+		t.Fatalf("expected checksum mismatch naming the include, got %v", err)
+	}
+}
+
+func TestResolveIncludeLocation(t *testing.T) {
+	got, err := resolveIncludeLocation("https://example.com/configs/vorbere.yaml", "../shared/lint.yaml")
+	if err != nil || got != "https://example.com/shared/lint.yaml" {
+		t.Fatalf("unexpected remote resolution %q: %v", got, err)
+	}
+	got, err = resolveIncludeLocation("/work/vorbere.yaml", "ci/tasks.yaml")
+	if err != nil || got != filepath.Join("/work", "ci", "tasks.yaml") {
+		t.Fatalf("unexpected local resolution %q: %v", got, err)
+	}
+}
@@ -1,46 +1,118 @@
 package manifest
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 
 	pkgmanifest "github.com/pirakansa/vorbere/pkg/manifest"
-	"gopkg.in/yaml.v3"
 )
 
+// LoadOptions customizes LoadTaskConfigWithOptions.
+type LoadOptions struct {
+	// Vars override vars from the manifest and VORBERE_VAR_* variables.
+	Vars map[string]string
+}
+
+// LoadTaskConfig reads the config at path, merges its includes, resolves
+// vars, expands vars templates and checks depends_on references and cycles.
 func LoadTaskConfig(path string) (*TaskConfig, error) {
-	content, err := readConfig(path)
+	return LoadTaskConfigWithOptions(path, LoadOptions{})
+}
+
+func LoadTaskConfigWithOptions(path string, opts LoadOptions) (*TaskConfig, error) {
+	cfg, err := loadTaskConfig(path, opts)
 	if err != nil {
 		return nil, err
 	}
+	if err := pkgmanifest.ValidateTaskGraph(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
 
-	var cfg TaskConfig
-	decoder := yaml.NewDecoder(bytes.NewReader(content))
-	decoder.KnownFields(true)
-	if err := decoder.Decode(&cfg); err != nil {
+// loadTaskConfig is LoadTaskConfigWithOptions without validation.
+func loadTaskConfig(path string, opts LoadOptions) (*TaskConfig, error) {
+	cfg, varsOpts, expandOpts, err := readTaskConfig(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := pkgmanifest.ResolveVars(cfg, varsOpts); err != nil {
 		return nil, err
 	}
-	pkgmanifest.NormalizeTaskConfig(&cfg)
-	if err := pkgmanifest.ExpandTaskConfigTemplates(&cfg); err != nil {
+	if err := pkgmanifest.ExpandTaskConfigTemplatesWithOptions(cfg, expandOpts); err != nil {
 		return nil, err
 	}
-	return &cfg, nil
+	if err := resolveIncludedRepositoryURLs(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readTaskConfig reads the config at path and merges its includes. The
+// returned options resolve its vars and expand its templates.
+func readTaskConfig(path string, opts LoadOptions) (*TaskConfig, pkgmanifest.ResolveVarsOptions, pkgmanifest.ExpandOptions, error) {
+	var varsOpts pkgmanifest.ResolveVarsOptions
+	var expandOpts pkgmanifest.ExpandOptions
+	if !IsRemoteConfigLocation(path) {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, varsOpts, expandOpts, err
+		}
+		path = abs
+	}
+	loader := &includeLoader{root: path}
+	cfg, err := loader.load(path, "")
+	if err != nil {
+		return nil, varsOpts, expandOpts, err
+	}
+	pkgmanifest.NormalizeTaskConfig(cfg)
+	dir := filepath.Dir(path)
+	if IsRemoteConfigLocation(path) {
+		if dir, err = os.Getwd(); err != nil {
+			return nil, varsOpts, expandOpts, err
+		}
+	}
+	varsOpts = pkgmanifest.ResolveVarsOptions{Overrides: opts.Vars, Dir: dir}
+	expandOpts = pkgmanifest.ExpandOptions{Dir: dir, Remote: IsRemoteConfigLocation(path), DeferTasks: true}
+	return cfg, varsOpts, expandOpts, nil
 }
 
 func IsRemoteConfigLocation(value string) bool {
 	return pkgmanifest.IsRemoteConfigLocation(value)
 }
 
+// ExpandTask expands the run, env and cwd of a loaded task, which are left
+// unexpanded at load, with args exposed as .args.
+func ExpandTask(cfg *TaskConfig, name string, args []string) (TaskDef, error) {
+	return pkgmanifest.ExpandTask(cfg, name, args)
+}
+
 func ResolveSyncConfig(taskCfg *TaskConfig, taskConfigPath string) (*SyncConfig, error) {
+	return ResolveFilteredSyncConfig(taskCfg, taskConfigPath, SyncFilter{})
+}
+
+// ResolveFilteredSyncConfig builds the sync config keeping only the
+// repository files selected by filter.
+func ResolveFilteredSyncConfig(taskCfg *TaskConfig, taskConfigPath string, filter SyncFilter) (*SyncConfig, error) {
 	if err := pkgmanifest.ValidateTaskConfig(taskCfg); err != nil {
 		return nil, err
 	}
-	return pkgmanifest.BuildSyncConfigWithOptions(taskCfg, pkgmanifest.BuildSyncConfigOptions{
-		ExpandRepositoryHeaderEnv: !IsRemoteConfigLocation(taskConfigPath),
-	})
+	return pkgmanifest.BuildSyncConfigWithOptions(taskCfg, syncBuildOptions(taskConfigPath, filter))
+}
+
+// syncBuildOptions returns the build options of the config loaded from
+// taskConfigPath. Remote configs neither expand header env vars nor use
+// local sources.
+func syncBuildOptions(taskConfigPath string, filter SyncFilter) pkgmanifest.BuildSyncConfigOptions {
+	remoteConfig := IsRemoteConfigLocation(taskConfigPath)
+	return pkgmanifest.BuildSyncConfigOptions{
+		ExpandRepositoryHeaderEnv: !remoteConfig,
+		RejectLocalSources:        remoteConfig,
+		Filter:                    filter,
+	}
 }
 
 func ValidateSyncConfig(cfg *SyncConfig) error {
@@ -222,7 +222,10 @@ tasks:
 	if err != nil {
 		t.Fatalf("LoadTaskConfig returned error: %v", err)
 	}
-	task := cfg.Tasks["print"]
+	if got := cfg.Tasks["print"].Run; got != "echo ${{ .vars.TOOL_VERSION }}" {
+		t.Fatalf("expected run to be expanded when the task runs, got %q", got)
+	}
+	task := mustExpandTask(t, cfg, "print", nil)
 	if got, want := task.Run, "echo 1.2.3"; got != want {
 		t.Fatalf("unexpected run: got=%q want=%q", got, want)
 	}
@@ -234,6 +237,15 @@ tasks:
 	}
 }
 
+func mustExpandTask(t *testing.T, cfg *TaskConfig, name string, args []string) TaskDef {
+	t.Helper()
+	task, err := ExpandTask(cfg, name, args)
+	if err != nil {
+		t.Fatalf("ExpandTask(%q) returned error: %v", name, err)
+	}
+	return task
+}
+
 func TestLoadTaskConfigRejectsUndefinedVars(t *testing.T) {
 	temp := t.TempDir()
 	configPath := filepath.Join(temp, "vorbere.yaml")
@@ -246,7 +258,11 @@ tasks:
 		t.Fatalf("write config: %v", err)
 	}
 
-	_, err := LoadTaskConfig(configPath)
+	cfg, err := LoadTaskConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTaskConfig returned error: %v", err)
+	}
+	_, err = ExpandTask(cfg, "print", nil)
 	if err == nil {
 		t.Fatalf("expected undefined var error")
 	}
@@ -334,3 +350,79 @@ type loadTestRoundTripFunc func(*http.Request) (*http.Response, error)
 func (f loadTestRoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
 	return f(req)
 }
+
+func TestLoadTaskConfigRunsShellVarsInConfigDir(t *testing.T) {
+	temp := t.TempDir()
+	writeConfigFile(t, filepath.Join(temp, "VERSION"), "1.4.0\n")
+	configPath := filepath.Join(temp, "vorbere.yaml")
+	writeConfigFile(t, configPath, `
+vars:
+  VERSION:
+    sh: cat VERSION
+tasks:
+  build:
+    run: go build -ldflags "-X main.version=${{ .vars.VERSION }}"
+`)
+
+	cfg, err := LoadTaskConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTaskConfig returned error: %v", err)
+	}
+	if got := mustExpandTask(t, cfg, "build", nil).Run; got != `go build -ldflags "-X main.version=1.4.0"` {
+		t.Fatalf("unexpected run: %q", got)
+	}
+
+	cfg, err = LoadTaskConfigWithOptions(configPath, LoadOptions{Vars: map[string]string{"VERSION": "dev"}})
+	if err != nil {
+		t.Fatalf("LoadTaskConfigWithOptions returned error: %v", err)
+	}
+	if got := mustExpandTask(t, cfg, "build", nil).Run; got != `go build -ldflags "-X main.version=dev"` {
+		t.Fatalf("expected override to skip sh var, got %q", got)
+	}
+}
+
+func TestLoadTaskConfigRejectsUndefinedDependencyAndCycle(t *testing.T) {
+	temp := t.TempDir()
+	path := filepath.Join(temp, "vorbere.yaml")
+	writeConfigFile(t, path, "version: 1\ntasks:\n  ci:\n    depends_on: [build]\n  docs:\n    desc: no run\n")
+	if _, err := LoadTaskConfig(path); err == nil || err.Error() != `tasks.ci.depends_on[0] references undefined task "build"` {
+		t.Fatalf("expected undefined dependency error, got %v", err)
+	}
+
+	writeConfigFile(t, path, "version: 1\ntasks:\n  ci:\n    depends_on: [build]\n  build:\n    run: go build\n    depends_on: [ci]\n")
+	if _, err := LoadTaskConfig(path); err == nil || err.Error() != "tasks.build.depends_on dependency cycle: build -> ci -> build" {
+		t.Fatalf("expected dependency cycle error, got %v", err)
+	}
+}
+
+func TestResolveSyncConfigDoesNotReexpandRemoteConfig(t *testing.T) {
+	t.Setenv("SECRET_TOKEN", "hunter2")
+	oldClient := http.DefaultClient
+	t.Cleanup(func() {
+		http.DefaultClient = oldClient
+	})
+	http.DefaultClient = &http.Client{
+		Transport: loadTestRoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			body := "version: 1\nrepositories:\n  - url: 'https://example.com/leak/${{ \"${{\" }} .env.SECRET_TOKEN }}/'\n    files:\n      - file_name: a.txt\n        out_dir: .\n"
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Header:     make(http.Header),
+			}, nil
+		}),
+	}
+
+	location := "https://example.com/vorbere.yaml"
+	cfg, err := LoadTaskConfig(location)
+	if err != nil {
+		t.Fatalf("LoadTaskConfig returned error: %v", err)
+	}
+	resolved, err := ResolveSyncConfig(cfg, location)
+	if err != nil {
+		t.Fatalf("ResolveSyncConfig returned error: %v", err)
+	}
+	src := resolved.Sources[resolved.Files[0].Source]
+	if src.URL != "https://example.com/leak/${{ .env.SECRET_TOKEN }}/a.txt" {
+		t.Fatalf("expected the literal ${{ to be kept, got %q", src.URL)
+	}
+}
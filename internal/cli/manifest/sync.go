@@ -4,17 +4,21 @@ import (
 	"errors"
 	"path/filepath"
 	"time"
+
+	"github.com/pirakansa/vorbere/internal/cli/shared"
 )
 
 const (
 	outcomeCreated   = "created"
 	outcomeUpdated   = "updated"
 	outcomeUnchanged = "unchanged"
+	outcomeConflict  = "conflict"
 )
 
 // SyncOptions controls sync behavior.
 type SyncOptions struct {
 	RootDir   string
+	CacheDir  string
 	Overwrite bool
 	DryRun    bool
 	Now       func() time.Time
@@ -23,17 +27,46 @@ type SyncOptions struct {
 
 // SyncFileProgress describes one processed file during sync.
 type SyncFileProgress struct {
-	Index   int
-	Total   int
-	Path    string
-	Outcome string
+	Index int `json:"index"`
+	Total int `json:"total"`
+	// Field is the entry path in the manifest that declares it, which is
+	// Origin for an included entry.
+	Field    string `json:"field"`
+	Origin   string `json:"origin,omitempty"`
+	Path     string `json:"path"`
+	Outcome  string `json:"outcome"`
+	Revision string `json:"revision,omitempty"`
+	// DownloadDigest is the sha256 of the downloaded artifact; empty when the
+	// source resolves to several files (for example a git directory).
+	DownloadDigest string `json:"download_digest,omitempty"`
+	DownloadBytes  int64  `json:"download_bytes"`
+	// OutputDigest is the sha256 of the single written file; empty when the
+	// rule writes several files.
+	OutputDigest string `json:"output_digest,omitempty"`
+	OutputBytes  int64  `json:"output_bytes"`
 }
 
 // SyncResult describes sync outcome.
 type SyncResult struct {
-	Created   int
-	Updated   int
-	Unchanged int
+	Created   int                `json:"created"`
+	Updated   int                `json:"updated"`
+	Unchanged int                `json:"unchanged"`
+	Conflicts int                `json:"conflicts"`
+	Files     []SyncFileProgress `json:"files"`
+}
+
+// ConfigError reports a sync failure caused by the manifest rather than by
+// the upstream source, for example a checksum file without the expected entry.
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
 }
 
 func Sync(cfg *SyncConfig, opts SyncOptions) (*SyncResult, error) {
@@ -51,35 +84,64 @@ func Sync(cfg *SyncConfig, opts SyncOptions) (*SyncResult, error) {
 
 	res := &SyncResult{}
 	total := len(rules)
+	fetcher := newSourceFetcher(opts.RootDir, opts.CacheDir)
 	for index, rule := range rules {
 		src := cfg.Sources[rule.Source]
-		artifact, err := download(src)
+		fetched, err := fetcher.fetch(src)
 		if err != nil {
 			return nil, err
 		}
-		if err := verifyChecksum(artifact, rule.DownloadChecksum); err != nil {
+		if err := verifyChecksum(fetched.content, rule.DownloadChecksum); err != nil {
 			return nil, err
 		}
+		if err := fetcher.verifyPublishedChecksums(cfg, rule, fetched); err != nil {
+			return nil, err
+		}
+		if rule.Signature != nil {
+			if err := fetcher.verifySignature(fetched.content, rule.Signature, cfg.Sources[rule.Signature.Source]); err != nil {
+				return nil, err
+			}
+		}
 
 		target := resolveTargetPath(opts.RootDir, rule.Path)
-		outcome, err := applyProcessedRule(target, artifact, rule, opts)
+		output, err := applyFetchedArtifact(target, fetched, rule, opts)
 		if err != nil {
 			return nil, err
 		}
 
-		recordOutcome(res, outcome)
+		recordOutcome(res, output.outcome)
+		progress := SyncFileProgress{
+			Index:        index + 1,
+			Total:        total,
+			Field:        rule.FieldPath(),
+			Origin:       rule.Origin,
+			Path:         target,
+			Outcome:      output.outcome,
+			Revision:     fetched.revision,
+			OutputDigest: output.digest,
+			OutputBytes:  output.bytes,
+		}
+		if fetched.entries == nil {
+			progress.DownloadDigest = DigestAlgorithmSHA256 + ":" + shared.SHA256Hex(fetched.content)
+			progress.DownloadBytes = int64(len(fetched.content))
+		} else {
+			progress.DownloadBytes = output.bytes
+		}
+		res.Files = append(res.Files, progress)
 		if opts.OnFile != nil {
-			opts.OnFile(SyncFileProgress{
-				Index:   index + 1,
-				Total:   total,
-				Path:    target,
-				Outcome: outcome,
-			})
+			opts.OnFile(progress)
 		}
 	}
 	return res, nil
 }
 
+func applyFetchedArtifact(target string, fetched *fetchedArtifact, rule FileRule, opts SyncOptions) (appliedOutput, error) {
+	if fetched.entries != nil {
+		return applyMultiOutput(target, fetched.entries, rule, opts)
+	}
+	return applyProcessedRule(target, fetched.content, rule, opts)
+}
+
 func resolveTargetPath(rootDir, path string) string {
 	if filepath.IsAbs(path) {
 		return path
@@ -95,5 +157,7 @@ func recordOutcome(res *SyncResult, outcome string) {
 		res.Updated++
 	case outcomeUnchanged:
 		res.Unchanged++
+	case outcomeConflict:
+		res.Conflicts++
 	}
 }
@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/klauspost/compress/zstd"
+	"github.com/pirakansa/vorbere/internal/cli/shared"
 	"github.com/ulikunitz/xz"
 )
 
@@ -34,12 +35,21 @@ type archiveEntry struct {
 type archiveApplySummary struct {
 	created bool
 	updated bool
+	bytes   int64
 }
 
-func applyProcessedRule(targetPath string, artifact []byte, rule FileRule, opts SyncOptions) (string, error) {
+// appliedOutput summarizes what one file rule resolved to on disk.
+type appliedOutput struct {
+	outcome string
+	// digest is the sha256 of the single output; empty for multi-output.
+	digest string
+	bytes  int64
+}
+
+func applyProcessedRule(targetPath string, artifact []byte, rule FileRule, opts SyncOptions) (appliedOutput, error) {
 	processed, err := processArtifact(artifact, rule)
 	if err != nil {
-		return "", err
+		return appliedOutput{}, err
 	}
 	if processed.single != nil {
 		return applySingleOutput(targetPath, processed.single, rule, opts)
@@ -47,20 +57,48 @@ func applyProcessedRule(targetPath string, artifact []byte, rule FileRule, opts
 	return applyMultiOutput(targetPath, processed.entries, rule, opts)
 }
 
-func applySingleOutput(targetPath string, file *processedFile, rule FileRule, opts SyncOptions) (string, error) {
+func applySingleOutput(targetPath string, file *processedFile, rule FileRule, opts SyncOptions) (appliedOutput, error) {
 	if err := verifyChecksum(file.content, rule.OutputChecksum); err != nil {
-		return "", err
+		return appliedOutput{}, err
+	}
+	content := file.content
+	if rule.Render {
+		rendered, err := renderTemplate(content, rule, opts.RootDir)
+		if err != nil {
+			return appliedOutput{}, err
+		}
+		content = rendered
 	}
 	modeValue := rule.Mode
 	if modeValue == "" && file.mode != 0 {
 		modeValue = fmt.Sprintf("%04o", uint32(file.mode.Perm()))
 	}
-	return applyRule(targetPath, file.content, modeValue, opts)
+	if rule.Block != nil {
+		return applyManagedBlock(targetPath, content, modeValue, rule.Block, opts)
+	}
+	switch rule.Merge {
+	case MergeThreeWay:
+		return applyThreeWayMerge(targetPath, content, modeValue, opts)
+	case MergeJSON, MergeYAML, MergeTOML:
+		return applyStructuredMerge(targetPath, content, modeValue, rule, opts)
+	}
+	outcome, err := applyRule(targetPath, content, modeValue, opts)
+	if err != nil {
+		return appliedOutput{}, err
+	}
+	return appliedOutput{
+		outcome: outcome,
+		digest:  DigestAlgorithmSHA256 + ":" + shared.SHA256Hex(content),
+		bytes:   int64(len(content)),
+	}, nil
 }
 
-func applyMultiOutput(targetRoot string, entries []archiveEntry, rule FileRule, opts SyncOptions) (string, error) {
+func applyMultiOutput(targetRoot string, entries []archiveEntry, rule FileRule, opts SyncOptions) (appliedOutput, error) {
 	if rule.OutputChecksum != "" {
-		return "", errors.New("output_digest cannot be used when extract resolves to multiple files")
+		return appliedOutput{}, errors.New("output_digest cannot be used when extract resolves to multiple files")
+	}
+	if rule.Render {
+		return appliedOutput{}, errors.New("render cannot be used when extract resolves to multiple files")
 	}
 	return applyArchiveEntries(targetRoot, entries, opts)
 }
@@ -152,7 +190,10 @@ func readArchiveEntries(content []byte, encoding string) ([]archiveEntry, error)
 	if closer != nil {
 		defer closer.Close()
 	}
+	return readTarEntries(reader)
+}
 
+func readTarEntries(reader io.Reader) ([]archiveEntry, error) {
 	tarReader := tar.NewReader(reader)
 	var entries []archiveEntry
 	for {
@@ -163,7 +204,7 @@ func readArchiveEntries(content []byte, encoding string) ([]archiveEntry, error)
 		if err != nil {
 			return nil, err
 		}
-		if !header.FileInfo().Mode().IsRegular() {
+		if header.Typeflag == tar.TypeXGlobalHeader || !header.FileInfo().Mode().IsRegular() {
 			continue
 		}
 
@@ -216,13 +257,13 @@ func normalizeArchiveEntryName(value string) (string, error) {
 	return filepath.ToSlash(cleaned), nil
 }
 
-func applyArchiveEntries(targetRoot string, entries []archiveEntry, opts SyncOptions) (string, error) {
+func applyArchiveEntries(targetRoot string, entries []archiveEntry, opts SyncOptions) (appliedOutput, error) {
 	summary := archiveApplySummary{}
 
 	for _, entry := range entries {
 		targetPath, err := resolveArchiveTargetPath(targetRoot, entry.path)
 		if err != nil {
-			return "", err
+			return appliedOutput{}, err
 		}
 
 		modeValue := "0644"
@@ -231,12 +272,13 @@ func applyArchiveEntries(targetRoot string, entries []archiveEntry, opts SyncOpt
 		}
 		outcome, err := applyRule(targetPath, entry.body, modeValue, opts)
 		if err != nil {
-			return "", err
+			return appliedOutput{}, err
 		}
 		updateArchiveApplySummary(&summary, outcome)
+		summary.bytes += int64(len(entry.body))
 	}
 
-	return summary.outcome(), nil
+	return appliedOutput{outcome: summary.outcome(), bytes: summary.bytes}, nil
 }
 
 func updateArchiveApplySummary(summary *archiveApplySummary, outcome string) {
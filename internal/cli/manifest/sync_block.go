@@ -0,0 +1,75 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pirakansa/vorbere/internal/cli/shared"
+)
+
+// applyManagedBlock replaces the lines between the block markers in
+// targetPath with content, appending the block when it is missing. Lines
+// outside the markers are kept as they are.
+func applyManagedBlock(targetPath string, content []byte, fileMode string, block *BlockRule, opts SyncOptions) (appliedOutput, error) {
+	current, err := os.ReadFile(targetPath)
+	if err != nil && !os.IsNotExist(err) {
+		return appliedOutput{}, err
+	}
+	incoming, err := replaceManagedBlock(current, content, block)
+	if err != nil {
+		return appliedOutput{}, fmt.Errorf("%s: %w", targetPath, err)
+	}
+	outcome, err := applyRule(targetPath, incoming, fileMode, opts)
+	if err != nil {
+		return appliedOutput{}, err
+	}
+	return appliedOutput{
+		outcome: outcome,
+		digest:  DigestAlgorithmSHA256 + ":" + shared.SHA256Hex(content),
+		bytes:   int64(len(content)),
+	}, nil
+}
+
+func replaceManagedBlock(current, content []byte, block *BlockRule) ([]byte, error) {
+	var rendered bytes.Buffer
+	rendered.WriteString(block.Begin + "\n")
+	rendered.Write(content)
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		rendered.WriteByte('\n')
+	}
+	rendered.WriteString(block.End + "\n")
+
+	lines := splitLines(current)
+	begin, end := -1, -1
+	for index, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == block.Begin && begin < 0:
+			begin = index
+		case trimmed == block.End && begin >= 0:
+			end = index
+		}
+		if end >= 0 {
+			break
+		}
+	}
+	if begin >= 0 && end < 0 {
+		return nil, fmt.Errorf("managed block %q has no end marker %q", block.Begin, block.End)
+	}
+
+	var out bytes.Buffer
+	if begin < 0 {
+		out.Write(current)
+		if len(current) > 0 && !bytes.HasSuffix(current, []byte("\n")) {
+			out.WriteByte('\n')
+		}
+		out.Write(rendered.Bytes())
+		return out.Bytes(), nil
+	}
+	writeLines(&out, lines[:begin], false)
+	out.Write(rendered.Bytes())
+	writeLines(&out, lines[end+1:], false)
+	return out.Bytes(), nil
+}
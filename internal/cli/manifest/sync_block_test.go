@@ -0,0 +1,86 @@
+package manifest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplaceManagedBlock(t *testing.T) {
+	block := &BlockRule{Begin: "# >>> vorbere:org", End: "# <<< vorbere:org"}
+	cases := []struct {
+		name    string
+		current string
+		want    string
+	}{
+		{name: "missing file", current: "", want: "# >>> vorbere:org\nnode_modules/\n# <<< vorbere:org\n"},
+		{name: "append", current: "local/", want: "local/\n# >>> vorbere:org\nnode_modules/\n# <<< vorbere:org\n"},
+		{
+			name:    "replace",
+			current: "top\n# >>> vorbere:org\nold/\n# <<< vorbere:org\nbottom\n",
+			want:    "top\n# >>> vorbere:org\nnode_modules/\n# <<< vorbere:org\nbottom\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := replaceManagedBlock([]byte(tc.current), []byte("node_modules/"), block)
+			if err != nil {
+				t.Fatalf("replaceManagedBlock failed: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("unexpected content:\n got: %q\nwant: %q", got, tc.want)
+			}
+		})
+	}
+
+	_, err := replaceManagedBlock([]byte("# >>> vorbere:org\nold\n"), []byte("new\n"), block)
+	if err == nil || !strings.Contains(err.Error(), "has no end marker") {
+		t.Fatalf("expected missing end marker error, got %v", err)
+	}
+}
+
+func TestSyncManagedBlockKeepsSurroundingLines(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("*.log\n.DS_Store\n"))
+	}))
+	defer server.Close()
+
+	temp := t.TempDir()
+	target := filepath.Join(temp, ".gitignore")
+	if err := os.WriteFile(target, []byte("/dist\n"), 0o644); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{"src": {URL: server.URL}},
+		Files: []FileRule{{
+			Source: "src",
+			Path:   ".gitignore",
+			Block:  &BlockRule{Begin: "# >>> vorbere:org", End: "# <<< vorbere:org"},
+		}},
+	}
+	opts := SyncOptions{RootDir: temp, Overwrite: true}
+	res, err := Sync(cfg, opts)
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if res.Updated != 1 {
+		t.Fatalf("expected updated=1, got %+v", res)
+	}
+
+	edited := "# >>> vorbere:org\n*.log\n.DS_Store\n# <<< vorbere:org\n/dist\n/coverage\n"
+	if err := os.WriteFile(target, []byte(edited), 0o644); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	res, err = Sync(cfg, opts)
+	if err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	got, _ := os.ReadFile(target)
+	if res.Unchanged != 1 || string(got) != edited {
+		t.Fatalf("expected block to be unchanged and local lines kept, got %+v %q", res, got)
+	}
+}
@@ -0,0 +1,144 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// bsdChecksumPattern matches BSD style lines such as "SHA256 (name) = <hex>".
+var bsdChecksumPattern = regexp.MustCompile(`^([A-Za-z0-9-]+) ?\((.+)\) ?= ?([0-9A-Fa-f]+)$`)
+
+// parseChecksumFile parses GNU coreutils ("<hex>  <name>", as produced by
+// sha256sum and release tooling) and BSD ("SHA256 (<name>) = <hex>") lines.
+// The result maps file names to "<algorithm>:<hex>".
+func parseChecksumFile(content []byte) (map[string]string, error) {
+	checksums := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, spec, err := parseChecksumLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		checksums[name] = spec
+		if base := path.Base(name); base != name {
+			if _, exists := checksums[base]; !exists {
+				checksums[base] = spec
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return checksums, nil
+}
+
+func parseChecksumLine(line string) (string, string, error) {
+	if matches := bsdChecksumPattern.FindStringSubmatch(line); matches != nil {
+		algorithm := strings.ToLower(strings.ReplaceAll(matches[1], "-", ""))
+		digest := strings.ToLower(matches[3])
+		if _, err := computeDigest(nil, algorithm); err != nil {
+			return "", "", err
+		}
+		return matches[2], algorithm + ":" + digest, nil
+	}
+	// A leading backslash marks GNU lines whose file name contains escapes.
+	digest, name, ok := strings.Cut(strings.TrimPrefix(line, "\\"), " ")
+	name = strings.TrimPrefix(strings.TrimSpace(name), "*")
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("invalid checksum entry")
+	}
+	spec, err := checksumSpecFromHex(digest)
+	if err != nil {
+		return "", "", err
+	}
+	return name, spec, nil
+}
+
+// resolveChecksumFile fetches and parses a download_digest_from checksum
+// file once per sync run.
+func (f *sourceFetcher) resolveChecksumFile(src Source) (map[string]string, error) {
+	key, err := json.Marshal(src)
+	if err != nil {
+		return nil, err
+	}
+	if checksums, ok := f.checksumFiles[string(key)]; ok {
+		return checksums, nil
+	}
+	fetched, err := f.fetch(src)
+	if err != nil {
+		return nil, fmt.Errorf("download_digest_from %s: %w", src.URL, err)
+	}
+	checksums, err := parseChecksumFile(fetched.content)
+	if err != nil {
+		return nil, fmt.Errorf("download_digest_from %s: %w", src.URL, err)
+	}
+	f.checksumFiles[string(key)] = checksums
+	return checksums, nil
+}
+
+// verifyPublishedChecksums verifies the digests published upstream for a
+// fetched artifact: the source's own checksum and download_digest_from.
+func (f *sourceFetcher) verifyPublishedChecksums(cfg *SyncConfig, rule FileRule, fetched *fetchedArtifact) error {
+	if err := verifyChecksum(fetched.content, fetched.checksum); err != nil {
+		return err
+	}
+	if rule.DownloadChecksumFrom == "" {
+		return nil
+	}
+	checksumSource := cfg.Sources[rule.DownloadChecksumFrom]
+	checksums, err := f.resolveChecksumFile(checksumSource)
+	if err != nil {
+		return err
+	}
+	checksum, err := lookupChecksum(checksums, fetched.name, checksumSource.URL)
+	if err != nil {
+		return err
+	}
+	return verifyChecksum(fetched.content, checksum)
+}
+
+// lookupChecksum returns the entry for name, falling back to its base name.
+// A missing entry is reported as a ConfigError.
+func lookupChecksum(checksums map[string]string, name, location string) (string, error) {
+	if checksum, ok := checksums[name]; ok {
+		return checksum, nil
+	}
+	if checksum, ok := checksums[path.Base(name)]; ok {
+		return checksum, nil
+	}
+	return "", &ConfigError{Err: fmt.Errorf("download_digest_from %s has no entry for %q", location, name)}
+}
+
+// checksumSpecFromHex infers the digest algorithm from the hex length.
+func checksumSpecFromHex(value string) (string, error) {
+	digest := strings.ToLower(strings.TrimSpace(value))
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", fmt.Errorf("invalid checksum hex %q", value)
+	}
+	switch len(digest) {
+	case 32:
+		return DigestAlgorithmMD5 + ":" + digest, nil
+	case 40:
+		return DigestAlgorithmSHA1 + ":" + digest, nil
+	case 64:
+		return DigestAlgorithmSHA256 + ":" + digest, nil
+	case 96:
+		return DigestAlgorithmSHA384 + ":" + digest, nil
+	case 128:
+		return DigestAlgorithmSHA512 + ":" + digest, nil
+	default:
+		return "", fmt.Errorf("unsupported checksum length %d", len(digest))
+	}
+}
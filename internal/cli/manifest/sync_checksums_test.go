@@ -0,0 +1,169 @@
+package manifest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pirakansa/vorbere/internal/cli/shared"
+)
+
+func TestParseChecksumFileSupportsGNUAndBSDFormats(t *testing.T) {
+	tool := shared.SHA256Hex([]byte("tool"))
+	archive := shared.MD5Hex([]byte("archive"))
+	content := strings.Join([]string{
+		"# generated by release tooling",
+		tool + "  dist/tool_linux_amd64",
+		archive + " *tool.tar.gz",
+		"SHA256 (tool_darwin_arm64) = " + strings.ToUpper(tool),
+		"MD5 (notes.txt)= " + archive,
+		"",
+	}, "\n")
+
+	checksums, err := parseChecksumFile([]byte(content))
+	if err != nil {
+		t.Fatalf("parseChecksumFile returned error: %v", err)
+	}
+	expected := map[string]string{
+		"dist/tool_linux_amd64": "sha256:" + tool,
+		"tool_linux_amd64":      "sha256:" + tool,
+		"tool.tar.gz":           "md5:" + archive,
+		"tool_darwin_arm64":     "sha256:" + tool,
+		"notes.txt":             "md5:" + archive,
+	}
+	for name, want := range expected {
+		if got := checksums[name]; got != want {
+			t.Fatalf("unexpected checksum for %s: got=%q want=%q", name, got, want)
+		}
+	}
+}
+
+func TestParseChecksumFileRejectsInvalidLines(t *testing.T) {
+	for _, content := range []string{"nothex  tool", "abcd", "WHIRLPOOL (tool) = abcd"} {
+		if _, err := parseChecksumFile([]byte(content)); err == nil {
+			t.Fatalf("expected error for %q", content)
+		}
+	}
+}
+
+func TestSyncVerifiesDownloadDigestFromChecksumFile(t *testing.T) {
+	files := map[string][]byte{
+		"/tool_linux_amd64":  []byte("linux"),
+		"/tool_darwin_arm64": []byte("darwin"),
+	}
+	sums := "SHA256 (tool_linux_amd64) = " + shared.SHA256Hex(files["/tool_linux_amd64"]) + "\n" +
+		shared.SHA256Hex(files["/tool_darwin_arm64"]) + "  tool_darwin_arm64\n"
+	sumsRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/SHA256SUMS" {
+			sumsRequests++
+			_, _ = w.Write([]byte(sums))
+			return
+		}
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	sumsSource := Source{URL: server.URL + "/SHA256SUMS"}
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{
+			"linux":  {URL: server.URL + "/tool_linux_amd64"},
+			"darwin": {URL: server.URL + "/tool_darwin_arm64"},
+			"sums":   sumsSource,
+		},
+		Files: []FileRule{
+			{Source: "linux", Path: "linux", DownloadChecksumFrom: "sums"},
+			{Source: "darwin", Path: "darwin", DownloadChecksumFrom: "sums"},
+		},
+	}
+	temp := t.TempDir()
+	if _, err := Sync(cfg, SyncOptions{RootDir: temp}); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if sumsRequests != 1 {
+		t.Fatalf("expected checksum file to be fetched once, got %d", sumsRequests)
+	}
+	got, err := os.ReadFile(filepath.Join(temp, "darwin"))
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if string(got) != "darwin" {
+		t.Fatalf("unexpected output: %q", string(got))
+	}
+
+	files["/tool_linux_amd64"] = []byte("tampered")
+	if _, err := Sync(cfg, SyncOptions{RootDir: t.TempDir()}); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
+
+func TestSyncReportsMissingChecksumEntryAsConfigError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/SHA256SUMS" {
+			_, _ = w.Write([]byte(shared.SHA256Hex([]byte("other")) + "  other\n"))
+			return
+		}
+		_, _ = w.Write([]byte("tool"))
+	}))
+	defer server.Close()
+
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{
+			"tool": {URL: server.URL + "/tool"},
+			"sums": {URL: server.URL + "/SHA256SUMS"},
+		},
+		Files: []FileRule{{Source: "tool", Path: "tool", DownloadChecksumFrom: "sums"}},
+	}
+	_, err := Sync(cfg, SyncOptions{RootDir: t.TempDir()})
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `no entry for "tool"`) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestVerifyChecksumRequiresEveryListedDigest(t *testing.T) {
+	content := []byte("tool")
+	valid := strings.Join([]string{
+		"sha512:" + shared.SHA512Hex(content),
+		"sha384:" + shared.SHA384Hex(content),
+		"sha1:" + shared.SHA1Hex(content),
+	}, ",")
+	if err := verifyChecksum(content, valid); err != nil {
+		t.Fatalf("verifyChecksum returned error: %v", err)
+	}
+	if err := verifyChecksum(content, valid+",sha256:"+shared.SHA256Hex([]byte("other"))); err == nil {
+		t.Fatalf("expected mismatch when one listed digest differs")
+	}
+}
+
+func TestChecksumSpecFromHexInfersAlgorithmFromLength(t *testing.T) {
+	content := []byte("tool")
+	cases := map[string]string{
+		shared.SHA1Hex(content):   DigestAlgorithmSHA1,
+		shared.SHA384Hex(content): DigestAlgorithmSHA384,
+		shared.SHA512Hex(content): DigestAlgorithmSHA512,
+	}
+	for digest, algorithm := range cases {
+		got, err := checksumSpecFromHex(digest)
+		if err != nil {
+			t.Fatalf("checksumSpecFromHex returned error: %v", err)
+		}
+		if got != algorithm+":"+digest {
+			t.Fatalf("expected %s digest, got %s", algorithm, got)
+		}
+	}
+}
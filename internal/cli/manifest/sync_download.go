@@ -13,38 +13,71 @@ import (
 )
 
 func download(src Source) ([]byte, error) {
-	req, err := http.NewRequest(http.MethodGet, src.URL, nil)
+	body, _, err := httpGet(src.URL, src.Headers, src.AllowHeaderForwardTo)
+	return body, err
+}
+
+func httpGet(rawURL string, headers map[string]string, forwardHosts []string) ([]byte, http.Header, error) {
+	resp, err := openHTTP(rawURL, headers, forwardHosts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, nil, fmt.Errorf("download failed: %s status=%d", rawURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, resp.Header, nil
+}
+
+// openHTTP sends a GET request and returns the response regardless of its
+// status code. The caller must close the response body.
+func openHTTP(rawURL string, headers map[string]string, forwardHosts []string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	for k, v := range src.Headers {
+	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
-	resp, err := newDownloadClient(src.Headers).Do(req)
+	resp, err := newDownloadClient(headers, forwardHosts).Do(req)
 	if err != nil {
 		return nil, fmt.Errorf(
 			"download failed: %s",
-			maskHeaderValues(err.Error(), src.Headers),
+			maskHeaderValues(err.Error(), headers),
 		)
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("download failed: %s status=%d", src.URL, resp.StatusCode)
-	}
-	return io.ReadAll(resp.Body)
+	return resp, nil
 }
 
-func newDownloadClient(headers map[string]string) *http.Client {
+// newDownloadClient returns a client for one download. Every redirect hop is
+// checked: headers are sent only while each hop stays on the original host
+// or a forward host, and are dropped for the rest of the chain after the
+// first hop that does not, or that downgrades an https request to http.
+func newDownloadClient(headers map[string]string, forwardHosts []string) *http.Client {
 	base := http.DefaultClient
 	if base == nil {
 		base = &http.Client{}
 	}
 	client := *base
 	prevCheckRedirect := base.CheckRedirect
+	dropped := false
 	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		if len(via) > 0 && !sameHost(req.URL, via[len(via)-1].URL) {
-			for key := range headers {
-				req.Header.Del(key)
+		if len(via) > 0 {
+			original := via[0].URL
+			downgraded := strings.EqualFold(original.Scheme, "https") && !strings.EqualFold(req.URL.Scheme, "https")
+			if downgraded || (!sameHost(req.URL, original) && !isHeaderForwardAllowed(req.URL, forwardHosts)) {
+				dropped = true
+			}
+			for key, value := range headers {
+				if dropped {
+					req.Header.Del(key)
+				} else {
+					req.Header.Set(key, value)
+				}
 			}
 		}
 		if prevCheckRedirect != nil {
@@ -62,6 +95,34 @@ func sameHost(left, right *url.URL) bool {
 	return strings.EqualFold(left.Host, right.Host)
 }
 
+// isHeaderForwardAllowed reports whether target matches one of the approved
+// hosts. Entries with a port must match host:port exactly, entries without a
+// port match the hostname on any port, and "*.example.com" matches any
+// subdomain of example.com but not example.com itself.
+func isHeaderForwardAllowed(target *url.URL, forwardHosts []string) bool {
+	if target == nil {
+		return false
+	}
+	hostPort := strings.ToLower(target.Host)
+	hostname := strings.ToLower(target.Hostname())
+	for _, allowed := range forwardHosts {
+		candidate := hostname
+		if strings.Contains(strings.TrimPrefix(allowed, "*."), ":") {
+			candidate = hostPort
+		}
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok {
+			if strings.HasSuffix(candidate, suffix) && len(candidate) > len(suffix) {
+				return true
+			}
+			continue
+		}
+		if candidate == allowed {
+			return true
+		}
+	}
+	return false
+}
+
 func maskHeaderValues(message string, headers map[string]string) string {
 	masked := message
 	for _, value := range headers {
@@ -73,23 +134,24 @@ func maskHeaderValues(message string, headers map[string]string) string {
 	return masked
 }
 
+// verifyChecksum verifies content against a comma separated list of
+// "<algorithm>:<hex>" digests; every entry must match.
 func verifyChecksum(content []byte, checksum string) error {
-	if checksum == "" {
-		return nil
-	}
-	algorithm, digest, err := parseChecksumSpec(checksum)
-	if err != nil {
-		return err
-	}
-	if algorithm == "" {
-		return nil
-	}
-	computed, err := computeDigest(content, algorithm)
-	if err != nil {
-		return err
-	}
-	if computed != digest {
-		return errors.New("checksum mismatch")
+	for _, entry := range Digests(checksum).List() {
+		algorithm, digest, err := parseChecksumSpec(entry)
+		if err != nil {
+			return err
+		}
+		if algorithm == "" {
+			continue
+		}
+		computed, err := computeDigest(content, algorithm)
+		if err != nil {
+			return err
+		}
+		if computed != digest {
+			return errors.New("checksum mismatch")
+		}
 	}
 	return nil
 }
@@ -115,6 +177,12 @@ func computeDigest(content []byte, algorithm string) (string, error) {
 		return shared.BLAKE3Hex(content), nil
 	case DigestAlgorithmSHA256:
 		return shared.SHA256Hex(content), nil
+	case DigestAlgorithmSHA384:
+		return shared.SHA384Hex(content), nil
+	case DigestAlgorithmSHA512:
+		return shared.SHA512Hex(content), nil
+	case DigestAlgorithmSHA1:
+		return shared.SHA1Hex(content), nil
 	case DigestAlgorithmMD5:
 		return shared.MD5Hex(content), nil
 	default:
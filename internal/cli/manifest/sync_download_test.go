@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 )
@@ -100,6 +101,138 @@ func TestDownloadDropsHeadersOnCrossHostRedirect(t *testing.T) {
 	}
 }
 
+func TestDownloadForwardsHeadersToAllowedCrossHostRedirect(t *testing.T) {
+	observed := installRedirectChainClient(t, "Authorization", map[string]string{
+		"github.com/o/r/releases/download/v1/tool": "https://objects.githubusercontent.com/asset",
+	})
+
+	_, err := download(Source{
+		URL:                  "https://github.com/o/r/releases/download/v1/tool",
+		Headers:              map[string]string{"Authorization": "Bearer token"},
+		AllowHeaderForwardTo: []string{"*.githubusercontent.com"},
+	})
+	if err != nil {
+		t.Fatalf("download returned error: %v", err)
+	}
+	if got := observed["objects.githubusercontent.com/asset"]; got != "Bearer token" {
+		t.Fatalf("expected header to be forwarded to allowed host, got %q", got)
+	}
+}
+
+func TestDownloadDropsHeadersOnceRedirectChainLeavesAllowedHosts(t *testing.T) {
+	observed := installRedirectChainClient(t, "X-Api-Key", map[string]string{
+		"origin.test/start":     "https://cdn.example.com/asset",
+		"cdn.example.com/asset": "https://example.com/asset",
+		"example.com/asset":     "https://untrusted.test/asset",
+		"untrusted.test/asset":  "https://cdn.example.com/final",
+	})
+
+	_, err := download(Source{
+		URL:                  "https://origin.test/start",
+		Headers:              map[string]string{"X-Api-Key": "secret"},
+		AllowHeaderForwardTo: []string{"*.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("download returned error: %v", err)
+	}
+	expected := map[string]string{
+		"origin.test/start":     "secret",
+		"cdn.example.com/asset": "secret",
+		"example.com/asset":     "",
+		"untrusted.test/asset":  "",
+		"cdn.example.com/final": "",
+	}
+	for hop, want := range expected {
+		if got := observed[hop]; got != want {
+			t.Fatalf("unexpected header at %s: got %q want %q", hop, got, want)
+		}
+	}
+}
+
+func TestDownloadDropsHeadersOnHTTPSDowngrade(t *testing.T) {
+	observed := installRedirectChainClient(t, "X-Api-Key", map[string]string{
+		"origin.test/start":     "http://origin.test/plain",
+		"origin.test/plain":     "https://cdn.example.com/asset",
+		"cdn.example.com/asset": "https://origin.test/final",
+	})
+
+	_, err := download(Source{
+		URL:                  "https://origin.test/start",
+		Headers:              map[string]string{"X-Api-Key": "secret"},
+		AllowHeaderForwardTo: []string{"*.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("download returned error: %v", err)
+	}
+	expected := map[string]string{
+		"origin.test/start":     "secret",
+		"origin.test/plain":     "",
+		"cdn.example.com/asset": "",
+		"origin.test/final":     "",
+	}
+	for hop, want := range expected {
+		if got := observed[hop]; got != want {
+			t.Fatalf("unexpected header at %s: got %q want %q", hop, got, want)
+		}
+	}
+}
+
+func TestIsHeaderForwardAllowed(t *testing.T) {
+	cases := []struct {
+		target string
+		hosts  []string
+		want   bool
+	}{
+		{target: "https://example.com/a", hosts: []string{"example.com"}, want: true},
+		{target: "https://EXAMPLE.com:8443/a", hosts: []string{"example.com"}, want: true},
+		{target: "https://example.com:8443/a", hosts: []string{"example.com:8443"}, want: true},
+		{target: "https://example.com:9000/a", hosts: []string{"example.com:8443"}, want: false},
+		{target: "https://a.b.example.com/a", hosts: []string{"*.example.com"}, want: true},
+		{target: "https://example.com/a", hosts: []string{"*.example.com"}, want: false},
+		{target: "https://badexample.com/a", hosts: []string{"*.example.com"}, want: false},
+		{target: "https://other.com/a", hosts: nil, want: false},
+	}
+	for _, tc := range cases {
+		target, err := url.Parse(tc.target)
+		if err != nil {
+			t.Fatalf("url.Parse(%s): %v", tc.target, err)
+		}
+		if got := isHeaderForwardAllowed(target, tc.hosts); got != tc.want {
+			t.Fatalf("isHeaderForwardAllowed(%s, %v) = %v, want %v", tc.target, tc.hosts, got, tc.want)
+		}
+	}
+}
+
+// installRedirectChainClient replaces http.DefaultClient with a transport
+// that follows hops (keyed by host+path) and records the headerKey value seen
+// at every hop.
+func installRedirectChainClient(t *testing.T, headerKey string, hops map[string]string) map[string]string {
+	t.Helper()
+	oldClient := http.DefaultClient
+	t.Cleanup(func() {
+		http.DefaultClient = oldClient
+	})
+	observed := map[string]string{}
+	http.DefaultClient = &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			hop := req.URL.Host + req.URL.Path
+			observed[hop] = req.Header.Get(headerKey)
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader("ok")),
+				Header:     make(http.Header),
+				Request:    req,
+			}
+			if location, ok := hops[hop]; ok {
+				resp.StatusCode = http.StatusFound
+				resp.Header.Set("Location", location)
+			}
+			return resp, nil
+		}),
+	}
+	return observed
+}
+
 type roundTripFunc func(*http.Request) (*http.Response, error)
 
 func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
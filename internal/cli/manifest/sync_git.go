@@ -0,0 +1,221 @@
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pirakansa/vorbere/internal/cli/shared"
+	pkgmanifest "github.com/pirakansa/vorbere/pkg/manifest"
+)
+
+func (f *sourceFetcher) fetchGitSource(src Source) (*fetchedArtifact, error) {
+	spec := src.Git
+	if spec == nil {
+		return nil, fmt.Errorf("git source %q is missing ref metadata", src.URL)
+	}
+	repoDir, commit, err := f.resolveGitCommit(src)
+	if err != nil {
+		return nil, err
+	}
+
+	selector := strings.TrimPrefix(strings.TrimSpace(spec.Path), "./")
+	entries, err := readGitTree(repoDir, commit, gitPathspec(selector))
+	if err != nil {
+		return nil, fmt.Errorf("git %s@%s: %w", src.URL, spec.Ref, err)
+	}
+
+	fetched := &fetchedArtifact{revision: commit}
+	if !pkgmanifest.IsGitDirectorySelector(selector) {
+		file := findExactArchiveEntry(entries, path.Clean(selector))
+		if file == nil {
+			if len(collectArchiveChildren(entries, path.Clean(selector))) > 0 {
+				return nil, fmt.Errorf("git %s@%s: %q is a directory; use %q to sync its contents", src.URL, spec.Ref, selector, selector+"/")
+			}
+			return nil, fmt.Errorf("git %s@%s: %q not found at commit %s", src.URL, spec.Ref, selector, commit)
+		}
+		fetched.content = file.content
+		fetched.name = path.Clean(selector)
+		return fetched, nil
+	}
+
+	fetched.entries = selectGitEntries(entries, selector)
+	if len(fetched.entries) == 0 {
+		return nil, fmt.Errorf("git %s@%s: %q matches no files at commit %s", src.URL, spec.Ref, selector, commit)
+	}
+	return fetched, nil
+}
+
+// resolveGitCommit shallow-fetches the ref into the cache repository once per
+// sync run and returns the cache repository and the resolved commit.
+func (f *sourceFetcher) resolveGitCommit(src Source) (string, string, error) {
+	remote := resolveGitRemote(src.URL, f.rootDir)
+	repoDir := filepath.Join(f.resolveCacheDir(), "git", shared.SHA256Hex([]byte(remote))[:16])
+	cacheKey := remote + "|" + src.Git.Ref
+	if commit, ok := f.gitCommits[cacheKey]; ok {
+		return repoDir, commit, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(repoDir, "HEAD")); os.IsNotExist(err) {
+		if err := os.MkdirAll(repoDir, 0o755); err != nil {
+			return "", "", err
+		}
+		if _, err := runGit(repoDir, nil, "init", "--bare", "--quiet"); err != nil {
+			return "", "", err
+		}
+	}
+
+	if _, err := runGit(repoDir, src.Headers, "fetch", "--quiet", "--depth=1", "--no-tags", remote, src.Git.Ref); err != nil {
+		return "", "", fmt.Errorf("git fetch %s@%s failed: %w", src.URL, src.Git.Ref, err)
+	}
+	out, err := runGit(repoDir, nil, "rev-parse", "--verify", "FETCH_HEAD^{commit}")
+	if err != nil {
+		return "", "", fmt.Errorf("git %s@%s: resolve commit: %w", src.URL, src.Git.Ref, err)
+	}
+	commit := strings.TrimSpace(string(out))
+	f.gitCommits[cacheKey] = commit
+	return repoDir, commit, nil
+}
+
+func readGitTree(repoDir, commit, pathspec string) ([]archiveEntry, error) {
+	args := []string{"archive", "--format=tar", commit}
+	if pathspec != "" {
+		args = append(args, "--", pathspec)
+	}
+	out, err := runGit(repoDir, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+	return readTarEntries(bytes.NewReader(out))
+}
+
+// gitPathspec narrows git archive to the static directory prefix of selector.
+func gitPathspec(selector string) string {
+	prefix := gitSelectorPrefix(selector)
+	if prefix == "" && !pkgmanifest.IsGitDirectorySelector(selector) {
+		return path.Clean(selector)
+	}
+	return prefix
+}
+
+// gitSelectorPrefix returns the leading directories of selector that contain
+// no glob metacharacters. Outputs are placed relative to this prefix.
+func gitSelectorPrefix(selector string) string {
+	trimmed := strings.TrimSuffix(selector, "/")
+	if !strings.ContainsAny(trimmed, "*?[") {
+		if strings.HasSuffix(selector, "/") {
+			return path.Clean(trimmed)
+		}
+		return ""
+	}
+	parts := strings.Split(trimmed, "/")
+	static := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if strings.ContainsAny(part, "*?[") {
+			break
+		}
+		static = append(static, part)
+	}
+	return strings.Join(static, "/")
+}
+
+func selectGitEntries(entries []archiveEntry, selector string) []archiveEntry {
+	prefix := gitSelectorPrefix(selector)
+	if strings.HasSuffix(selector, "/") && !strings.ContainsAny(selector, "*?[") {
+		return collectArchiveChildren(entries, prefix)
+	}
+
+	pattern := strings.TrimSuffix(selector, "/")
+	selected := make([]archiveEntry, 0)
+	for _, entry := range entries {
+		if !matchesGitSelector(pattern, entry.path) {
+			continue
+		}
+		relativePath := entry.path
+		if prefix != "" {
+			relativePath = strings.TrimPrefix(entry.path, prefix+"/")
+		}
+		selected = append(selected, archiveEntry{path: relativePath, body: entry.body, mode: entry.mode})
+	}
+	return selected
+}
+
+// matchesGitSelector matches pattern against entryPath or any of its parent
+// directories, so "configs/*" selects files nested below matching directories.
+func matchesGitSelector(pattern, entryPath string) bool {
+	for candidate := entryPath; candidate != "." && candidate != "/"; candidate = path.Dir(candidate) {
+		if ok, _ := path.Match(pattern, candidate); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveGitRemote makes local repository paths absolute against rootDir and
+// leaves URLs (scheme://...) and scp-like remotes (host:path) untouched.
+func resolveGitRemote(remote, rootDir string) string {
+	if strings.Contains(remote, "://") || filepath.IsAbs(remote) {
+		return remote
+	}
+	if colon := strings.Index(remote, ":"); colon > 0 && !strings.Contains(remote[:colon], "/") {
+		return remote
+	}
+	return filepath.Join(rootDir, remote)
+}
+
+// runGit runs git in dir. headers are sent as http.extraHeader through the
+// GIT_CONFIG_* environment rather than -c arguments, so their values never
+// show up in the process list, and are masked in the returned error.
+func runGit(dir string, headers map[string]string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(gitHeaderEnv(os.Environ(), headers), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return nil, errors.New(maskHeaderValues(message, headers))
+	}
+	return out, nil
+}
+
+// gitHeaderEnv appends one http.extraHeader entry per header to environ,
+// after any GIT_CONFIG_KEY_n entries the environment already defines.
+func gitHeaderEnv(environ []string, headers map[string]string) []string {
+	if len(headers) == 0 {
+		return environ
+	}
+	count := 0
+	env := make([]string, 0, len(environ)+len(headers)*2+1)
+	for _, entry := range environ {
+		if value, ok := strings.CutPrefix(entry, "GIT_CONFIG_COUNT="); ok {
+			count, _ = strconv.Atoi(value)
+			continue
+		}
+		env = append(env, entry)
+	}
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=http.extraHeader", count),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s: %s", count, key, headers[key]),
+		)
+		count++
+	}
+	return append(env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", count))
+}
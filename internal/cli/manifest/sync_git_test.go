@@ -0,0 +1,208 @@
+package manifest
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestGitRemote creates a bare repository with two commits and returns its
+// path together with the first commit hash. The first commit is tagged v1.
+func newTestGitRemote(t *testing.T) (string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	temp := t.TempDir()
+	remote := filepath.Join(temp, "remote.git")
+	work := filepath.Join(temp, "work")
+	mustGit(t, temp, "init", "--quiet", "--bare", remote)
+	mustGit(t, temp, "init", "--quiet", work)
+
+	writeTestFiles(t, work, map[string]string{
+		"AGENTS.md":                          "agents-v1",
+		".devcontainer/devcontainer.json":    "{}",
+		".devcontainer/features/extra.json":  "{\"extra\":true}",
+		".devcontainer/Dockerfile":           "FROM scratch",
+		"templates/github/workflows/ci.yaml": "ci",
+		"templates/README.md":                "readme",
+	})
+	mustGit(t, work, "add", ".")
+	mustGit(t, work, "commit", "--quiet", "-m", "first")
+	first := strings.TrimSpace(mustGit(t, work, "rev-parse", "HEAD"))
+	mustGit(t, work, "tag", "-a", "v1", "-m", "v1")
+
+	writeTestFiles(t, work, map[string]string{"AGENTS.md": "agents-v2"})
+	mustGit(t, work, "commit", "--quiet", "-am", "second")
+	mustGit(t, work, "push", "--quiet", remote, "HEAD:refs/heads/main", "refs/tags/v1")
+	mustGit(t, remote, "symbolic-ref", "HEAD", "refs/heads/main")
+	return remote, first
+}
+
+func mustGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		target := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", name, err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+}
+
+func gitTestSource(remote, ref, path string) Source {
+	return Source{Type: RepositoryTypeGit, URL: remote, Git: &GitSource{Ref: ref, Path: path}}
+}
+
+func TestSyncGitSourceSelectsFilesDirectoriesAndGlobs(t *testing.T) {
+	remote, _ := newTestGitRemote(t)
+	temp := t.TempDir()
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{
+			"file": gitTestSource(remote, "main", "AGENTS.md"),
+			"dir":  gitTestSource(remote, "main", "templates/"),
+			"glob": gitTestSource(remote, "main", ".devcontainer/*.json"),
+		},
+		Files: []FileRule{
+			{Source: "file", Path: "AGENTS.md"},
+			{Source: "dir", Path: "tpl", ExpandDirectory: true},
+			{Source: "glob", Path: ".devcontainer", ExpandDirectory: true},
+		},
+	}
+
+	var revisions []string
+	_, err := Sync(cfg, SyncOptions{
+		RootDir:  temp,
+		CacheDir: filepath.Join(temp, ".cache"),
+		OnFile: func(progress SyncFileProgress) {
+			revisions = append(revisions, progress.Revision)
+		},
+	})
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"AGENTS.md":                       "agents-v2",
+		"tpl/README.md":                   "readme",
+		"tpl/github/workflows/ci.yaml":    "ci",
+		".devcontainer/devcontainer.json": "{}",
+	}
+	for name, want := range expected {
+		got, err := os.ReadFile(filepath.Join(temp, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if string(got) != want {
+			t.Fatalf("unexpected %s: %q", name, string(got))
+		}
+	}
+	for _, name := range []string{".devcontainer/Dockerfile", ".devcontainer/features/extra.json"} {
+		if _, err := os.Stat(filepath.Join(temp, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s not to be selected by glob, err=%v", name, err)
+		}
+	}
+
+	head := strings.TrimSpace(mustGit(t, remote, "rev-parse", "main"))
+	for _, revision := range revisions {
+		if revision != head {
+			t.Fatalf("expected resolved commit %s in progress, got %v", head, revisions)
+		}
+	}
+}
+
+func TestSyncGitSourceResolvesTagAndCommitRefs(t *testing.T) {
+	remote, first := newTestGitRemote(t)
+	temp := t.TempDir()
+	for _, ref := range []string{"v1", first} {
+		cfg := &SyncConfig{
+			Version: "v1",
+			Sources: map[string]Source{"src": gitTestSource(remote, ref, "AGENTS.md")},
+			Files:   []FileRule{{Source: "src", Path: ref + ".md"}},
+		}
+		var revision string
+		_, err := Sync(cfg, SyncOptions{
+			RootDir:  temp,
+			CacheDir: filepath.Join(temp, ".cache"),
+			OnFile:   func(progress SyncFileProgress) { revision = progress.Revision },
+		})
+		if err != nil {
+			t.Fatalf("sync %s failed: %v", ref, err)
+		}
+		got, err := os.ReadFile(filepath.Join(temp, ref+".md"))
+		if err != nil {
+			t.Fatalf("read output: %v", err)
+		}
+		if string(got) != "agents-v1" {
+			t.Fatalf("unexpected content for ref %s: %q", ref, string(got))
+		}
+		if revision != first {
+			t.Fatalf("expected revision %s for ref %s, got %s", first, ref, revision)
+		}
+	}
+}
+
+func TestSyncGitSourceRequiresTrailingSlashForDirectory(t *testing.T) {
+	remote, _ := newTestGitRemote(t)
+	temp := t.TempDir()
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{"src": gitTestSource(remote, "main", "templates")},
+		Files:   []FileRule{{Source: "src", Path: "templates"}},
+	}
+	_, err := Sync(cfg, SyncOptions{RootDir: temp, CacheDir: filepath.Join(temp, ".cache")})
+	if err == nil {
+		t.Fatalf("expected directory selection error")
+	}
+	if !strings.Contains(err.Error(), `use "templates/"`) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRunGitPassesHeadersThroughEnvironment(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "vorbere.test")
+	t.Setenv("GIT_CONFIG_VALUE_0", "kept")
+	dir := t.TempDir()
+	headers := map[string]string{"Authorization": "Bearer abc", "X-Token": "s3cr3t"}
+
+	out, err := runGit(dir, headers, "config", "--get-all", "http.extraHeader")
+	if err != nil {
+		t.Fatalf("runGit returned error: %v", err)
+	}
+	if got, want := string(out), "Authorization: Bearer abc\nX-Token: s3cr3t\n"; got != want {
+		t.Fatalf("expected headers %q, got %q", want, got)
+	}
+	out, err = runGit(dir, headers, "config", "--get", "vorbere.test")
+	if err != nil || string(out) != "kept\n" {
+		t.Fatalf("expected existing GIT_CONFIG entries to be kept, got %q (%v)", out, err)
+	}
+
+	mustGit(t, dir, "init", "--quiet")
+	_, err = runGit(dir, headers, "rev-parse", "s3cr3t")
+	if err == nil || !strings.Contains(err.Error(), "***") {
+		t.Fatalf("expected a masked git error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Fatalf("expected header values to be masked, got %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pirakansa/vorbere/internal/cli/shared"
+)
+
+const (
+	githubAPIAccept       = "application/vnd.github+json"
+	githubAPIVersion      = "2022-11-28"
+	githubAssetAccept     = "application/octet-stream"
+	githubReleasesPerPage = 100
+)
+
+var linkNextPattern = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="next"`)
+
+type githubRelease struct {
+	TagName    string               `json:"tag_name"`
+	Draft      bool                 `json:"draft"`
+	Prerelease bool                 `json:"prerelease"`
+	Assets     []githubReleaseAsset `json:"assets"`
+}
+
+type githubReleaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+func (f *sourceFetcher) fetchGitHubReleaseAsset(src Source) (*fetchedArtifact, error) {
+	spec := src.GitHubRelease
+	if spec == nil {
+		return nil, fmt.Errorf("github release source %q is missing release metadata", src.URL)
+	}
+	release, err := f.resolveGitHubRelease(src)
+	if err != nil {
+		return nil, err
+	}
+	asset, err := matchGitHubReleaseAsset(release, spec.Asset)
+	if err != nil {
+		return nil, fmt.Errorf("github release %s@%s: %w", spec.Repo, release.TagName, err)
+	}
+	content, err := downloadGitHubReleaseAsset(src, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	fetched := &fetchedArtifact{content: content, name: asset.Name, revision: release.TagName}
+	if spec.Checksums == "" {
+		return fetched, nil
+	}
+	checksums, err := f.resolveGitHubChecksums(src, release)
+	if err != nil {
+		return nil, err
+	}
+	checksum, ok := checksums[asset.Name]
+	if !ok {
+		return nil, fmt.Errorf(
+			"github release %s@%s: checksums file %q has no entry for %q",
+			spec.Repo, release.TagName, spec.Checksums, asset.Name,
+		)
+	}
+	fetched.checksum = checksum
+	return fetched, nil
+}
+
+func (f *sourceFetcher) resolveGitHubRelease(src Source) (*githubRelease, error) {
+	spec := src.GitHubRelease
+	cacheKey := src.URL + "|" + spec.Repo + "|" + spec.Tag
+	if release, ok := f.githubReleases[cacheKey]; ok {
+		return release, nil
+	}
+
+	var (
+		release *githubRelease
+		err     error
+	)
+	switch {
+	case spec.Tag == GitHubReleaseTagLatest:
+		release, err = getGitHubRelease(src, githubRepoAPIURL(src, "releases/latest"))
+	case shared.IsVersionConstraint(spec.Tag):
+		release, err = findGitHubReleaseByConstraint(src)
+	default:
+		release, err = getGitHubRelease(src, githubRepoAPIURL(src, "releases/tags/"+url.PathEscape(spec.Tag)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.githubReleases[cacheKey] = release
+	return release, nil
+}
+
+func (f *sourceFetcher) resolveGitHubChecksums(src Source, release *githubRelease) (map[string]string, error) {
+	spec := src.GitHubRelease
+	cacheKey := src.URL + "|" + spec.Repo + "|" + release.TagName + "|" + spec.Checksums
+	if checksums, ok := f.githubChecksums[cacheKey]; ok {
+		return checksums, nil
+	}
+	asset, err := matchGitHubReleaseAsset(release, spec.Checksums)
+	if err != nil {
+		return nil, fmt.Errorf("github release %s@%s checksums: %w", spec.Repo, release.TagName, err)
+	}
+	content, err := downloadGitHubReleaseAsset(src, asset)
+	if err != nil {
+		return nil, err
+	}
+	checksums, err := parseChecksumFile(content)
+	if err != nil {
+		return nil, fmt.Errorf("github release %s@%s checksums %q: %w", spec.Repo, release.TagName, asset.Name, err)
+	}
+	f.githubChecksums[cacheKey] = checksums
+	return checksums, nil
+}
+
+func findGitHubReleaseByConstraint(src Source) (*githubRelease, error) {
+	spec := src.GitHubRelease
+	constraint, err := shared.ParseVersionConstraint(spec.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		best        *githubRelease
+		bestVersion shared.Version
+	)
+	next := githubRepoAPIURL(src, fmt.Sprintf("releases?per_page=%d", githubReleasesPerPage))
+	for next != "" {
+		body, header, err := httpGet(next, githubAPIHeaders(src.Headers), src.AllowHeaderForwardTo)
+		if err != nil {
+			return nil, err
+		}
+		var page []githubRelease
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("decode github releases for %s: %w", spec.Repo, err)
+		}
+		for index := range page {
+			release := &page[index]
+			if release.Draft || release.Prerelease {
+				continue
+			}
+			version, err := shared.ParseVersion(release.TagName)
+			if err != nil || version.Prerelease != "" || !constraint.Check(version) {
+				continue
+			}
+			if best == nil || shared.CompareVersions(version, bestVersion) > 0 {
+				best = release
+				bestVersion = version
+			}
+		}
+		next = nextPageURL(header.Get("Link"))
+	}
+	if best == nil {
+		return nil, fmt.Errorf("github release %s: no release matches tag constraint %q", spec.Repo, spec.Tag)
+	}
+	return best, nil
+}
+
+func getGitHubRelease(src Source, location string) (*githubRelease, error) {
+	body, _, err := httpGet(location, githubAPIHeaders(src.Headers), src.AllowHeaderForwardTo)
+	if err != nil {
+		return nil, err
+	}
+	var release githubRelease
+	if err := json.Unmarshal(body, &release); err != nil {
+		return nil, fmt.Errorf("decode github release for %s: %w", src.GitHubRelease.Repo, err)
+	}
+	return &release, nil
+}
+
+func matchGitHubReleaseAsset(release *githubRelease, pattern string) (githubReleaseAsset, error) {
+	var matches []githubReleaseAsset
+	for _, asset := range release.Assets {
+		ok, err := path.Match(pattern, asset.Name)
+		if err != nil {
+			return githubReleaseAsset{}, err
+		}
+		if ok {
+			matches = append(matches, asset)
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return githubReleaseAsset{}, fmt.Errorf("no asset matches %q", pattern)
+	default:
+		names := make([]string, 0, len(matches))
+		for _, asset := range matches {
+			names = append(names, asset.Name)
+		}
+		sort.Strings(names)
+		return githubReleaseAsset{}, fmt.Errorf("asset pattern %q matches multiple assets: %s", pattern, strings.Join(names, ", "))
+	}
+}
+
+func downloadGitHubReleaseAsset(src Source, asset githubReleaseAsset) ([]byte, error) {
+	headers := copyHeaders(src.Headers)
+	headers["Accept"] = githubAssetAccept
+	content, _, err := httpGet(asset.URL, headers, src.AllowHeaderForwardTo)
+	return content, err
+}
+
+func githubRepoAPIURL(src Source, suffix string) string {
+	return strings.TrimRight(src.URL, "/") + "/repos/" + src.GitHubRelease.Repo + "/" + suffix
+}
+
+func githubAPIHeaders(headers map[string]string) map[string]string {
+	merged := copyHeaders(headers)
+	if _, ok := merged["Accept"]; !ok {
+		merged["Accept"] = githubAPIAccept
+	}
+	if _, ok := merged["X-GitHub-Api-Version"]; !ok {
+		merged["X-GitHub-Api-Version"] = githubAPIVersion
+	}
+	return merged
+}
+
+func copyHeaders(headers map[string]string) map[string]string {
+	copied := make(map[string]string, len(headers)+2)
+	for key, value := range headers {
+		copied[key] = value
+	}
+	return copied
+}
+
+func nextPageURL(link string) string {
+	matches := linkNextPattern.FindStringSubmatch(link)
+	if len(matches) < 2 {
+		return ""
+	}
+	return matches[1]
+}
@@ -0,0 +1,194 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pirakansa/vorbere/internal/cli/shared"
+)
+
+type fakeGitHubAPI struct {
+	server   *httptest.Server
+	pages    [][]githubRelease
+	assets   map[string][]byte
+	requests []string
+}
+
+func newFakeGitHubAPI(t *testing.T, pages [][]githubRelease, assets map[string][]byte) *fakeGitHubAPI {
+	t.Helper()
+	api := &fakeGitHubAPI{pages: pages, assets: assets}
+	api.server = httptest.NewServer(http.HandlerFunc(api.serve))
+	t.Cleanup(api.server.Close)
+	for pageIndex := range api.pages {
+		for releaseIndex := range api.pages[pageIndex] {
+			release := &api.pages[pageIndex][releaseIndex]
+			for assetIndex := range release.Assets {
+				asset := &release.Assets[assetIndex]
+				asset.URL = api.server.URL + "/repos/o/r/releases/assets/" + release.TagName + "/" + asset.Name
+			}
+		}
+	}
+	return api
+}
+
+func (api *fakeGitHubAPI) serve(w http.ResponseWriter, r *http.Request) {
+	api.requests = append(api.requests, r.URL.RequestURI())
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.URL.Path == "/repos/o/r/releases":
+		page := 1
+		_, _ = fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+		if page < len(api.pages) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/o/r/releases?per_page=100&page=%d>; rel="next"`, api.server.URL, page+1))
+		}
+		_ = json.NewEncoder(w).Encode(api.pages[page-1])
+	case r.URL.Path == "/repos/o/r/releases/latest":
+		_ = json.NewEncoder(w).Encode(api.pages[0][0])
+	case strings.HasPrefix(r.URL.Path, "/repos/o/r/releases/tags/"):
+		tag := strings.TrimPrefix(r.URL.Path, "/repos/o/r/releases/tags/")
+		for _, page := range api.pages {
+			for _, release := range page {
+				if release.TagName == tag {
+					_ = json.NewEncoder(w).Encode(release)
+					return
+				}
+			}
+		}
+		http.NotFound(w, r)
+	case strings.HasPrefix(r.URL.Path, "/repos/o/r/releases/assets/"):
+		if r.Header.Get("Accept") != githubAssetAccept {
+			http.Error(w, "expected octet-stream accept header", http.StatusBadRequest)
+			return
+		}
+		body, ok := api.assets[strings.TrimPrefix(r.URL.Path, "/repos/o/r/releases/assets/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(body)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (api *fakeGitHubAPI) source(tag, asset, checksums string) Source {
+	return Source{
+		Type:    RepositoryTypeGitHub,
+		URL:     api.server.URL,
+		Headers: map[string]string{"Authorization": "Bearer test-token"},
+		GitHubRelease: &GitHubReleaseSource{
+			Repo:      "o/r",
+			Tag:       tag,
+			Asset:     asset,
+			Checksums: checksums,
+		},
+	}
+}
+
+func TestSyncGitHubReleaseLatestWithChecksums(t *testing.T) {
+	tool := []byte("tool-v2")
+	api := newFakeGitHubAPI(t, [][]githubRelease{{
+		{TagName: "v2.0.0", Assets: []githubReleaseAsset{{Name: "tool_linux_amd64"}, {Name: "tool_darwin_arm64"}, {Name: "checksums.txt"}}},
+	}}, map[string][]byte{
+		"v2.0.0/tool_linux_amd64": tool,
+		"v2.0.0/checksums.txt":    []byte(shared.SHA256Hex(tool) + "  tool_linux_amd64\n" + shared.SHA256Hex([]byte("other")) + "  tool_darwin_arm64\n"),
+	})
+
+	temp := t.TempDir()
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{"src": api.source(GitHubReleaseTagLatest, "tool_linux_*", "checksums.txt")},
+		Files:   []FileRule{{Source: "src", Path: "bin/tool", Mode: "0755"}},
+	}
+	if _, err := Sync(cfg, SyncOptions{RootDir: temp}); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(temp, "bin/tool"))
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if string(got) != string(tool) {
+		t.Fatalf("unexpected output: %q", string(got))
+	}
+
+	api.assets["v2.0.0/tool_linux_amd64"] = []byte("tampered")
+	cfg.Files[0].Path = "bin/tool-2"
+	if _, err := Sync(cfg, SyncOptions{RootDir: temp}); err == nil {
+		t.Fatalf("expected checksum mismatch from release checksums file")
+	}
+}
+
+func TestSyncGitHubReleaseSemverConstraintFollowsPagination(t *testing.T) {
+	api := newFakeGitHubAPI(t, [][]githubRelease{
+		{
+			{TagName: "v2.0.0", Assets: []githubReleaseAsset{{Name: "tool.txt"}}},
+			{TagName: "v1.3.0-rc.1", Prerelease: true, Assets: []githubReleaseAsset{{Name: "tool.txt"}}},
+		},
+		{
+			{TagName: "v1.2.0", Assets: []githubReleaseAsset{{Name: "tool.txt"}}},
+			{TagName: "v1.1.0", Assets: []githubReleaseAsset{{Name: "tool.txt"}}},
+		},
+	}, map[string][]byte{
+		"v2.0.0/tool.txt": []byte("v2"),
+		"v1.2.0/tool.txt": []byte("v1.2"),
+		"v1.1.0/tool.txt": []byte("v1.1"),
+	})
+
+	temp := t.TempDir()
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{
+			"a": api.source("^1.1", "tool.txt", ""),
+			"b": api.source("^1.1", "tool.txt", ""),
+		},
+		Files: []FileRule{{Source: "a", Path: "a.txt"}, {Source: "b", Path: "b.txt"}},
+	}
+	if _, err := Sync(cfg, SyncOptions{RootDir: temp}); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(temp, "a.txt"))
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if string(got) != "v1.2" {
+		t.Fatalf("expected highest matching release, got %q", string(got))
+	}
+
+	listRequests := 0
+	for _, request := range api.requests {
+		if strings.HasPrefix(request, "/repos/o/r/releases?") {
+			listRequests++
+		}
+	}
+	if listRequests != 2 {
+		t.Fatalf("expected release list to be fetched once across 2 pages, got %d requests: %v", listRequests, api.requests)
+	}
+}
+
+func TestSyncGitHubReleaseRejectsAmbiguousAssetPattern(t *testing.T) {
+	api := newFakeGitHubAPI(t, [][]githubRelease{{
+		{TagName: "v1.0.0", Assets: []githubReleaseAsset{{Name: "tool_linux_amd64"}, {Name: "tool_linux_arm64"}}},
+	}}, map[string][]byte{})
+
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{"src": api.source("v1.0.0", "tool_linux_*", "")},
+		Files:   []FileRule{{Source: "src", Path: "tool"}},
+	}
+	_, err := Sync(cfg, SyncOptions{RootDir: t.TempDir()})
+	if err == nil {
+		t.Fatalf("expected ambiguous asset error")
+	}
+	if !strings.Contains(err.Error(), "matches multiple assets") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
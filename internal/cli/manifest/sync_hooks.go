@@ -0,0 +1,37 @@
+package manifest
+
+import "slices"
+
+// ChangedHooks returns the on_change tasks of files that were created or
+// updated, followed by sync.after when anything changed. Each task appears
+// once, in first-seen order.
+func ChangedHooks(cfg *SyncConfig, res *SyncResult) []string {
+	if res == nil {
+		return nil
+	}
+	var hooks []string
+	changed := false
+	for _, file := range res.Files {
+		if file.Outcome != outcomeCreated && file.Outcome != outcomeUpdated {
+			continue
+		}
+		changed = true
+		if file.Index < 1 || file.Index > len(cfg.Files) {
+			continue
+		}
+		for _, name := range cfg.Files[file.Index-1].OnChange {
+			if !slices.Contains(hooks, name) {
+				hooks = append(hooks, name)
+			}
+		}
+	}
+	if !changed {
+		return nil
+	}
+	for _, name := range cfg.After {
+		if !slices.Contains(hooks, name) {
+			hooks = append(hooks, name)
+		}
+	}
+	return hooks
+}
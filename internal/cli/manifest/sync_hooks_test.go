@@ -0,0 +1,34 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChangedHooksRunsOnlyForChangedFiles(t *testing.T) {
+	cfg := &SyncConfig{
+		Files: []FileRule{
+			{Path: "a", OnChange: []string{"chmod", "npm-ci"}},
+			{Path: "b", OnChange: []string{"go-mod"}},
+			{Path: "c", OnChange: []string{"npm-ci", "lint"}},
+		},
+		After: []string{"lint", "notify"},
+	}
+	res := &SyncResult{Files: []SyncFileProgress{
+		{Index: 1, Outcome: outcomeCreated},
+		{Index: 2, Outcome: outcomeUnchanged},
+		{Index: 3, Outcome: outcomeUpdated},
+	}}
+	if got := strings.Join(ChangedHooks(cfg, res), ","); got != "chmod,npm-ci,lint,notify" {
+		t.Fatalf("unexpected hooks: %s", got)
+	}
+
+	res = &SyncResult{Files: []SyncFileProgress{
+		{Index: 1, Outcome: outcomeUnchanged},
+		{Index: 2, Outcome: outcomeUnchanged},
+		{Index: 3, Outcome: outcomeUnchanged},
+	}}
+	if got := ChangedHooks(cfg, res); len(got) != 0 {
+		t.Fatalf("expected no hooks when nothing changed, got %v", got)
+	}
+}
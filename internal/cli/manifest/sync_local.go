@@ -0,0 +1,52 @@
+package manifest
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func (f *sourceFetcher) fetchLocalFile(src Source) (*fetchedArtifact, error) {
+	location, err := resolveLocalSourcePath(src.URL, f.rootDir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(location)
+	if err != nil {
+		return nil, fmt.Errorf("read local source failed: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("read local source failed: %s is a directory", location)
+	}
+	content, err := os.ReadFile(location)
+	if err != nil {
+		return nil, fmt.Errorf("read local source failed: %w", err)
+	}
+	return &fetchedArtifact{content: content, name: filepath.Base(location)}, nil
+}
+
+// resolveLocalSourcePath converts a file:// URL or a local path into a
+// filesystem path. Relative paths are resolved against rootDir.
+func resolveLocalSourcePath(location, rootDir string) (string, error) {
+	value := strings.TrimSpace(location)
+	if strings.HasPrefix(strings.ToLower(value), SourceTypeFile+":") {
+		parsed, err := url.Parse(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid file url %q: %w", location, err)
+		}
+		if parsed.Host != "" && parsed.Host != "localhost" {
+			return "", fmt.Errorf("file url %q must not name a remote host", location)
+		}
+		value = parsed.Path
+		if value == "" {
+			value = parsed.Opaque
+		}
+	}
+	value = filepath.FromSlash(value)
+	if filepath.IsAbs(value) {
+		return filepath.Clean(value), nil
+	}
+	return filepath.Join(rootDir, value), nil
+}
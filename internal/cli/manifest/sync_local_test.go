@@ -0,0 +1,93 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pirakansa/vorbere/internal/cli/shared"
+)
+
+func TestSyncLocalSourcesUseFullPipeline(t *testing.T) {
+	temp := t.TempDir()
+	vendorDir := filepath.Join(temp, "vendor")
+	archive := mustBuildTarGzip(t, map[string]string{"pkg/bin/tool": "tool-binary"})
+	writeTestFiles(t, vendorDir, map[string]string{
+		"AGENTS.md":    "agents",
+		"tool.tar.gz":  string(archive),
+		"tool.zst":     string(mustEncodeZstd(t, []byte("decoded"))),
+		"dir/file.txt": "nested",
+	})
+	projectDir := filepath.Join(temp, "project")
+
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{
+			"relative": {Type: SourceTypeFile, URL: "../vendor/AGENTS.md"},
+			"absolute": {Type: SourceTypeFile, URL: filepath.Join(vendorDir, "dir/file.txt")},
+			"file-url": {Type: SourceTypeFile, URL: "file://" + filepath.ToSlash(filepath.Join(vendorDir, "tool.tar.gz"))},
+			"zstd":     {Type: SourceTypeFile, URL: "file:../vendor/tool.zst"},
+		},
+		Files: []FileRule{
+			{Source: "relative", Path: "AGENTS.md"},
+			{Source: "absolute", Path: "file.txt"},
+			{
+				Source:           "file-url",
+				Path:             "bin/tool",
+				Encoding:         EncodingTarGzip,
+				Extract:          "pkg/bin/tool",
+				DownloadChecksum: checksumSpec(DigestAlgorithmSHA256, shared.SHA256Hex(archive)),
+			},
+			{Source: "zstd", Path: "bin/decoded", Encoding: EncodingZstd},
+		},
+	}
+
+	if _, err := Sync(cfg, SyncOptions{RootDir: projectDir}); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	expected := map[string]string{
+		"AGENTS.md":   "agents",
+		"file.txt":    "nested",
+		"bin/tool":    "tool-binary",
+		"bin/decoded": "decoded",
+	}
+	for name, want := range expected {
+		got, err := os.ReadFile(filepath.Join(projectDir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if string(got) != want {
+			t.Fatalf("unexpected %s: %q", name, string(got))
+		}
+	}
+
+	writeTestFiles(t, vendorDir, map[string]string{"AGENTS.md": "agents-v2"})
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	res, err := Sync(cfg, SyncOptions{RootDir: projectDir, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	if res.Updated != 1 || res.Unchanged != 3 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	backupPath := fmt.Sprintf("%s.%s.bak", filepath.Join(projectDir, "AGENTS.md"), "20260301090000")
+	if _, err := os.Stat(backupPath); err != nil {
+		t.Fatalf("expected backup file %s: %v", backupPath, err)
+	}
+}
+
+func TestSyncLocalSourceRejectsDirectoryAndRemoteHost(t *testing.T) {
+	temp := t.TempDir()
+	for _, location := range []string{temp, "file://example.com/etc/hosts"} {
+		cfg := &SyncConfig{
+			Version: "v1",
+			Sources: map[string]Source{"src": {Type: SourceTypeFile, URL: location}},
+			Files:   []FileRule{{Source: "src", Path: "out.txt"}},
+		}
+		if _, err := Sync(cfg, SyncOptions{RootDir: temp}); err == nil {
+			t.Fatalf("expected error for local source %q", location)
+		}
+	}
+}
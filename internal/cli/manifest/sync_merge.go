@@ -0,0 +1,256 @@
+package manifest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pirakansa/vorbere/internal/cli/shared"
+)
+
+// MergeBaseDir holds the merge bases of merge: three-way entries, relative
+// to the config directory. It must be committed: without a base, local edits
+// cannot be told apart from upstream changes.
+const MergeBaseDir = ".vorbere/base"
+
+const (
+	conflictMarkerLocal  = "<<<<<<< local"
+	conflictMarkerSplit  = "======="
+	conflictMarkerRemote = ">>>>>>> upstream"
+	// maxMergeMatrix bounds the LCS table for the differing middle of two
+	// files; larger changes are treated as one replaced region.
+	maxMergeMatrix = 4 << 20
+)
+
+// applyThreeWayMerge merges local edits of targetPath with upstream changes
+// against the upstream content stored by the previous sync. An existing
+// target without a stored base is merged by mergeWithoutBase.
+func applyThreeWayMerge(targetPath string, upstream []byte, fileMode string, opts SyncOptions) (appliedOutput, error) {
+	basePath := mergeBasePath(opts.RootDir, targetPath)
+	current, err := os.ReadFile(targetPath)
+	if err != nil && !os.IsNotExist(err) {
+		return appliedOutput{}, err
+	}
+	incoming := upstream
+	conflict := false
+	if err == nil {
+		base, baseErr := os.ReadFile(basePath)
+		switch {
+		case baseErr == nil:
+			incoming, conflict = mergeThreeWay(base, current, upstream)
+		case os.IsNotExist(baseErr):
+			incoming, conflict = mergeWithoutBase(current, upstream)
+		default:
+			return appliedOutput{}, baseErr
+		}
+	}
+
+	outcome, err := applyRule(targetPath, incoming, fileMode, opts)
+	if err != nil {
+		return appliedOutput{}, err
+	}
+	if !opts.DryRun {
+		if err := os.MkdirAll(filepath.Dir(basePath), 0o755); err != nil {
+			return appliedOutput{}, err
+		}
+		if err := os.WriteFile(basePath, upstream, 0o644); err != nil {
+			return appliedOutput{}, err
+		}
+	}
+	if conflict || hasConflictMarkers(incoming) {
+		outcome = outcomeConflict
+	}
+	return appliedOutput{
+		outcome: outcome,
+		digest:  DigestAlgorithmSHA256 + ":" + shared.SHA256Hex(incoming),
+		bytes:   int64(len(incoming)),
+	}, nil
+}
+
+// mergeBasePath returns where the last synced upstream content of target is
+// stored, keyed by the target path relative to rootDir.
+func mergeBasePath(rootDir, target string) string {
+	key := target
+	if rel, err := filepath.Rel(rootDir, target); err == nil && !strings.HasPrefix(rel, "..") {
+		key = rel
+	}
+	return filepath.Join(rootDir, filepath.FromSlash(MergeBaseDir), shared.SHA256Hex([]byte(filepath.ToSlash(key))))
+}
+
+// mergeThreeWay performs a line-based diff3 merge. Regions changed on only
+// one side take that side; overlapping different changes are written with
+// conflict markers and reported as a conflict.
+func mergeThreeWay(base, local, upstream []byte) ([]byte, bool) {
+	baseLines := splitLines(base)
+	localLines := splitLines(local)
+	upstreamLines := splitLines(upstream)
+	localMatches := matchLines(baseLines, localLines)
+	upstreamMatches := matchLines(baseLines, upstreamLines)
+
+	var out bytes.Buffer
+	conflict := false
+	baseIndex, localIndex, upstreamIndex := 0, 0, 0
+	for {
+		next := baseIndex
+		for next < len(baseLines) && (localMatches[next] < 0 || upstreamMatches[next] < 0) {
+			next++
+		}
+		localEnd, upstreamEnd := len(localLines), len(upstreamLines)
+		if next < len(baseLines) {
+			localEnd, upstreamEnd = localMatches[next], upstreamMatches[next]
+		}
+		if next > baseIndex || localEnd > localIndex || upstreamEnd > upstreamIndex {
+			if writeMergeChunk(&out, baseLines[baseIndex:next], localLines[localIndex:localEnd], upstreamLines[upstreamIndex:upstreamEnd]) {
+				conflict = true
+			}
+		}
+		if next == len(baseLines) {
+			break
+		}
+		out.WriteString(baseLines[next])
+		baseIndex, localIndex, upstreamIndex = next+1, localEnd+1, upstreamEnd+1
+	}
+	return out.Bytes(), conflict
+}
+
+// mergeWithoutBase merges a target that has no stored base, for example on a
+// fresh checkout where .vorbere/base was not committed. Local edits cannot be
+// told apart from upstream changes, so the lines between the common prefix
+// and suffix are written as one conflict.
+func mergeWithoutBase(local, upstream []byte) ([]byte, bool) {
+	if bytes.Equal(local, upstream) {
+		return local, false
+	}
+	localLines := splitLines(local)
+	upstreamLines := splitLines(upstream)
+	prefix := 0
+	for prefix < len(localLines) && prefix < len(upstreamLines) && localLines[prefix] == upstreamLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(localLines)-prefix && suffix < len(upstreamLines)-prefix && localLines[len(localLines)-1-suffix] == upstreamLines[len(upstreamLines)-1-suffix] {
+		suffix++
+	}
+
+	var out bytes.Buffer
+	writeLines(&out, localLines[:prefix], false)
+	writeConflict(&out, localLines[prefix:len(localLines)-suffix], upstreamLines[prefix:len(upstreamLines)-suffix])
+	writeLines(&out, localLines[len(localLines)-suffix:], false)
+	return out.Bytes(), true
+}
+
+func writeMergeChunk(out *bytes.Buffer, base, local, upstream []string) bool {
+	switch {
+	case equalLines(local, base):
+		writeLines(out, upstream, false)
+	case equalLines(upstream, base), equalLines(local, upstream):
+		writeLines(out, local, false)
+	default:
+		writeConflict(out, local, upstream)
+		return true
+	}
+	return false
+}
+
+func writeConflict(out *bytes.Buffer, local, upstream []string) {
+	out.WriteString(conflictMarkerLocal + "\n")
+	writeLines(out, local, true)
+	out.WriteString(conflictMarkerSplit + "\n")
+	writeLines(out, upstream, true)
+	out.WriteString(conflictMarkerRemote + "\n")
+}
+
+func writeLines(out *bytes.Buffer, lines []string, terminate bool) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+	if terminate && len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		out.WriteByte('\n')
+	}
+}
+
+func hasConflictMarkers(content []byte) bool {
+	for _, line := range splitLines(content) {
+		if strings.TrimRight(line, "\r\n") == conflictMarkerLocal {
+			return true
+		}
+	}
+	return false
+}
+
+// splitLines splits content after each newline, keeping line endings.
+func splitLines(content []byte) []string {
+	var lines []string
+	for len(content) > 0 {
+		index := bytes.IndexByte(content, '\n')
+		if index < 0 {
+			lines = append(lines, string(content))
+			break
+		}
+		lines = append(lines, string(content[:index+1]))
+		content = content[index+1:]
+	}
+	return lines
+}
+
+func equalLines(left, right []string) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for index := range left {
+		if left[index] != right[index] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchLines maps each base line to its line in other along a longest common
+// subsequence, or -1 when the line is not part of it.
+func matchLines(base, other []string) []int {
+	matches := make([]int, len(base))
+	for index := range matches {
+		matches[index] = -1
+	}
+	prefix := 0
+	for prefix < len(base) && prefix < len(other) && base[prefix] == other[prefix] {
+		matches[prefix] = prefix
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(base)-prefix && suffix < len(other)-prefix && base[len(base)-1-suffix] == other[len(other)-1-suffix] {
+		matches[len(base)-1-suffix] = len(other) - 1 - suffix
+		suffix++
+	}
+
+	left := base[prefix : len(base)-suffix]
+	right := other[prefix : len(other)-suffix]
+	if len(left) == 0 || len(right) == 0 || len(left)*len(right) > maxMergeMatrix {
+		return matches
+	}
+	width := len(right) + 1
+	lengths := make([]int32, (len(left)+1)*width)
+	for i := len(left) - 1; i >= 0; i-- {
+		for j := len(right) - 1; j >= 0; j-- {
+			if left[i] == right[j] {
+				lengths[i*width+j] = lengths[(i+1)*width+j+1] + 1
+			} else {
+				lengths[i*width+j] = max(lengths[(i+1)*width+j], lengths[i*width+j+1])
+			}
+		}
+	}
+	for i, j := 0, 0; i < len(left) && j < len(right); {
+		switch {
+		case left[i] == right[j]:
+			matches[prefix+i] = prefix + j
+			i++
+			j++
+		case lengths[(i+1)*width+j] >= lengths[i*width+j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches
+}
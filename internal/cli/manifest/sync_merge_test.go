@@ -0,0 +1,147 @@
+package manifest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeThreeWayCombinesNonOverlappingChanges(t *testing.T) {
+	base := "a\nb\nc\nd\ne\n"
+	local := "a\nb-local\nc\nd\ne\nf-local\n"
+	upstream := "a\nb\nc\nd-upstream\ne\n"
+	merged, conflict := mergeThreeWay([]byte(base), []byte(local), []byte(upstream))
+	if conflict {
+		t.Fatalf("expected clean merge, got conflict:\n%s", merged)
+	}
+	if want := "a\nb-local\nc\nd-upstream\ne\nf-local\n"; string(merged) != want {
+		t.Fatalf("unexpected merge:\n got: %q\nwant: %q", merged, want)
+	}
+}
+
+func TestMergeThreeWayWritesConflictMarkers(t *testing.T) {
+	base := "a\nb\nc"
+	local := "a\nlocal\nc"
+	upstream := "a\nupstream\nc"
+	merged, conflict := mergeThreeWay([]byte(base), []byte(local), []byte(upstream))
+	if !conflict {
+		t.Fatalf("expected conflict")
+	}
+	want := "a\n<<<<<<< local\nlocal\n=======\nupstream\n>>>>>>> upstream\nc"
+	if string(merged) != want {
+		t.Fatalf("unexpected merge:\n got: %q\nwant: %q", merged, want)
+	}
+}
+
+func TestMergeThreeWayTakesIdenticalChangesOnce(t *testing.T) {
+	merged, conflict := mergeThreeWay([]byte("a\n"), []byte("a\nsame\n"), []byte("a\nsame\n"))
+	if conflict || string(merged) != "a\nsame\n" {
+		t.Fatalf("unexpected merge conflict=%v: %q", conflict, merged)
+	}
+}
+
+func TestSyncThreeWayMergeKeepsLocalEditsAndReportsConflicts(t *testing.T) {
+	upstream := "# Agents\nrule one\nrule two\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(upstream))
+	}))
+	defer server.Close()
+
+	temp := t.TempDir()
+	target := filepath.Join(temp, "AGENTS.md")
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{"src": {URL: server.URL}},
+		Files:   []FileRule{{Source: "src", Path: "AGENTS.md", Merge: MergeThreeWay}},
+	}
+	opts := SyncOptions{RootDir: temp, Overwrite: true}
+	if _, err := Sync(cfg, opts); err != nil {
+		t.Fatalf("initial sync failed: %v", err)
+	}
+
+	if err := os.WriteFile(target, []byte("# Agents\nrule one\nrule two\nlocal rule\n"), 0o644); err != nil {
+		t.Fatalf("write local edit: %v", err)
+	}
+	upstream = "# Agents (v2)\nrule one\nrule two\n"
+	res, err := Sync(cfg, opts)
+	if err != nil {
+		t.Fatalf("merge sync failed: %v", err)
+	}
+	got, _ := os.ReadFile(target)
+	if res.Updated != 1 || string(got) != "# Agents (v2)\nrule one\nrule two\nlocal rule\n" {
+		t.Fatalf("unexpected clean merge result %+v: %q", res, got)
+	}
+
+	if err := os.WriteFile(target, []byte("# Agents (v2)\nrule one\nlocal two\nlocal rule\n"), 0o644); err != nil {
+		t.Fatalf("write local edit: %v", err)
+	}
+	upstream = "# Agents (v2)\nrule one\nupstream two\n"
+	res, err = Sync(cfg, opts)
+	if err != nil {
+		t.Fatalf("conflict sync failed: %v", err)
+	}
+	if res.Conflicts != 1 || res.Files[0].Outcome != outcomeConflict {
+		t.Fatalf("expected conflict outcome, got %+v", res)
+	}
+	got, _ = os.ReadFile(target)
+	if !strings.Contains(string(got), "<<<<<<< local\nlocal two\nlocal rule\n=======\nupstream two\n>>>>>>> upstream\n") {
+		t.Fatalf("expected conflict markers, got %q", got)
+	}
+
+	res, err = Sync(cfg, opts)
+	if err != nil {
+		t.Fatalf("repeat sync failed: %v", err)
+	}
+	if res.Conflicts != 1 {
+		t.Fatalf("expected unresolved markers to keep reporting a conflict, got %+v", res)
+	}
+}
+
+func TestSyncThreeWayMergeWithoutBaseReportsConflict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("# Agents\nupstream rule\nfooter\n"))
+	}))
+	defer server.Close()
+
+	temp := t.TempDir()
+	target := filepath.Join(temp, "a.txt")
+	if err := os.WriteFile(target, []byte("# Agents\nlocal rule\nfooter\n"), 0o644); err != nil {
+		t.Fatalf("write local file: %v", err)
+	}
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{"src": {URL: server.URL}},
+		Files:   []FileRule{{Source: "src", Path: "a.txt", Merge: MergeThreeWay}},
+	}
+	res, err := Sync(cfg, SyncOptions{RootDir: temp, Overwrite: true})
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if res.Conflicts != 1 || res.Files[0].Outcome != outcomeConflict {
+		t.Fatalf("expected conflict without stored base, got %+v", res)
+	}
+	got, _ := os.ReadFile(target)
+	if want := "# Agents\n<<<<<<< local\nlocal rule\n=======\nupstream rule\n>>>>>>> upstream\nfooter\n"; string(got) != want {
+		t.Fatalf("expected local edits to be kept in conflict markers, got %q", got)
+	}
+	if _, err := os.Stat(mergeBasePath(temp, target)); err != nil {
+		t.Fatalf("expected base to be stored: %v", err)
+	}
+
+	if err := os.WriteFile(target, []byte("# Agents\nupstream rule\nfooter\n"), 0o644); err != nil {
+		t.Fatalf("write resolved file: %v", err)
+	}
+	if err := os.Remove(mergeBasePath(temp, target)); err != nil {
+		t.Fatalf("remove base: %v", err)
+	}
+	res, err = Sync(cfg, SyncOptions{RootDir: temp, Overwrite: true})
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if res.Conflicts != 0 || res.Files[0].Outcome != outcomeUnchanged {
+		t.Fatalf("expected a file matching upstream to be unchanged without base, got %+v", res)
+	}
+}
@@ -0,0 +1,235 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pirakansa/vorbere/internal/cli/shared"
+	pkgmanifest "github.com/pirakansa/vorbere/pkg/manifest"
+)
+
+const (
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	ociIndexMediaType       = "application/vnd.oci.image.index.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	dockerListMediaType     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	ociTitleAnnotation      = "org.opencontainers.image.title"
+)
+
+var ociChallengeParamPattern = regexp.MustCompile(`([A-Za-z]+)="([^"]*)"`)
+
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Layers    []ociDescriptor `json:"layers"`
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type ociResolvedManifest struct {
+	digest   string
+	manifest ociManifest
+}
+
+func (f *sourceFetcher) fetchOCILayer(src Source) (*fetchedArtifact, error) {
+	if src.OCI == nil {
+		return nil, fmt.Errorf("oci source %q is missing layer selection", src.URL)
+	}
+	ref, err := pkgmanifest.ParseOCIReference(src.URL)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := f.resolveOCIManifest(src, ref)
+	if err != nil {
+		return nil, err
+	}
+	layer, err := selectOCILayer(resolved.manifest.Layers, src.OCI)
+	if err != nil {
+		return nil, fmt.Errorf("oci %s: %w", ref, err)
+	}
+	content, _, err := f.ociGet(src, ref, "blobs/"+layer.Digest, "")
+	if err != nil {
+		return nil, err
+	}
+	if layer.Size > 0 && int64(len(content)) != layer.Size {
+		return nil, fmt.Errorf("oci %s: layer %s size mismatch: got %d want %d", ref, layer.Digest, len(content), layer.Size)
+	}
+	return &fetchedArtifact{
+		content:  content,
+		name:     layer.Annotations[ociTitleAnnotation],
+		checksum: strings.ToLower(layer.Digest),
+		revision: resolved.digest,
+	}, nil
+}
+
+// resolveOCIManifest fetches the manifest once per reference and verifies it
+// against the pinned digest when the reference uses @sha256:.
+func (f *sourceFetcher) resolveOCIManifest(src Source, ref pkgmanifest.OCIReference) (*ociResolvedManifest, error) {
+	cacheKey := ref.Scheme + "://" + ref.String()
+	if resolved, ok := f.ociManifests[cacheKey]; ok {
+		return resolved, nil
+	}
+
+	accept := strings.Join([]string{ociManifestMediaType, dockerManifestMediaType, ociIndexMediaType, dockerListMediaType}, ", ")
+	body, _, err := f.ociGet(src, ref, "manifests/"+ref.ManifestReference(), accept)
+	if err != nil {
+		return nil, err
+	}
+	digest := DigestAlgorithmSHA256 + ":" + shared.SHA256Hex(body)
+	if ref.Digest != "" {
+		if err := verifyChecksum(body, ref.Digest); err != nil {
+			return nil, fmt.Errorf("oci %s: manifest digest mismatch", ref)
+		}
+		digest = ref.Digest
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("oci %s: decode manifest: %w", ref, err)
+	}
+	if manifest.MediaType == ociIndexMediaType || manifest.MediaType == dockerListMediaType || len(manifest.Manifests) > 0 {
+		return nil, fmt.Errorf("oci %s: image indexes are not supported; reference a manifest digest instead", ref)
+	}
+	resolved := &ociResolvedManifest{digest: digest, manifest: manifest}
+	f.ociManifests[cacheKey] = resolved
+	return resolved, nil
+}
+
+// ociGet requests /v2/<repository>/<suffix>. On a 401 bearer challenge it
+// performs the token handshake once and retries with the issued token.
+func (f *sourceFetcher) ociGet(src Source, ref pkgmanifest.OCIReference, suffix, accept string) ([]byte, http.Header, error) {
+	location := ref.Scheme + "://" + ref.Registry + "/v2/" + ref.Repository + "/" + suffix
+	tokenKey := ref.Scheme + "://" + ref.Registry + "/" + ref.Repository
+	for attempt := 0; attempt < 2; attempt++ {
+		headers := copyHeaders(src.Headers)
+		if accept != "" {
+			headers["Accept"] = accept
+		}
+		if token, ok := f.ociTokens[tokenKey]; ok {
+			deleteHeader(headers, "Authorization")
+			headers["Authorization"] = "Bearer " + token
+		}
+
+		resp, err := openHTTP(location, headers, src.AllowHeaderForwardTo)
+		if err != nil {
+			return nil, nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			token, err := requestOCIToken(src, ref, challenge)
+			if err != nil {
+				return nil, nil, fmt.Errorf("oci %s: %w", ref, err)
+			}
+			f.ociTokens[tokenKey] = token
+			continue
+		}
+		body, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return nil, nil, fmt.Errorf("download failed: %s status=%d", location, resp.StatusCode)
+		}
+		if readErr != nil {
+			return nil, nil, readErr
+		}
+		return body, resp.Header, nil
+	}
+	return nil, nil, fmt.Errorf("download failed: %s status=%d", location, http.StatusUnauthorized)
+}
+
+// requestOCIToken implements the registry bearer token handshake. Repository
+// headers (for example a Basic Authorization header) are sent to the realm.
+func requestOCIToken(src Source, ref pkgmanifest.OCIReference, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("registry requires %q authentication; set repositories[].headers.Authorization", scheme)
+	}
+	values := map[string]string{}
+	for _, match := range ociChallengeParamPattern.FindAllStringSubmatch(params, -1) {
+		values[strings.ToLower(match[1])] = match[2]
+	}
+	realm := values["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("registry bearer challenge has no realm")
+	}
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid registry token realm %q: %w", realm, err)
+	}
+	query := tokenURL.Query()
+	if service := values["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := values["scope"]
+	if scope == "" {
+		scope = "repository:" + ref.Repository + ":pull"
+	}
+	query.Set("scope", scope)
+	tokenURL.RawQuery = query.Encode()
+
+	body, _, err := httpGet(tokenURL.String(), src.Headers, src.AllowHeaderForwardTo)
+	if err != nil {
+		return "", err
+	}
+	var response struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("decode registry token: %w", err)
+	}
+	if response.Token != "" {
+		return response.Token, nil
+	}
+	if response.AccessToken != "" {
+		return response.AccessToken, nil
+	}
+	return "", fmt.Errorf("registry token response has no token")
+}
+
+func selectOCILayer(layers []ociDescriptor, selection *OCISource) (ociDescriptor, error) {
+	var matches []ociDescriptor
+	for _, layer := range layers {
+		if selection.MediaType != "" && layer.MediaType != selection.MediaType {
+			continue
+		}
+		if selection.Title != "" {
+			ok, err := path.Match(selection.Title, layer.Annotations[ociTitleAnnotation])
+			if err != nil {
+				return ociDescriptor{}, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		matches = append(matches, layer)
+	}
+	describe := fmt.Sprintf("title=%q media_type=%q", selection.Title, selection.MediaType)
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return ociDescriptor{}, fmt.Errorf("no layer matches %s", describe)
+	default:
+		return ociDescriptor{}, fmt.Errorf("%d layers match %s; narrow file_name or media_type", len(matches), describe)
+	}
+}
+
+func deleteHeader(headers map[string]string, name string) {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			delete(headers, key)
+		}
+	}
+}
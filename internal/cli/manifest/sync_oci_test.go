@@ -0,0 +1,202 @@
+package manifest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pirakansa/vorbere/internal/cli/shared"
+)
+
+type fakeOCIRegistry struct {
+	server         *httptest.Server
+	manifest       []byte
+	blobs          map[string][]byte
+	tokenRequests  []string
+	manifestLookup []string
+}
+
+func newFakeOCIRegistry(t *testing.T, layers map[string][]byte, mediaTypes map[string]string) *fakeOCIRegistry {
+	t.Helper()
+	registry := &fakeOCIRegistry{blobs: map[string][]byte{}}
+	manifest := ociManifest{MediaType: ociManifestMediaType}
+	for _, title := range []string{"tool_linux_amd64.tar.gz", "tool_darwin_arm64.tar.gz", "README.md"} {
+		content, ok := layers[title]
+		if !ok {
+			continue
+		}
+		digest := DigestAlgorithmSHA256 + ":" + shared.SHA256Hex(content)
+		registry.blobs[digest] = content
+		manifest.Layers = append(manifest.Layers, ociDescriptor{
+			MediaType:   mediaTypes[title],
+			Digest:      digest,
+			Size:        int64(len(content)),
+			Annotations: map[string]string{ociTitleAnnotation: title},
+		})
+	}
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("marshal manifest: %v", err)
+	}
+	registry.manifest = body
+	registry.server = httptest.NewServer(http.HandlerFunc(registry.serve))
+	t.Cleanup(registry.server.Close)
+	return registry
+}
+
+func (registry *fakeOCIRegistry) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		registry.tokenRequests = append(registry.tokenRequests, r.URL.RawQuery)
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": "registry-token"})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer registry-token" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+registry.server.URL+`/token",service="test-registry",scope="repository:org/tool:pull"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v2/org/tool/manifests/"):
+		registry.manifestLookup = append(registry.manifestLookup, strings.TrimPrefix(r.URL.Path, "/v2/org/tool/manifests/"))
+		w.Header().Set("Content-Type", ociManifestMediaType)
+		_, _ = w.Write(registry.manifest)
+	case strings.HasPrefix(r.URL.Path, "/v2/org/tool/blobs/"):
+		body, ok := registry.blobs[strings.TrimPrefix(r.URL.Path, "/v2/org/tool/blobs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(body)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (registry *fakeOCIRegistry) source(reference, title, mediaType string) Source {
+	return Source{
+		Type: RepositoryTypeOCI,
+		URL:  "http://" + strings.TrimPrefix(registry.server.URL, "http://") + "/org/tool" + reference,
+		OCI:  &OCISource{Title: title, MediaType: mediaType},
+	}
+}
+
+func TestSyncOCILayerByTagWithTokenHandshake(t *testing.T) {
+	archive := mustBuildTarGzip(t, map[string]string{"tool": "oci-tool"})
+	registry := newFakeOCIRegistry(t, map[string][]byte{
+		"tool_linux_amd64.tar.gz":  archive,
+		"tool_darwin_arm64.tar.gz": []byte("darwin"),
+	}, map[string]string{
+		"tool_linux_amd64.tar.gz":  "application/vnd.example.tool.layer.v1.tar+gzip",
+		"tool_darwin_arm64.tar.gz": "application/vnd.example.tool.layer.v1.tar+gzip",
+	})
+
+	temp := t.TempDir()
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{
+			"a": registry.source(":1.0.0", "tool_linux_*", ""),
+			"b": registry.source(":1.0.0", "tool_linux_amd64.tar.gz", ""),
+		},
+		Files: []FileRule{
+			{Source: "a", Path: "bin/tool", Encoding: EncodingTarGzip, Extract: "tool"},
+			{Source: "b", Path: "tool.tar.gz"},
+		},
+	}
+	var revisions []string
+	_, err := Sync(cfg, SyncOptions{RootDir: temp, OnFile: func(progress SyncFileProgress) {
+		revisions = append(revisions, progress.Revision)
+	}})
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(temp, "bin/tool"))
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if string(got) != "oci-tool" {
+		t.Fatalf("unexpected output: %q", string(got))
+	}
+	if len(registry.tokenRequests) != 1 || !strings.Contains(registry.tokenRequests[0], "service=test-registry") {
+		t.Fatalf("expected one token request with service, got %v", registry.tokenRequests)
+	}
+	if len(registry.manifestLookup) != 1 || registry.manifestLookup[0] != "1.0.0" {
+		t.Fatalf("expected manifest to be resolved once by tag, got %v", registry.manifestLookup)
+	}
+	wantRevision := DigestAlgorithmSHA256 + ":" + shared.SHA256Hex(registry.manifest)
+	if len(revisions) != 2 || revisions[0] != wantRevision {
+		t.Fatalf("expected manifest digest revision %q, got %v", wantRevision, revisions)
+	}
+}
+
+func TestSyncOCILayerByDigestAndMediaType(t *testing.T) {
+	registry := newFakeOCIRegistry(t, map[string][]byte{
+		"tool_linux_amd64.tar.gz": []byte("binary"),
+		"README.md":               []byte("# tool\n"),
+	}, map[string]string{
+		"tool_linux_amd64.tar.gz": "application/vnd.example.tool.layer.v1.tar+gzip",
+		"README.md":               "text/markdown",
+	})
+	pinned := "@" + DigestAlgorithmSHA256 + ":" + shared.SHA256Hex(registry.manifest)
+
+	temp := t.TempDir()
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{"src": registry.source(pinned, "", "text/markdown")},
+		Files:   []FileRule{{Source: "src", Path: "README.md"}},
+	}
+	if _, err := Sync(cfg, SyncOptions{RootDir: temp}); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(temp, "README.md"))
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if string(got) != "# tool\n" {
+		t.Fatalf("unexpected output: %q", string(got))
+	}
+
+	cfg.Sources["src"] = registry.source("@"+DigestAlgorithmSHA256+":"+shared.SHA256Hex([]byte("other")), "", "text/markdown")
+	_, err = Sync(cfg, SyncOptions{RootDir: t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "manifest digest mismatch") {
+		t.Fatalf("expected manifest digest mismatch, got %v", err)
+	}
+}
+
+func TestSyncOCILayerDetectsBlobDigestMismatch(t *testing.T) {
+	registry := newFakeOCIRegistry(t, map[string][]byte{
+		"README.md": []byte("original"),
+	}, map[string]string{
+		"README.md": "text/markdown",
+	})
+	for digest := range registry.blobs {
+		registry.blobs[digest] = []byte("tampered")
+	}
+
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{"src": registry.source(":latest", "README.md", "")},
+		Files:   []FileRule{{Source: "src", Path: "README.md"}},
+	}
+	if _, err := Sync(cfg, SyncOptions{RootDir: t.TempDir()}); err == nil {
+		t.Fatalf("expected layer digest mismatch")
+	}
+}
+
+func TestSelectOCILayerRejectsAmbiguousSelection(t *testing.T) {
+	layers := []ociDescriptor{
+		{Digest: "sha256:aa", Annotations: map[string]string{ociTitleAnnotation: "tool_linux_amd64"}},
+		{Digest: "sha256:bb", Annotations: map[string]string{ociTitleAnnotation: "tool_linux_arm64"}},
+	}
+	_, err := selectOCILayer(layers, &OCISource{Title: "tool_linux_*"})
+	if err == nil || !strings.Contains(err.Error(), "2 layers match") {
+		t.Fatalf("expected ambiguous layer error, got %v", err)
+	}
+	layer, err := selectOCILayer(layers, &OCISource{Title: "tool_linux_arm64"})
+	if err != nil || layer.Digest != "sha256:bb" {
+		t.Fatalf("expected arm64 layer, got %+v err=%v", layer, err)
+	}
+}
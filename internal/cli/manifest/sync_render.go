@@ -0,0 +1,40 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"text/template"
+
+	pkgmanifest "github.com/pirakansa/vorbere/pkg/manifest"
+)
+
+// renderTemplate runs a single output through text/template with the
+// manifest function library; relative paths resolve against rootDir.
+// Undefined .vars keys are errors; use `index .env "NAME"` for optional
+// variables. .env is empty for entries of a remote config or include.
+func renderTemplate(content []byte, rule FileRule, rootDir string) ([]byte, error) {
+	tmpl, err := template.New(rule.FieldPath()).Option("missingkey=error").Funcs(pkgmanifest.TemplateFuncs(rootDir)).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("render %s: %w", rule.Path, err)
+	}
+	vars := rule.Vars
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	env := map[string]string{}
+	if !rule.Remote {
+		env = pkgmanifest.EnvironMap()
+	}
+	data := map[string]any{
+		"vars": vars,
+		"env":  env,
+		"os":   runtime.GOOS,
+		"arch": runtime.GOARCH,
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return nil, fmt.Errorf("render %s: %w", rule.Path, err)
+	}
+	return rendered.Bytes(), nil
+}
@@ -0,0 +1,93 @@
+package manifest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/pirakansa/vorbere/internal/cli/shared"
+)
+
+func TestSyncRendersTemplateAfterOutputDigest(t *testing.T) {
+	template := []byte(`{"name": "{{ .vars.NAME }}", "platform": "{{ .os }}/{{ .arch }}", "home": "{{ index .env "RENDER_TEST_HOME" }}"}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(template)
+	}))
+	defer server.Close()
+	t.Setenv("RENDER_TEST_HOME", "/home/dev")
+
+	temp := t.TempDir()
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{"src": {URL: server.URL}},
+		Files: []FileRule{{
+			Source:         "src",
+			Path:           "devcontainer.json",
+			OutputChecksum: "sha256:" + shared.SHA256Hex(template),
+			Render:         true,
+			Vars:           map[string]string{"NAME": "api"},
+		}},
+	}
+	res, err := Sync(cfg, SyncOptions{RootDir: temp})
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if res.Created != 1 {
+		t.Fatalf("expected created=1, got %+v", res)
+	}
+	got, err := os.ReadFile(filepath.Join(temp, "devcontainer.json"))
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	want := `{"name": "api", "platform": "` + runtime.GOOS + "/" + runtime.GOARCH + `", "home": "/home/dev"}`
+	if string(got) != want {
+		t.Fatalf("unexpected rendered output:\n got: %s\nwant: %s", got, want)
+	}
+
+	res, err = Sync(cfg, SyncOptions{RootDir: temp})
+	if err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	if res.Unchanged != 1 {
+		t.Fatalf("expected rendered output to be unchanged, got %+v", res)
+	}
+}
+
+func TestSyncRenderRejectsUndefinedVars(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{{ .vars.MISSING }}"))
+	}))
+	defer server.Close()
+
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{"src": {URL: server.URL}},
+		Files:   []FileRule{{Source: "src", Path: "AGENTS.md", Render: true}},
+	}
+	_, err := Sync(cfg, SyncOptions{RootDir: t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "render AGENTS.md") || !strings.Contains(err.Error(), "MISSING") {
+		t.Fatalf("expected undefined var render error, got %v", err)
+	}
+}
+
+func TestSyncRenderHidesEnvFromRemoteEntries(t *testing.T) {
+	t.Setenv("RENDER_TEST_TOKEN", "s3cr3t")
+	rule := FileRule{Path: "AGENTS.md", Render: true, Remote: true}
+	got, err := renderTemplate([]byte(`token={{ index .env "RENDER_TEST_TOKEN" }}`), rule, t.TempDir())
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if string(got) != "token=" {
+		t.Fatalf("expected an empty .env for a remote entry, got %q", got)
+	}
+
+	rule.Remote = false
+	got, err = renderTemplate([]byte(`token={{ index .env "RENDER_TEST_TOKEN" }}`), rule, t.TempDir())
+	if err != nil || string(got) != "token=s3cr3t" {
+		t.Fatalf("expected the process env for a local entry, got %q: %v", got, err)
+	}
+}
@@ -0,0 +1,244 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	minisignKeyLength       = 2 + 8 + ed25519.PublicKeySize
+	minisignSignatureLength = 2 + 8 + ed25519.SignatureSize
+	minisignTrustedPrefix   = "trusted comment: "
+	pgpArmorPrefix          = "-----BEGIN "
+)
+
+// verifySignature checks the detached signature of a downloaded artifact
+// before anything is written.
+func (f *sourceFetcher) verifySignature(content []byte, rule *SignatureRule, signatureSource Source) error {
+	key, err := f.loadSignatureKey(rule)
+	if err != nil {
+		return fmt.Errorf("signature key: %w", err)
+	}
+	fetched, err := f.fetch(signatureSource)
+	if err != nil {
+		return fmt.Errorf("signature %s: %w", signatureSource.URL, err)
+	}
+
+	switch rule.Type {
+	case SignatureTypeMinisign:
+		err = verifyMinisign(content, key, fetched.content)
+	case SignatureTypeEd25519:
+		err = verifyEd25519(content, key, fetched.content)
+	case SignatureTypeCosign:
+		err = verifyCosign(content, key, fetched.content)
+	case SignatureTypeOpenPGP:
+		err = verifyOpenPGP(content, key, fetched.content)
+	default:
+		err = fmt.Errorf("unsupported signature type %q", rule.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("%s signature verification failed for %s: %w", rule.Type, signatureSource.URL, err)
+	}
+	return nil
+}
+
+func (f *sourceFetcher) loadSignatureKey(rule *SignatureRule) ([]byte, error) {
+	if rule.Key != "" {
+		return []byte(rule.Key), nil
+	}
+	location := rule.KeyFile
+	if !filepath.IsAbs(location) {
+		location = filepath.Join(f.rootDir, location)
+	}
+	return os.ReadFile(location)
+}
+
+// verifyMinisign verifies a minisign signature file against a minisign
+// public key (the base64 line of a .pub file, with or without its comment).
+// Both legacy ("Ed") and prehashed ("ED") signatures are accepted, and the
+// trusted comment is checked with the global signature.
+func verifyMinisign(content, key, signature []byte) error {
+	keyBlob, err := decodeBase64(lastNonCommentLine(string(key)))
+	if err != nil || len(keyBlob) != minisignKeyLength || string(keyBlob[:2]) != "Ed" {
+		return errors.New("invalid minisign public key")
+	}
+	publicKey := ed25519.PublicKey(keyBlob[10:])
+
+	lines := nonEmptyLines(string(signature))
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], minisignTrustedPrefix) {
+		return errors.New("invalid minisign signature file")
+	}
+	sigBlob, err := decodeBase64(lines[1])
+	if err != nil || len(sigBlob) != minisignSignatureLength {
+		return errors.New("invalid minisign signature")
+	}
+	if !bytes.Equal(sigBlob[2:10], keyBlob[2:10]) {
+		return errors.New("signature was made with a different key")
+	}
+
+	message := content
+	switch string(sigBlob[:2]) {
+	case "Ed":
+	case "ED":
+		digest := blake2b.Sum512(content)
+		message = digest[:]
+	default:
+		return fmt.Errorf("unsupported minisign algorithm %q", string(sigBlob[:2]))
+	}
+	if !ed25519.Verify(publicKey, message, sigBlob[10:]) {
+		return errors.New("signature mismatch")
+	}
+
+	globalSignature, err := decodeBase64(lines[3])
+	if err != nil || len(globalSignature) != ed25519.SignatureSize {
+		return errors.New("invalid minisign global signature")
+	}
+	trusted := append(append([]byte{}, sigBlob[10:]...), strings.TrimPrefix(lines[2], minisignTrustedPrefix)...)
+	if !ed25519.Verify(publicKey, trusted, globalSignature) {
+		return errors.New("trusted comment signature mismatch")
+	}
+	return nil
+}
+
+// verifyEd25519 verifies a raw or base64 ed25519 signature. The key is a PEM
+// public key or a base64 encoded 32 byte key.
+func verifyEd25519(content, key, signature []byte) error {
+	publicKey, err := parsePublicKey(key)
+	if err != nil {
+		return err
+	}
+	edKey, ok := publicKey.(ed25519.PublicKey)
+	if !ok {
+		return errors.New("key is not an ed25519 public key")
+	}
+	sig := signature
+	if len(sig) != ed25519.SignatureSize {
+		if sig, err = decodeBase64(string(signature)); err != nil {
+			return errors.New("invalid ed25519 signature encoding")
+		}
+	}
+	if !ed25519.Verify(edKey, content, sig) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// verifyCosign verifies a key-based "cosign sign-blob" signature: base64
+// signature output or a bundle with base64Signature.
+func verifyCosign(content, key, signature []byte) error {
+	publicKey, err := parsePublicKey(key)
+	if err != nil {
+		return err
+	}
+	encoded := strings.TrimSpace(string(signature))
+	if strings.HasPrefix(encoded, "{") {
+		var bundle struct {
+			Base64Signature string `json:"base64Signature"`
+		}
+		if err := json.Unmarshal(signature, &bundle); err != nil || bundle.Base64Signature == "" {
+			return errors.New("invalid cosign bundle")
+		}
+		encoded = bundle.Base64Signature
+	}
+	sig, err := decodeBase64(encoded)
+	if err != nil {
+		return errors.New("invalid cosign signature encoding")
+	}
+
+	digest := sha256.Sum256(content)
+	switch typed := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(typed, digest[:], sig) {
+			return errors.New("signature mismatch")
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(typed, crypto.SHA256, digest[:], sig) != nil {
+			return errors.New("signature mismatch")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(typed, content, sig) {
+			return errors.New("signature mismatch")
+		}
+	default:
+		return fmt.Errorf("unsupported cosign key type %T", publicKey)
+	}
+	return nil
+}
+
+// verifyOpenPGP verifies an armored or binary detached OpenPGP signature
+// against an armored or binary public keyring.
+func verifyOpenPGP(content, key, signature []byte) error {
+	var (
+		keyring openpgp.EntityList
+		err     error
+	)
+	if bytes.HasPrefix(bytes.TrimSpace(key), []byte(pgpArmorPrefix)) {
+		keyring, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+	} else {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(key))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid openpgp public key: %w", err)
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte(pgpArmorPrefix)) {
+		_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(content), bytes.NewReader(signature), nil)
+	} else {
+		_, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(content), bytes.NewReader(signature), nil)
+	}
+	return err
+}
+
+func parsePublicKey(key []byte) (crypto.PublicKey, error) {
+	if block, _ := pem.Decode(key); block != nil {
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+		return publicKey, nil
+	}
+	raw, err := decodeBase64(string(key))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, errors.New("public key must be PEM or a base64 encoded ed25519 key")
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+func decodeBase64(value string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+}
+
+func nonEmptyLines(value string) []string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func lastNonCommentLine(value string) string {
+	lines := nonEmptyLines(value)
+	for index := len(lines) - 1; index >= 0; index-- {
+		if !strings.HasPrefix(lines[index], "untrusted comment:") {
+			return lines[index]
+		}
+	}
+	return ""
+}
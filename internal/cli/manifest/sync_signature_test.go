@@ -0,0 +1,175 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"golang.org/x/crypto/blake2b"
+)
+
+type signatureFixture struct {
+	key       string
+	signature []byte
+}
+
+func newMinisignFixture(t *testing.T, content []byte) signatureFixture {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	keyID := []byte("keyid123")
+	keyBlob := append(append([]byte("Ed"), keyID...), publicKey...)
+
+	digest := blake2b.Sum512(content)
+	sig := ed25519.Sign(privateKey, digest[:])
+	sigBlob := append(append([]byte("ED"), keyID...), sig...)
+	trustedComment := "timestamp:1700000000\tfile:tool"
+	global := ed25519.Sign(privateKey, append(append([]byte{}, sig...), trustedComment...))
+
+	signature := strings.Join([]string{
+		"untrusted comment: signature from minisign secret key",
+		base64.StdEncoding.EncodeToString(sigBlob),
+		"trusted comment: " + trustedComment,
+		base64.StdEncoding.EncodeToString(global),
+		"",
+	}, "\n")
+	key := "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(keyBlob) + "\n"
+	return signatureFixture{key: key, signature: []byte(signature)}
+}
+
+func newEd25519Fixture(t *testing.T, content []byte) signatureFixture {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return signatureFixture{
+		key:       base64.StdEncoding.EncodeToString(publicKey),
+		signature: ed25519.Sign(privateKey, content),
+	}
+}
+
+func newCosignFixture(t *testing.T, content []byte) signatureFixture {
+	t.Helper()
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	digest := sha256.Sum256(content)
+	sig, err := ecdsa.SignASN1(rand.Reader, privateKey, digest[:])
+	if err != nil {
+		t.Fatalf("SignASN1: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	key := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	return signatureFixture{key: string(key), signature: []byte(base64.StdEncoding.EncodeToString(sig))}
+}
+
+func newOpenPGPFixture(t *testing.T, content []byte) signatureFixture {
+	t.Helper()
+	entity, err := openpgp.NewEntity("tool", "", "tool@example.com", nil)
+	if err != nil {
+		t.Fatalf("NewEntity: %v", err)
+	}
+	var key bytes.Buffer
+	keyWriter, err := armor.Encode(&key, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatalf("armor.Encode: %v", err)
+	}
+	if err := entity.Serialize(keyWriter); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	if err := keyWriter.Close(); err != nil {
+		t.Fatalf("close armor writer: %v", err)
+	}
+	var signature bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&signature, entity, bytes.NewReader(content), nil); err != nil {
+		t.Fatalf("ArmoredDetachSign: %v", err)
+	}
+	return signatureFixture{key: key.String(), signature: signature.Bytes()}
+}
+
+func TestSyncVerifiesSignatures(t *testing.T) {
+	content := []byte("signed-tool")
+	fixtures := map[string]signatureFixture{
+		SignatureTypeMinisign: newMinisignFixture(t, content),
+		SignatureTypeEd25519:  newEd25519Fixture(t, content),
+		SignatureTypeCosign:   newCosignFixture(t, content),
+		SignatureTypeOpenPGP:  newOpenPGPFixture(t, content),
+	}
+
+	for signatureType, fixture := range fixtures {
+		t.Run(signatureType, func(t *testing.T) {
+			body := content
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/tool.sig" {
+					_, _ = w.Write(fixture.signature)
+					return
+				}
+				_, _ = w.Write(body)
+			}))
+			defer server.Close()
+
+			temp := t.TempDir()
+			keyPath := filepath.Join(temp, "tool.pub")
+			if err := os.WriteFile(keyPath, []byte(fixture.key), 0o644); err != nil {
+				t.Fatalf("write key: %v", err)
+			}
+			cfg := &SyncConfig{
+				Version: "v1",
+				Sources: map[string]Source{
+					"tool": {URL: server.URL + "/tool"},
+					"sig":  {URL: server.URL + "/tool.sig"},
+				},
+				Files: []FileRule{{
+					Source:    "tool",
+					Path:      "bin/tool",
+					Signature: &SignatureRule{Type: signatureType, Source: "sig", KeyFile: "tool.pub"},
+				}},
+			}
+			if _, err := Sync(cfg, SyncOptions{RootDir: temp}); err != nil {
+				t.Fatalf("sync failed: %v", err)
+			}
+
+			body = []byte("tampered-tool")
+			cfg.Files[0].Path = "bin/tool-2"
+			cfg.Files[0].Signature.KeyFile = ""
+			cfg.Files[0].Signature.Key = fixture.key
+			_, err := Sync(cfg, SyncOptions{RootDir: temp})
+			if err == nil || !strings.Contains(err.Error(), "signature verification failed") {
+				t.Fatalf("expected signature verification failure, got %v", err)
+			}
+			if _, statErr := os.Stat(filepath.Join(temp, "bin/tool-2")); !os.IsNotExist(statErr) {
+				t.Fatalf("expected no output for tampered artifact, stat err=%v", statErr)
+			}
+		})
+	}
+}
+
+func TestVerifyMinisignRejectsForeignKey(t *testing.T) {
+	content := []byte("tool")
+	signed := newMinisignFixture(t, content)
+	other := newMinisignFixture(t, content)
+	if err := verifyMinisign(content, []byte(other.key), signed.signature); err == nil {
+		t.Fatalf("expected key mismatch error")
+	}
+}
@@ -0,0 +1,95 @@
+package manifest
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// fetchedArtifact is the raw content of one source before decode/extract.
+type fetchedArtifact struct {
+	content []byte
+	// entries holds several files when the source itself selects a directory
+	// (for example a git directory or glob); content is unused in that case.
+	entries []archiveEntry
+	// checksum is a digest published by the source itself (for example a
+	// release checksums file). It is verified in addition to download_digest.
+	checksum string
+	// name is the upstream file name used to look up download_digest_from
+	// checksum file entries.
+	name string
+	// revision identifies the resolved upstream version (release tag or
+	// commit) and is reported in sync progress.
+	revision string
+}
+
+// sourceFetcher resolves sources into artifacts. One fetcher is used per sync
+// run so lookups shared by several files are performed only once.
+type sourceFetcher struct {
+	rootDir         string
+	cacheDir        string
+	githubReleases  map[string]*githubRelease
+	githubChecksums map[string]map[string]string
+	gitCommits      map[string]string
+	ociManifests    map[string]*ociResolvedManifest
+	ociTokens       map[string]string
+	checksumFiles   map[string]map[string]string
+}
+
+func newSourceFetcher(rootDir, cacheDir string) *sourceFetcher {
+	return &sourceFetcher{
+		rootDir:         rootDir,
+		cacheDir:        cacheDir,
+		githubReleases:  map[string]*githubRelease{},
+		githubChecksums: map[string]map[string]string{},
+		gitCommits:      map[string]string{},
+		ociManifests:    map[string]*ociResolvedManifest{},
+		ociTokens:       map[string]string{},
+		checksumFiles:   map[string]map[string]string{},
+	}
+}
+
+func (f *sourceFetcher) fetch(src Source) (*fetchedArtifact, error) {
+	switch src.Type {
+	case "", RepositoryTypeHTTP:
+		content, err := download(src)
+		if err != nil {
+			return nil, err
+		}
+		return &fetchedArtifact{content: content, name: sourceFileName(src.URL)}, nil
+	case RepositoryTypeGitHub:
+		return f.fetchGitHubReleaseAsset(src)
+	case SourceTypeFile:
+		return f.fetchLocalFile(src)
+	case RepositoryTypeGit:
+		return f.fetchGitSource(src)
+	case RepositoryTypeOCI:
+		return f.fetchOCILayer(src)
+	default:
+		return nil, fmt.Errorf("unsupported source type %q", src.Type)
+	}
+}
+
+// resolveCacheDir returns the directory used for persistent source caches.
+func (f *sourceFetcher) resolveCacheDir() string {
+	if f.cacheDir != "" {
+		return f.cacheDir
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	f.cacheDir = filepath.Join(base, "vorbere")
+	return f.cacheDir
+}
+
+// sourceFileName returns the last path element of a source URL.
+func sourceFileName(location string) string {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return path.Base(location)
+	}
+	return path.Base(parsed.Path)
+}
@@ -0,0 +1,483 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pirakansa/vorbere/internal/cli/shared"
+	"gopkg.in/yaml.v3"
+)
+
+// applyStructuredMerge deep-merges upstream keys into the existing JSON, YAML
+// or TOML document at targetPath. Keys only present locally are kept.
+func applyStructuredMerge(targetPath string, upstream []byte, fileMode string, rule FileRule, opts SyncOptions) (appliedOutput, error) {
+	current, err := os.ReadFile(targetPath)
+	if err != nil && !os.IsNotExist(err) {
+		return appliedOutput{}, err
+	}
+	incoming := upstream
+	if err == nil && len(bytes.TrimSpace(current)) > 0 {
+		incoming, err = mergeStructured(rule.Merge, current, upstream, rule.MergeArrays)
+		if err != nil {
+			return appliedOutput{}, fmt.Errorf("merge %s %s: %w", rule.Merge, targetPath, err)
+		}
+	}
+	outcome, err := applyRule(targetPath, incoming, fileMode, opts)
+	if err != nil {
+		return appliedOutput{}, err
+	}
+	return appliedOutput{
+		outcome: outcome,
+		digest:  DigestAlgorithmSHA256 + ":" + shared.SHA256Hex(incoming),
+		bytes:   int64(len(incoming)),
+	}, nil
+}
+
+// mergeStructured returns local unchanged when upstream adds nothing, so
+// formatting is only normalized when the document actually changes.
+func mergeStructured(format string, local, upstream []byte, arrays string) ([]byte, error) {
+	switch format {
+	case MergeJSON:
+		return mergeJSONDocuments(local, upstream, arrays)
+	case MergeYAML:
+		return mergeYAMLDocuments(local, upstream, arrays)
+	case MergeTOML:
+		return mergeTOMLDocuments(local, upstream, arrays)
+	default:
+		return nil, fmt.Errorf("unsupported merge %q", format)
+	}
+}
+
+func mergeYAMLDocuments(local, upstream []byte, arrays string) ([]byte, error) {
+	localDocs, changed, err := mergeNodeDocuments(local, upstream, arrays)
+	if err != nil || !changed {
+		return local, err
+	}
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(detectIndentWidth(local))
+	for _, document := range localDocs {
+		if err := encoder.Encode(document); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// mergeJSONDocuments parses JSON through yaml.v3 nodes so key order of the
+// local document is kept. Comments and trailing commas (JSONC, as used by
+// VS Code settings) are accepted and dropped when the document changes.
+func mergeJSONDocuments(local, upstream []byte, arrays string) ([]byte, error) {
+	plainLocal, plainUpstream := stripJSONC(local), stripJSONC(upstream)
+	if !json.Valid(plainLocal) {
+		return nil, errors.New("local document is not valid JSON")
+	}
+	if !json.Valid(plainUpstream) {
+		return nil, errors.New("upstream document is not valid JSON")
+	}
+	localDocs, changed, err := mergeNodeDocuments(plainLocal, plainUpstream, arrays)
+	if err != nil || !changed {
+		return local, err
+	}
+	var compact bytes.Buffer
+	if err := writeJSONNode(&compact, localDocs[0]); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, compact.Bytes(), "", detectIndent(local)); err != nil {
+		return nil, err
+	}
+	if bytes.HasSuffix(local, []byte("\n")) {
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// stripJSONC removes // and /* */ comments and trailing commas outside of
+// strings, turning JSONC into plain JSON. Line breaks are kept.
+func stripJSONC(content []byte) []byte {
+	stripped := make([]byte, 0, len(content))
+	inString := false
+	for index := 0; index < len(content); index++ {
+		char := content[index]
+		switch {
+		case inString:
+			stripped = append(stripped, char)
+			if char == '\\' && index+1 < len(content) {
+				index++
+				stripped = append(stripped, content[index])
+			} else if char == '"' {
+				inString = false
+			}
+		case char == '"':
+			inString = true
+			stripped = append(stripped, char)
+		case char == '/' && index+1 < len(content) && content[index+1] == '/':
+			for index < len(content) && content[index] != '\n' {
+				index++
+			}
+			index--
+		case char == '/' && index+1 < len(content) && content[index+1] == '*':
+			end := bytes.Index(content[index+2:], []byte("*/"))
+			if end < 0 {
+				return append(stripped, content[index:]...)
+			}
+			stripped = append(stripped, bytes.Repeat([]byte("\n"), bytes.Count(content[index:index+2+end], []byte("\n")))...)
+			index += end + 3
+		case char == ',' && closesAfterComma(content[index+1:]):
+		default:
+			stripped = append(stripped, char)
+		}
+	}
+	return stripped
+}
+
+// closesAfterComma reports whether the next token after a comma closes an
+// object or array, skipping whitespace and comments.
+func closesAfterComma(rest []byte) bool {
+	for len(rest) > 0 {
+		switch {
+		case rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r' || rest[0] == '\n':
+			rest = rest[1:]
+		case bytes.HasPrefix(rest, []byte("//")):
+			end := bytes.IndexByte(rest, '\n')
+			if end < 0 {
+				return false
+			}
+			rest = rest[end:]
+		case bytes.HasPrefix(rest, []byte("/*")):
+			end := bytes.Index(rest[2:], []byte("*/"))
+			if end < 0 {
+				return false
+			}
+			rest = rest[end+4:]
+		default:
+			return rest[0] == '}' || rest[0] == ']'
+		}
+	}
+	return false
+}
+
+// mergeNodeDocuments merges upstream into the parsed local documents and
+// reports whether a decoded local value changed. Multi-document YAML is
+// merged document by document; upstream documents beyond the local ones are
+// appended and local documents beyond the upstream ones are kept.
+func mergeNodeDocuments(local, upstream []byte, arrays string) ([]*yaml.Node, bool, error) {
+	localDocs, err := decodeYAMLDocuments(local)
+	if err != nil {
+		return nil, false, fmt.Errorf("parse local document: %w", err)
+	}
+	upstreamDocs, err := decodeYAMLDocuments(upstream)
+	if err != nil {
+		return nil, false, fmt.Errorf("parse upstream document: %w", err)
+	}
+	changed := false
+	for index, upstreamDoc := range upstreamDocs {
+		if len(upstreamDoc.Content) == 0 {
+			continue
+		}
+		if index >= len(localDocs) {
+			localDocs = append(localDocs, upstreamDoc)
+			changed = true
+			continue
+		}
+		localDoc := localDocs[index]
+		if len(localDoc.Content) == 0 {
+			localDoc.Content = upstreamDoc.Content
+			changed = true
+			continue
+		}
+		var before any
+		if err := localDoc.Decode(&before); err != nil {
+			return nil, false, err
+		}
+		mergeYAMLNodes(localDoc.Content[0], upstreamDoc.Content[0], arrays)
+		var after any
+		if err := localDoc.Decode(&after); err != nil {
+			return nil, false, err
+		}
+		changed = changed || !reflect.DeepEqual(before, after)
+	}
+	return localDocs, changed, nil
+}
+
+func decodeYAMLDocuments(content []byte) ([]*yaml.Node, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	var documents []*yaml.Node
+	for {
+		var document yaml.Node
+		if err := decoder.Decode(&document); errors.Is(err, io.EOF) {
+			return documents, nil
+		} else if err != nil {
+			return nil, err
+		}
+		documents = append(documents, &document)
+	}
+}
+
+// mergeYAMLNodes merges src into dst in place. Mappings merge by key,
+// sequences follow arrays, and other values are replaced by src.
+func mergeYAMLNodes(dst, src *yaml.Node, arrays string) {
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		for index := 0; index+1 < len(src.Content); index += 2 {
+			key, value := src.Content[index], src.Content[index+1]
+			if existing := yamlMappingValue(dst, key.Value); existing != nil {
+				mergeYAMLNodes(existing, value, arrays)
+				continue
+			}
+			dst.Content = append(dst.Content, key, value)
+		}
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode && arrays != MergeArraysReplace:
+		items := dst.Content
+		if arrays == MergeArraysUnion {
+			items = nil
+			for _, item := range dst.Content {
+				if !containsYAMLNode(items, item) {
+					items = append(items, item)
+				}
+			}
+		}
+		for _, item := range src.Content {
+			if !containsYAMLNode(items, item) {
+				items = append(items, item)
+			}
+		}
+		dst.Content = items
+	default:
+		if equalYAMLNodes(dst, src) {
+			return
+		}
+		// Local comments win; upstream ones are used only where the local
+		// value has none.
+		headComment, lineComment, footComment := dst.HeadComment, dst.LineComment, dst.FootComment
+		*dst = *src
+		if headComment != "" {
+			dst.HeadComment = headComment
+		}
+		if lineComment != "" {
+			dst.LineComment = lineComment
+		}
+		if footComment != "" {
+			dst.FootComment = footComment
+		}
+	}
+}
+
+func yamlMappingValue(node *yaml.Node, key string) *yaml.Node {
+	for index := 0; index+1 < len(node.Content); index += 2 {
+		if node.Content[index].Value == key {
+			return node.Content[index+1]
+		}
+	}
+	return nil
+}
+
+func containsYAMLNode(nodes []*yaml.Node, node *yaml.Node) bool {
+	for _, candidate := range nodes {
+		if equalYAMLNodes(candidate, node) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalYAMLNodes(left, right *yaml.Node) bool {
+	var leftValue, rightValue any
+	if left.Decode(&leftValue) != nil || right.Decode(&rightValue) != nil {
+		return false
+	}
+	return reflect.DeepEqual(leftValue, rightValue)
+}
+
+func writeJSONNode(out *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			out.WriteString("null")
+			return nil
+		}
+		return writeJSONNode(out, node.Content[0])
+	case yaml.MappingNode:
+		out.WriteByte('{')
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			if index > 0 {
+				out.WriteByte(',')
+			}
+			key, _ := json.Marshal(node.Content[index].Value)
+			out.Write(key)
+			out.WriteByte(':')
+			if err := writeJSONNode(out, node.Content[index+1]); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+	case yaml.SequenceNode:
+		out.WriteByte('[')
+		for index, item := range node.Content {
+			if index > 0 {
+				out.WriteByte(',')
+			}
+			if err := writeJSONNode(out, item); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null", "!!bool", "!!int", "!!float":
+			out.WriteString(node.Value)
+		default:
+			value, _ := json.Marshal(node.Value)
+			out.Write(value)
+		}
+	default:
+		return fmt.Errorf("unsupported JSON node at line %d", node.Line)
+	}
+	return nil
+}
+
+func mergeTOMLDocuments(local, upstream []byte, arrays string) ([]byte, error) {
+	var localDoc, upstreamDoc map[string]any
+	if _, err := toml.Decode(string(local), &localDoc); err != nil {
+		return nil, fmt.Errorf("parse local document: %w", err)
+	}
+	if _, err := toml.Decode(string(upstream), &upstreamDoc); err != nil {
+		return nil, fmt.Errorf("parse upstream document: %w", err)
+	}
+	merged := mergeValues(localDoc, upstreamDoc, arrays)
+	if reflect.DeepEqual(merged, any(localDoc)) {
+		return local, nil
+	}
+	// The merged document is re-encoded, which would silently drop them.
+	if hasTOMLComments(local) {
+		return nil, errors.New("local document has comments, which a toml merge cannot keep; remove them or stop merging this file")
+	}
+	var out bytes.Buffer
+	encoder := toml.NewEncoder(&out)
+	encoder.Indent = ""
+	if err := encoder.Encode(merged); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// hasTOMLComments reports whether content has a # comment outside of
+// strings.
+func hasTOMLComments(content []byte) bool {
+	for index := 0; index < len(content); index++ {
+		switch content[index] {
+		case '#':
+			return true
+		case '"', '\'':
+			quote := content[index : index+1]
+			if bytes.HasPrefix(content[index:], bytes.Repeat(quote, 3)) {
+				quote = bytes.Repeat(quote, 3)
+			}
+			index += len(quote)
+			for index < len(content) && !bytes.HasPrefix(content[index:], quote) {
+				if content[index] == '\\' && quote[0] == '"' {
+					index++
+				}
+				index++
+			}
+			index += len(quote) - 1
+		}
+	}
+	return false
+}
+
+// mergeValues is the decoded-value counterpart of mergeYAMLNodes.
+func mergeValues(dst, src any, arrays string) any {
+	dstMap, dstIsMap := dst.(map[string]any)
+	srcMap, srcIsMap := src.(map[string]any)
+	if dstIsMap && srcIsMap {
+		merged := make(map[string]any, len(dstMap)+len(srcMap))
+		for key, value := range dstMap {
+			merged[key] = value
+		}
+		for key, value := range srcMap {
+			if existing, ok := merged[key]; ok {
+				merged[key] = mergeValues(existing, value, arrays)
+				continue
+			}
+			merged[key] = value
+		}
+		return merged
+	}
+	dstItems, dstIsSlice := sliceValues(dst)
+	srcItems, srcIsSlice := sliceValues(src)
+	if !dstIsSlice || !srcIsSlice || arrays == MergeArraysReplace {
+		return src
+	}
+	items := dstItems
+	if arrays == MergeArraysUnion {
+		items = nil
+		for _, item := range dstItems {
+			if !containsValue(items, item) {
+				items = append(items, item)
+			}
+		}
+	}
+	for _, item := range srcItems {
+		if !containsValue(items, item) {
+			items = append(items, item)
+		}
+	}
+	if reflect.DeepEqual(items, dstItems) {
+		return dst
+	}
+	return items
+}
+
+func sliceValues(value any) ([]any, bool) {
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() != reflect.Slice {
+		return nil, false
+	}
+	items := make([]any, reflected.Len())
+	for index := range items {
+		items[index] = reflected.Index(index).Interface()
+	}
+	return items, true
+}
+
+func containsValue(values []any, value any) bool {
+	for _, candidate := range values {
+		if reflect.DeepEqual(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectIndent returns the leading whitespace of the first indented line,
+// defaulting to two spaces.
+func detectIndent(content []byte) string {
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || len(trimmed) == len(line) || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		return line[:len(line)-len(trimmed)]
+	}
+	return "  "
+}
+
+func detectIndentWidth(content []byte) int {
+	indent := detectIndent(content)
+	if strings.Contains(indent, "\t") || len(indent) < 2 {
+		return 2
+	}
+	return len(indent)
+}
@@ -0,0 +1,240 @@
+package manifest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeJSONDocumentsKeepsLocalKeysAndOrder(t *testing.T) {
+	local := `{
+    "name": "api",
+    "scripts": {
+        "local": "make dev",
+        "test": "jest"
+    },
+    "tags": ["a", "b"]
+}
+`
+	upstream := `{"scripts": {"test": "vitest", "lint": "eslint ."}, "tags": ["b", "c"], "version": 2}`
+	cases := []struct {
+		arrays string
+		tags   string
+	}{
+		{arrays: MergeArraysReplace, tags: `"b",
+        "c"`},
+		{arrays: MergeArraysAppend, tags: `"a",
+        "b",
+        "c"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.arrays, func(t *testing.T) {
+			merged, err := mergeJSONDocuments([]byte(local), []byte(upstream), tc.arrays)
+			if err != nil {
+				t.Fatalf("merge failed: %v", err)
+			}
+			want := `{
+    "name": "api",
+    "scripts": {
+        "local": "make dev",
+        "test": "vitest",
+        "lint": "eslint ."
+    },
+    "tags": [
+        ` + tc.tags + `
+    ],
+    "version": 2
+}
+`
+			if string(merged) != want {
+				t.Fatalf("unexpected merge:\n got: %s\nwant: %s", merged, want)
+			}
+		})
+	}
+}
+
+func TestMergeJSONDocumentsLeavesUnchangedDocumentAsIs(t *testing.T) {
+	local := "{ \"a\": 1,   \"b\": [1, 2] }\n"
+	merged, err := mergeJSONDocuments([]byte(local), []byte(`{"b": [2]}`), MergeArraysUnion)
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if string(merged) != local {
+		t.Fatalf("expected local document to be kept byte for byte, got %q", merged)
+	}
+}
+
+func TestMergeJSONDocumentsAcceptsJSONC(t *testing.T) {
+	local := `{
+  // editor defaults
+  "editor.rulers": [80,],
+  /* kept as is */ "url": "https://example.com/a,]",
+}
+`
+	merged, err := mergeJSONDocuments([]byte(local), []byte(`{"editor.rulers": [80], /* same */}`), MergeArraysUnion)
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if string(merged) != local {
+		t.Fatalf("expected local document to be kept byte for byte, got %q", merged)
+	}
+
+	merged, err = mergeJSONDocuments([]byte(local), []byte("{\"tabSize\": 2, // team default\n}"), MergeArraysUnion)
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	want := `{
+  "editor.rulers": [
+    80
+  ],
+  "url": "https://example.com/a,]",
+  "tabSize": 2
+}
+`
+	if string(merged) != want {
+		t.Fatalf("unexpected merge:\n got: %s\nwant: %s", merged, want)
+	}
+
+	if _, err := mergeJSONDocuments([]byte(`{"a": 1,, }`), []byte(`{}`), MergeArraysUnion); err == nil || !strings.Contains(err.Error(), "local document is not valid JSON") {
+		t.Fatalf("expected invalid JSON error, got %v", err)
+	}
+}
+
+func TestMergeYAMLDocumentsPreservesComments(t *testing.T) {
+	local := `# editor settings
+editor:
+  tabSize: 2 # team default
+  rulers: [80]
+local: true
+`
+	upstream := `editor:
+  tabSize: 4
+  rulers: [100]
+  formatOnSave: true
+`
+	merged, err := mergeYAMLDocuments([]byte(local), []byte(upstream), MergeArraysUnion)
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	for _, want := range []string{"# editor settings", "tabSize: 4 # team default", "rulers: [80, 100]", "formatOnSave: true", "local: true"} {
+		if !strings.Contains(string(merged), want) {
+			t.Fatalf("expected %q in merged document:\n%s", want, merged)
+		}
+	}
+}
+
+func TestMergeYAMLDocumentsKeepsLocalLineComments(t *testing.T) {
+	merged, err := mergeYAMLDocuments([]byte("a: 1 # local\nb: 2\n"), []byte("a: 3 # upstream\nb: 4 # added\n"), MergeArraysReplace)
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if want := "a: 3 # local\nb: 4 # added\n"; string(merged) != want {
+		t.Fatalf("expected %q, got %q", want, merged)
+	}
+}
+
+func TestMergeYAMLDocumentsMergesEachDocument(t *testing.T) {
+	merged, err := mergeYAMLDocuments([]byte("a: 1\n---\nb: 2\n"), []byte("c: 3\n"), MergeArraysReplace)
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if want := "a: 1\nc: 3\n---\nb: 2\n"; string(merged) != want {
+		t.Fatalf("expected %q, got %q", want, merged)
+	}
+
+	merged, err = mergeYAMLDocuments([]byte("a: 1\n---\nb: 2\n"), []byte("a: 1\n---\nd: 4\n---\ne: 5\n"), MergeArraysReplace)
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if want := "a: 1\n---\nb: 2\nd: 4\n---\ne: 5\n"; string(merged) != want {
+		t.Fatalf("expected %q, got %q", want, merged)
+	}
+}
+
+func TestMergeTOMLDocuments(t *testing.T) {
+	local := `[tool]
+name = "api"
+features = ["a"]
+`
+	upstream := `[tool]
+features = ["b"]
+edition = "2021"
+`
+	merged, err := mergeTOMLDocuments([]byte(local), []byte(upstream), MergeArraysAppend)
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	for _, want := range []string{`name = "api"`, `features = ["a", "b"]`, `edition = "2021"`} {
+		if !strings.Contains(string(merged), want) {
+			t.Fatalf("expected %q in merged document:\n%s", want, merged)
+		}
+	}
+	again, err := mergeTOMLDocuments(merged, []byte(upstream), MergeArraysAppend)
+	if err != nil {
+		t.Fatalf("second merge failed: %v", err)
+	}
+	if string(again) != string(merged) {
+		t.Fatalf("expected merge to be idempotent:\n%s\n---\n%s", merged, again)
+	}
+}
+
+func TestMergeTOMLDocumentsRejectsLocalComments(t *testing.T) {
+	local := "# pinned by the team\n[tool]\nname = \"api\"\n"
+	if _, err := mergeTOMLDocuments([]byte(local), []byte("[tool]\nedition = \"2021\"\n"), MergeArraysReplace); err == nil || !strings.Contains(err.Error(), "local document has comments") {
+		t.Fatalf("expected comments to be rejected, got %v", err)
+	}
+	merged, err := mergeTOMLDocuments([]byte(local), []byte("[tool]\nname = \"api\"\n"), MergeArraysReplace)
+	if err != nil || string(merged) != local {
+		t.Fatalf("expected an unchanged document to be kept as is, got %q: %v", merged, err)
+	}
+	if _, err := mergeTOMLDocuments([]byte("url = \"https://example.com/#top\"\nq = '#'\n"), []byte("edition = \"2021\"\n"), MergeArraysReplace); err != nil {
+		t.Fatalf("expected # inside strings to be accepted, got %v", err)
+	}
+}
+
+func TestMergeTOMLDocumentsUnionDeduplicatesLocalItems(t *testing.T) {
+	merged, err := mergeTOMLDocuments([]byte("a = [1, 1]\n"), []byte("a = [2]\n"), MergeArraysUnion)
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if want := "a = [1, 2]\n"; string(merged) != want {
+		t.Fatalf("expected %q, got %q", want, merged)
+	}
+}
+
+func TestSyncStructuredMergeIsIdempotent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"editor.formatOnSave": true}`))
+	}))
+	defer server.Close()
+
+	temp := t.TempDir()
+	target := filepath.Join(temp, "settings.json")
+	if err := os.WriteFile(target, []byte("{\n  \"files.eol\": \"\\n\"\n}\n"), 0o644); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{"src": {URL: server.URL}},
+		Files:   []FileRule{{Source: "src", Path: "settings.json", Merge: MergeJSON, MergeArrays: MergeArraysReplace}},
+	}
+	opts := SyncOptions{RootDir: temp, Overwrite: true}
+	res, err := Sync(cfg, opts)
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	got, _ := os.ReadFile(target)
+	if res.Updated != 1 || string(got) != "{\n  \"files.eol\": \"\\n\",\n  \"editor.formatOnSave\": true\n}\n" {
+		t.Fatalf("unexpected merge result %+v: %q", res, got)
+	}
+	res, err = Sync(cfg, opts)
+	if err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	if res.Unchanged != 1 {
+		t.Fatalf("expected unchanged on second sync, got %+v", res)
+	}
+}
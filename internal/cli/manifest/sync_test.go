@@ -227,6 +227,35 @@ func TestSyncReportsPerFileProgress(t *testing.T) {
 	}
 }
 
+func TestSyncResultRecordsDigestsAndBytes(t *testing.T) {
+	payload := []byte("decoded-content")
+	artifact := mustEncodeZstd(t, payload)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(artifact)
+	}))
+	defer server.Close()
+
+	cfg := &SyncConfig{
+		Version: "v1",
+		Sources: map[string]Source{"src": {URL: server.URL}},
+		Files:   []FileRule{{Source: "src", Path: "out.txt", Encoding: EncodingZstd}},
+	}
+	res, err := Sync(cfg, SyncOptions{RootDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if len(res.Files) != 1 {
+		t.Fatalf("expected 1 file result, got %d", len(res.Files))
+	}
+	file := res.Files[0]
+	if file.DownloadDigest != "sha256:"+shared.SHA256Hex(artifact) || file.DownloadBytes != int64(len(artifact)) {
+		t.Fatalf("unexpected download digest or size: %#v", file)
+	}
+	if file.OutputDigest != "sha256:"+shared.SHA256Hex(payload) || file.OutputBytes != int64(len(payload)) {
+		t.Fatalf("unexpected output digest or size: %#v", file)
+	}
+}
+
 func TestSyncDigestValidationForDownloadedArtifactWithZstd(t *testing.T) {
 	payload := []byte("decoded-content")
 	artifact := mustEncodeZstd(t, payload)
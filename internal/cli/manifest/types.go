@@ -4,18 +4,47 @@ import pkgmanifest "github.com/pirakansa/vorbere/pkg/manifest"
 
 type TaskConfig = pkgmanifest.TaskConfig
 type TaskDef = pkgmanifest.TaskDef
+type VarDef = pkgmanifest.VarDef
 type Repository = pkgmanifest.Repository
 type RepositoryFile = pkgmanifest.RepositoryFile
 type SymlinkSpec = pkgmanifest.SymlinkSpec
 type SyncConfig = pkgmanifest.SyncConfig
 type Source = pkgmanifest.Source
+type GitHubReleaseSource = pkgmanifest.GitHubReleaseSource
+type GitSource = pkgmanifest.GitSource
+type OCISource = pkgmanifest.OCISource
 type FileRule = pkgmanifest.FileRule
+type SyncFilter = pkgmanifest.SyncFilter
+type Digests = pkgmanifest.Digests
+type SignatureSpec = pkgmanifest.SignatureSpec
+type SignatureRule = pkgmanifest.SignatureRule
+type BlockRule = pkgmanifest.BlockRule
 
 const (
-	EncodingZstd          = pkgmanifest.EncodingZstd
-	EncodingTarGzip       = pkgmanifest.EncodingTarGzip
-	EncodingTarXz         = pkgmanifest.EncodingTarXz
-	DigestAlgorithmBLAKE3 = pkgmanifest.DigestAlgorithmBLAKE3
-	DigestAlgorithmSHA256 = pkgmanifest.DigestAlgorithmSHA256
-	DigestAlgorithmMD5    = pkgmanifest.DigestAlgorithmMD5
+	EncodingZstd           = pkgmanifest.EncodingZstd
+	EncodingTarGzip        = pkgmanifest.EncodingTarGzip
+	EncodingTarXz          = pkgmanifest.EncodingTarXz
+	DigestAlgorithmBLAKE3  = pkgmanifest.DigestAlgorithmBLAKE3
+	DigestAlgorithmSHA256  = pkgmanifest.DigestAlgorithmSHA256
+	DigestAlgorithmSHA384  = pkgmanifest.DigestAlgorithmSHA384
+	DigestAlgorithmSHA512  = pkgmanifest.DigestAlgorithmSHA512
+	DigestAlgorithmSHA1    = pkgmanifest.DigestAlgorithmSHA1
+	DigestAlgorithmMD5     = pkgmanifest.DigestAlgorithmMD5
+	RepositoryTypeHTTP     = pkgmanifest.RepositoryTypeHTTP
+	RepositoryTypeGitHub   = pkgmanifest.RepositoryTypeGitHub
+	RepositoryTypeGit      = pkgmanifest.RepositoryTypeGit
+	RepositoryTypeOCI      = pkgmanifest.RepositoryTypeOCI
+	SourceTypeFile         = pkgmanifest.SourceTypeFile
+	GitHubReleaseTagLatest = pkgmanifest.GitHubReleaseTagLatest
+	SignatureTypeMinisign  = pkgmanifest.SignatureTypeMinisign
+	SignatureTypeEd25519   = pkgmanifest.SignatureTypeEd25519
+	SignatureTypeCosign    = pkgmanifest.SignatureTypeCosign
+	SignatureTypeOpenPGP   = pkgmanifest.SignatureTypeOpenPGP
+	MergeThreeWay          = pkgmanifest.MergeThreeWay
+	MergeJSON              = pkgmanifest.MergeJSON
+	MergeYAML              = pkgmanifest.MergeYAML
+	MergeTOML              = pkgmanifest.MergeTOML
+	MergeArraysReplace     = pkgmanifest.MergeArraysReplace
+	MergeArraysAppend      = pkgmanifest.MergeArraysAppend
+	MergeArraysUnion       = pkgmanifest.MergeArraysUnion
 )
@@ -0,0 +1,211 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	pkgmanifest "github.com/pirakansa/vorbere/pkg/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	repositoryPathPattern = regexp.MustCompile(`^repositories\[(\d+)\]`)
+	syncAfterPathPattern  = regexp.MustCompile(`^sync\.after\[(\d+)\]`)
+	taskPathPattern       = regexp.MustCompile(`^tasks\.(.+?)(\.(?:run|desc|env|cwd|depends_on)\b.*)?$`)
+)
+
+// ValidationIssue is one problem reported by ValidateConfig. Path is the
+// field path inside File, which differs from the merged config for included
+// entries. Line and Column locate the offending yaml node in File; they are 0
+// when the node cannot be found, for example for a missing field.
+type ValidationIssue struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (i ValidationIssue) String() string {
+	location := i.File
+	if i.Line > 0 {
+		location = fmt.Sprintf("%s:%d:%d", i.File, i.Line, i.Column)
+	}
+	return fmt.Sprintf("%s: %s %s", location, i.Path, i.Message)
+}
+
+// ValidateConfig loads the config at path and reports every problem found
+// while resolving vars, expanding templates, building the sync config and
+// checking task references. Errors that prevent loading, such as invalid
+// yaml, are returned as err.
+func ValidateConfig(path string, opts LoadOptions) ([]ValidationIssue, error) {
+	cfg, varsOpts, expandOpts, err := readTaskConfig(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	issues, err := pkgmanifest.ResolveTaskConfigIssues(cfg, varsOpts, expandOpts)
+	if err != nil {
+		return nil, err
+	}
+	// A field that failed to expand still holds its template, so checks of
+	// the same field would only repeat the problem.
+	for _, issue := range pkgmanifest.CheckTaskConfig(cfg, syncBuildOptions(path, SyncFilter{})) {
+		if !coveredByIssues(issues, issue) {
+			issues = append(issues, issue)
+		}
+	}
+	if len(issues) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+
+	locator := &issueLocator{root: path, cfg: cfg, documents: map[string]*yaml.Node{}}
+	out := make([]ValidationIssue, 0, len(issues))
+	for _, issue := range issues {
+		out = append(out, locator.locate(issue))
+	}
+	return out, nil
+}
+
+func coveredByIssues(issues []pkgmanifest.ConfigIssue, issue pkgmanifest.ConfigIssue) bool {
+	for _, existing := range issues {
+		rest, ok := strings.CutPrefix(issue.Path, existing.Path)
+		if ok && (rest == "" || rest[0] == '.' || rest[0] == '[') {
+			return true
+		}
+	}
+	return false
+}
+
+// issueLocator maps field paths of the merged config back to the yaml nodes
+// of the file each entry was declared in.
+type issueLocator struct {
+	root      string
+	cfg       *TaskConfig
+	documents map[string]*yaml.Node
+}
+
+func (l *issueLocator) locate(issue pkgmanifest.ConfigIssue) ValidationIssue {
+	file := issue.Origin
+	if file == "" {
+		file = l.root
+	}
+	located := ValidationIssue{File: file, Path: issue.Path, Message: issue.Message}
+	document := l.document(file)
+	if document == nil {
+		return located
+	}
+	path, ok := l.originPath(issue, document)
+	if !ok {
+		return located
+	}
+	located.Path = path
+	if position, _ := findYAMLPath(document, path); position != nil {
+		located.Line, located.Column = position.Line, position.Column
+	}
+	return located
+}
+
+// originPath rewrites the merged field path to the one in the declaring
+// file: included repositories shift indexes, namespaces prefix task names
+// and sync.after lists of includes come first.
+func (l *issueLocator) originPath(issue pkgmanifest.ConfigIssue, document *yaml.Node) (string, bool) {
+	path := issue.Path
+	if match := repositoryPathPattern.FindStringSubmatch(path); match != nil {
+		index, _ := strconv.Atoi(match[1])
+		local := pkgmanifest.OriginRepositoryIndex(l.cfg.Repositories, index)
+		return fmt.Sprintf("repositories[%d]", local) + path[len(match[0]):], true
+	}
+	if match := syncAfterPathPattern.FindStringSubmatch(path); match != nil {
+		index, _ := strconv.Atoi(match[1])
+		declared := 0
+		if _, after := findYAMLPath(document, "sync.after"); after != nil && after.Kind == yaml.SequenceNode {
+			declared = len(after.Content)
+		}
+		index -= len(l.cfg.Sync.After) - declared
+		if index < 0 {
+			return "", false
+		}
+		return fmt.Sprintf("sync.after[%d]", index) + path[len(match[0]):], true
+	}
+	if match := taskPathPattern.FindStringSubmatch(path); match != nil && issue.Origin != "" {
+		if _, name, ok := strings.Cut(match[1], pkgmanifest.IncludeNamespaceSeparator); ok && !hasYAMLPath(document, "tasks."+match[1]) {
+			return "tasks." + name + match[2], true
+		}
+	}
+	return path, true
+}
+
+func (l *issueLocator) document(location string) *yaml.Node {
+	if document, ok := l.documents[location]; ok {
+		return document
+	}
+	var document *yaml.Node
+	if content, err := readConfig(location); err == nil {
+		var node yaml.Node
+		if yaml.Unmarshal(content, &node) == nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+			document = node.Content[0]
+		}
+	}
+	l.documents[location] = document
+	return document
+}
+
+// findYAMLPath resolves a field path such as tasks.ci.depends_on[0].
+// position is the key node of mapping entries and the item node of sequence
+// entries, value the node the path refers to. When the path leaves the
+// document, position is the last node reached, so a missing field points at
+// its parent, and value is nil.
+func findYAMLPath(node *yaml.Node, path string) (position, value *yaml.Node) {
+	for path != "" {
+		path = strings.TrimPrefix(path, ".")
+		if strings.HasPrefix(path, "[") {
+			end := strings.Index(path, "]")
+			if end < 0 || node.Kind != yaml.SequenceNode {
+				return position, nil
+			}
+			index, err := strconv.Atoi(path[1:end])
+			if err != nil || index >= len(node.Content) {
+				return position, nil
+			}
+			node = node.Content[index]
+			position = node
+			path = path[end+1:]
+			continue
+		}
+		if node.Kind != yaml.MappingNode {
+			return position, nil
+		}
+		// Keys may contain dots, so the longest key matching the path wins.
+		var key, next *yaml.Node
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			candidate := node.Content[index].Value
+			rest, ok := strings.CutPrefix(path, candidate)
+			if !ok || (rest != "" && rest[0] != '.' && rest[0] != '[') {
+				continue
+			}
+			if key == nil || len(candidate) > len(key.Value) {
+				key, next = node.Content[index], node.Content[index+1]
+			}
+		}
+		if key == nil {
+			return position, nil
+		}
+		position, node = key, next
+		path = path[len(key.Value):]
+	}
+	return position, node
+}
+
+func hasYAMLPath(document *yaml.Node, path string) bool {
+	_, value := findYAMLPath(document, path)
+	return value != nil
+}
+
+// JSONSchema returns the JSON Schema of vorbere.yaml.
+func JSONSchema() map[string]any {
+	return pkgmanifest.JSONSchema()
+}
@@ -0,0 +1,147 @@
+package manifest
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidateConfigReportsPositions(t *testing.T) {
+	temp := t.TempDir()
+	included := filepath.Join(temp, "shared", "lint.yaml")
+	writeConfigFile(t, included, `tasks:
+  lint:
+    depends_on: [fmt]
+repositories:
+  - url: https://example.com/
+    files:
+      - file_name: lint.txt
+        out_dir: .
+        encoding: bogus
+sync:
+  after: [lint]
+`)
+	root := filepath.Join(temp, "vorbere.yaml")
+	writeConfigFile(t, root, `version: 1
+includes:
+  - path: shared/lint.yaml
+    namespace: tools
+tasks:
+  ci:
+    depends_on: [build, tools:lint]
+  empty: {}
+repositories:
+  - url: https://example.com/
+    files:
+      - file_name: a.txt
+sync:
+  after: [ci, missing]
+`)
+
+	issues, err := ValidateConfig(root, LoadOptions{})
+	if err != nil {
+		t.Fatalf("ValidateConfig returned error: %v", err)
+	}
+	got := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		got = append(got, strings.ReplaceAll(issue.String(), temp+string(filepath.Separator), ""))
+	}
+	want := []string{
+		`shared/lint.yaml:9:9: repositories[0].files[0].encoding must be one of "zstd", "tar+gzip", "tar+xz"`,
+		`vorbere.yaml:12:9: repositories[0].files[0].out_dir is required`,
+		`vorbere.yaml:14:15: sync.after[1] references undefined task "missing"`,
+		`vorbere.yaml:7:18: tasks.ci.depends_on[0] references undefined task "build"`,
+		`vorbere.yaml:8:3: tasks.empty must have run or depends_on`,
+		`shared/lint.yaml:3:18: tasks.lint.depends_on[0] references undefined task "fmt"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected issues:\n got: %s\nwant: %s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestValidateConfigReportsEveryExpansionError(t *testing.T) {
+	temp := t.TempDir()
+	writeConfigFile(t, filepath.Join(temp, "base.yaml"), `repositories:
+  - url: https://example.com/
+    files:
+      - file_name: base.txt
+        out_dir: .
+`)
+	writeConfigFile(t, filepath.Join(temp, "tools.yaml"), `repositories:
+  - url: https://example.com/
+    files:
+      - file_name: ${{ .vars.TOOL }}
+        out_dir: .
+`)
+	root := filepath.Join(temp, "vorbere.yaml")
+	writeConfigFile(t, root, `version: 1
+includes: [base.yaml, tools.yaml]
+vars:
+  both:
+    value: a
+    sh: echo b
+  token:
+    env: VORBERE_TEST_UNSET_TOKEN
+tasks:
+  zeta:
+    run: echo ${{ .vars.ZETA }}
+  alpha:
+    run: echo ${{ if }}
+    env:
+      NAME: ${{ .vars.NAME }}
+repositories:
+  - url: https://example.com/${{ .vars.REPO }}
+    files:
+      - file_name: a.txt
+        out_dir: .
+        encoding: ${{ .vars.ENCODING }}
+`)
+
+	issues, err := ValidateConfig(root, LoadOptions{})
+	if err != nil {
+		t.Fatalf("ValidateConfig returned error: %v", err)
+	}
+	got := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		got = append(got, strings.ReplaceAll(issue.String(), temp+string(filepath.Separator), ""))
+	}
+	want := []string{
+		`tools.yaml:4:9: repositories[0].files[0].file_name references undefined var(s): TOOL`,
+		`vorbere.yaml:21:9: repositories[0].files[0].encoding references undefined var(s): ENCODING`,
+		`vorbere.yaml:17:5: repositories[0].url references undefined var(s): REPO`,
+		`vorbere.yaml:15:7: tasks.alpha.env.NAME references undefined var(s): NAME`,
+		`vorbere.yaml:13:5: tasks.alpha.run invalid template: template: tasks.alpha.run:1: missing value for if`,
+		`vorbere.yaml:11:5: tasks.zeta.run references undefined var(s): ZETA`,
+		`vorbere.yaml:4:3: vars.both must not set both value and sh`,
+		`vorbere.yaml:7:3: vars.token env VORBERE_TEST_UNSET_TOKEN is not set and no default is given`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected issues:\n got: %s\nwant: %s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestValidateConfigReturnsLoadErrors(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "vorbere.yaml")
+	writeConfigFile(t, root, "version: 1\ntasks:\n  build:\n    runn: go build\n")
+	if _, err := ValidateConfig(root, LoadOptions{}); err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Fatalf("expected decode error with line number, got %v", err)
+	}
+}
+
+func TestFindYAMLPathPrefersLongestKey(t *testing.T) {
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte("tasks:\n  build:\n    run: a\n  build.linux:\n    depends_on: [build]\n"), &node); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	document := node.Content[0]
+	position, value := findYAMLPath(document, "tasks.build.linux.depends_on[0]")
+	if position == nil || value == nil || position.Line != 5 || position.Column != 18 {
+		t.Fatalf("unexpected position: %+v", position)
+	}
+	position, value = findYAMLPath(document, "tasks.build.cwd")
+	if position == nil || value != nil || position.Line != 2 {
+		t.Fatalf("expected missing field to point at its parent, got %+v", position)
+	}
+}
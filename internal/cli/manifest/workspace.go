@@ -0,0 +1,84 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	pkgmanifest "github.com/pirakansa/vorbere/pkg/manifest"
+)
+
+// Workspace is a directory matched by the top-level workspaces patterns that
+// holds its own manifest.
+type Workspace struct {
+	Name       string
+	Dir        string
+	ConfigPath string
+}
+
+// Load reads the workspace manifest.
+func (w Workspace) Load(opts LoadOptions) (*TaskConfig, error) {
+	cfg, err := LoadTaskConfigWithOptions(w.ConfigPath, opts)
+	if err != nil {
+		return nil, fmt.Errorf("workspace %q: %w", w.Name, err)
+	}
+	return cfg, nil
+}
+
+// ResolveWorkspaces expands the workspaces patterns relative to rootDir.
+// Matches without one of ConfigFileNames are ignored. Workspaces are named
+// after their directory and sorted by name.
+func ResolveWorkspaces(cfg *TaskConfig, rootDir string) ([]Workspace, error) {
+	seen := map[string]Workspace{}
+	for index, pattern := range cfg.Workspaces {
+		fieldPath := fmt.Sprintf("workspaces[%d]", index)
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			return nil, fmt.Errorf("%s must not be empty", fieldPath)
+		}
+		if filepath.IsAbs(pattern) {
+			return nil, fmt.Errorf("%s must be relative to the config directory", fieldPath)
+		}
+		matches, err := filepath.Glob(filepath.Join(rootDir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", fieldPath, err)
+		}
+		for _, dir := range matches {
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() || dir == rootDir {
+				continue
+			}
+			configPath, err := findConfigFile(dir)
+			if err != nil {
+				return nil, err
+			}
+			if configPath == "" {
+				continue
+			}
+			workspace := Workspace{Name: filepath.Base(dir), Dir: dir, ConfigPath: configPath}
+			if existing, ok := seen[workspace.Name]; ok {
+				if existing.Dir != workspace.Dir {
+					return nil, fmt.Errorf("%s: workspaces %s and %s share the name %q", fieldPath, existing.Dir, workspace.Dir, workspace.Name)
+				}
+				continue
+			}
+			seen[workspace.Name] = workspace
+		}
+	}
+	workspaces := make([]Workspace, 0, len(seen))
+	for _, workspace := range seen {
+		workspaces = append(workspaces, workspace)
+	}
+	sort.Slice(workspaces, func(i, j int) bool { return workspaces[i].Name < workspaces[j].Name })
+	return workspaces, nil
+}
+
+// SplitWorkspaceTask splits "<workspace>:<task>" at the first separator.
+func SplitWorkspaceTask(name string) (string, string, bool) {
+	workspace, task, ok := strings.Cut(name, pkgmanifest.IncludeNamespaceSeparator)
+	if !ok || workspace == "" || task == "" {
+		return "", "", false
+	}
+	return workspace, task, true
+}
@@ -0,0 +1,51 @@
+package manifest
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveWorkspacesMatchesDirectoriesWithManifest(t *testing.T) {
+	temp := t.TempDir()
+	writeConfigFile(t, filepath.Join(temp, "services", "web", "vorbere.yaml"), "tasks: {}\n")
+	writeConfigFile(t, filepath.Join(temp, "services", "api", "vorbere.yaml"), "tasks: {}\n")
+	writeConfigFile(t, filepath.Join(temp, "services", "docs", "README.md"), "no manifest\n")
+
+	workspaces, err := ResolveWorkspaces(&TaskConfig{Workspaces: []string{"services/*"}}, temp)
+	if err != nil {
+		t.Fatalf("ResolveWorkspaces returned error: %v", err)
+	}
+	if len(workspaces) != 2 || workspaces[0].Name != "api" || workspaces[1].Name != "web" {
+		t.Fatalf("unexpected workspaces: %+v", workspaces)
+	}
+	if workspaces[0].Dir != filepath.Join(temp, "services", "api") {
+		t.Fatalf("unexpected workspace dir: %s", workspaces[0].Dir)
+	}
+}
+
+func TestResolveWorkspacesRejectsDuplicateNames(t *testing.T) {
+	temp := t.TempDir()
+	writeConfigFile(t, filepath.Join(temp, "services", "api", "vorbere.yaml"), "tasks: {}\n")
+	writeConfigFile(t, filepath.Join(temp, "tools", "api", "vorbere.yaml"), "tasks: {}\n")
+
+	_, err := ResolveWorkspaces(&TaskConfig{Workspaces: []string{"services/*", "tools/*"}}, temp)
+	if err == nil || !strings.Contains(err.Error(), `workspaces[1]: workspaces`) || !strings.Contains(err.Error(), `share the name "api"`) {
+		t.Fatalf("expected duplicate name error, got %v", err)
+	}
+
+	_, err = ResolveWorkspaces(&TaskConfig{Workspaces: []string{"/abs"}}, temp)
+	if err == nil || err.Error() != "workspaces[0] must be relative to the config directory" {
+		t.Fatalf("expected absolute pattern error, got %v", err)
+	}
+}
+
+func TestSplitWorkspaceTask(t *testing.T) {
+	workspace, task, ok := SplitWorkspaceTask("api:lint:go")
+	if !ok || workspace != "api" || task != "lint:go" {
+		t.Fatalf("unexpected split: %q %q %v", workspace, task, ok)
+	}
+	if _, _, ok := SplitWorkspaceTask("test"); ok {
+		t.Fatalf("expected plain task name not to split")
+	}
+}
@@ -6,4 +6,6 @@ const (
 	ExitTaskUndefined = 4
 	ExitTaskFailed    = 5
 	ExitSyncFailed    = 6
+	ExitHookFailed    = 7
+	ExitSyncConflict  = 8
 )
@@ -2,7 +2,9 @@ package shared
 
 import (
 	"crypto/md5"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 
 	"github.com/zeebo/blake3"
@@ -14,6 +16,18 @@ func SHA256Hex(content []byte) string {
 	return hex.EncodeToString(sum[:])
 }
 
+// SHA384Hex returns lowercase hex encoded digest for content.
+func SHA384Hex(content []byte) string {
+	sum := sha512.Sum384(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// SHA512Hex returns lowercase hex encoded digest for content.
+func SHA512Hex(content []byte) string {
+	sum := sha512.Sum512(content)
+	return hex.EncodeToString(sum[:])
+}
+
 // BLAKE3Hex returns lowercase hex encoded digest for content.
 func BLAKE3Hex(content []byte) string {
 	sum := blake3.Sum256(content)
@@ -25,3 +39,9 @@ func MD5Hex(content []byte) string {
 	sum := md5.Sum(content)
 	return hex.EncodeToString(sum[:])
 }
+
+// SHA1Hex returns lowercase hex encoded digest for content.
+func SHA1Hex(content []byte) string {
+	sum := sha1.Sum(content)
+	return hex.EncodeToString(sum[:])
+}
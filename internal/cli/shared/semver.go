@@ -0,0 +1,259 @@
+package shared
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version. Build metadata is discarded.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
+
+// VersionConstraint is a parsed semver range such as "^1.2", "~1.4.0",
+// ">=1.0.0 <2.0.0" or "1.x || 2.x".
+type VersionConstraint struct {
+	alternatives [][]versionComparator
+}
+
+type versionComparator struct {
+	op      string
+	version Version
+}
+
+// ParseVersion parses "1.2.3", "v1.2.3-rc.1" and partial forms such as "v1.2".
+func ParseVersion(value string) (Version, error) {
+	parts, prerelease, err := parsePartialVersion(value)
+	if err != nil {
+		return Version{}, err
+	}
+	if len(parts) == 0 {
+		return Version{}, fmt.Errorf("invalid version %q", value)
+	}
+	return fillVersion(parts, prerelease), nil
+}
+
+// CompareVersions returns -1, 0 or 1 when left is lower, equal or higher than right.
+func CompareVersions(left, right Version) int {
+	for _, pair := range [][2]int{{left.Major, right.Major}, {left.Minor, right.Minor}, {left.Patch, right.Patch}} {
+		switch {
+		case pair[0] < pair[1]:
+			return -1
+		case pair[0] > pair[1]:
+			return 1
+		}
+	}
+	switch {
+	case left.Prerelease == right.Prerelease:
+		return 0
+	case left.Prerelease == "":
+		return 1
+	case right.Prerelease == "":
+		return -1
+	default:
+		return comparePrereleases(left.Prerelease, right.Prerelease)
+	}
+}
+
+// comparePrereleases compares dot-separated identifiers in order (SemVer
+// §11): numeric identifiers numerically and below alphanumeric ones, others
+// as strings. A prefix of the other has lower precedence.
+func comparePrereleases(left, right string) int {
+	leftIDs, rightIDs := strings.Split(left, "."), strings.Split(right, ".")
+	for index := 0; index < len(leftIDs) && index < len(rightIDs); index++ {
+		leftNumber, leftErr := strconv.ParseUint(leftIDs[index], 10, 64)
+		rightNumber, rightErr := strconv.ParseUint(rightIDs[index], 10, 64)
+		switch {
+		case leftErr == nil && rightErr == nil:
+			if leftNumber != rightNumber {
+				return cmp.Compare(leftNumber, rightNumber)
+			}
+		case leftErr == nil:
+			return -1
+		case rightErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(leftIDs[index], rightIDs[index]); c != 0 {
+				return c
+			}
+		}
+	}
+	return cmp.Compare(len(leftIDs), len(rightIDs))
+}
+
+// IsVersionConstraint reports whether value looks like a range rather than a
+// literal version or tag name.
+func IsVersionConstraint(value string) bool {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return false
+	}
+	if strings.ContainsAny(trimmed[:1], "^~<>=*") || strings.ContainsAny(trimmed, " ,|") {
+		return true
+	}
+	for _, part := range strings.Split(strings.TrimPrefix(trimmed, "v"), ".") {
+		if part == "x" || part == "X" || part == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseVersionConstraint parses a semver range. Comparators separated by
+// spaces or commas must all match; "||" separates alternatives.
+func ParseVersionConstraint(value string) (*VersionConstraint, error) {
+	constraint := &VersionConstraint{}
+	for _, alternative := range strings.Split(value, "||") {
+		fields := strings.Fields(strings.ReplaceAll(alternative, ",", " "))
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid version constraint %q", value)
+		}
+		var comparators []versionComparator
+		for _, field := range fields {
+			parsed, err := parseVersionComparator(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version constraint %q: %w", value, err)
+			}
+			comparators = append(comparators, parsed...)
+		}
+		constraint.alternatives = append(constraint.alternatives, comparators)
+	}
+	return constraint, nil
+}
+
+// Check reports whether version satisfies the constraint.
+func (c *VersionConstraint) Check(version Version) bool {
+	for _, comparators := range c.alternatives {
+		matched := true
+		for _, comparator := range comparators {
+			if !comparator.check(version) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (c versionComparator) check(version Version) bool {
+	cmp := CompareVersions(version, c.version)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case "!=":
+		return cmp != 0
+	default:
+		return cmp == 0
+	}
+}
+
+func parseVersionComparator(field string) ([]versionComparator, error) {
+	op := ""
+	for _, candidate := range []string{">=", "<=", "!=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(field, candidate) {
+			op = candidate
+			break
+		}
+	}
+	parts, prerelease, err := parsePartialVersion(strings.TrimPrefix(field, op))
+	if err != nil {
+		return nil, err
+	}
+	lower := fillVersion(parts, prerelease)
+
+	switch op {
+	case ">", ">=", "<", "<=", "!=":
+		return []versionComparator{{op: op, version: lower}}, nil
+	case "^":
+		return rangeComparators(lower, caretUpperBound(parts, lower)), nil
+	case "~":
+		if len(parts) <= 1 {
+			return rangeComparators(lower, &Version{Major: lower.Major + 1}), nil
+		}
+		return rangeComparators(lower, &Version{Major: lower.Major, Minor: lower.Minor + 1}), nil
+	}
+
+	switch len(parts) {
+	case 0:
+		return []versionComparator{{op: ">=", version: Version{}}}, nil
+	case 1:
+		return rangeComparators(lower, &Version{Major: lower.Major + 1}), nil
+	case 2:
+		return rangeComparators(lower, &Version{Major: lower.Major, Minor: lower.Minor + 1}), nil
+	default:
+		return []versionComparator{{op: "=", version: lower}}, nil
+	}
+}
+
+func caretUpperBound(parts []int, lower Version) *Version {
+	switch {
+	case lower.Major > 0 || len(parts) <= 1:
+		return &Version{Major: lower.Major + 1}
+	case lower.Minor > 0 || len(parts) == 2:
+		return &Version{Minor: lower.Minor + 1}
+	default:
+		return &Version{Patch: lower.Patch + 1}
+	}
+}
+
+func rangeComparators(lower Version, upper *Version) []versionComparator {
+	comparators := []versionComparator{{op: ">=", version: lower}}
+	if upper != nil {
+		comparators = append(comparators, versionComparator{op: "<", version: *upper})
+	}
+	return comparators
+}
+
+// parsePartialVersion returns the numeric components present in value. A
+// wildcard component ("x", "X", "*") ends the list.
+func parsePartialVersion(value string) ([]int, string, error) {
+	raw := strings.TrimPrefix(strings.TrimSpace(value), "v")
+	if raw == "" {
+		return nil, "", fmt.Errorf("invalid version %q", value)
+	}
+	raw, _, _ = strings.Cut(raw, "+")
+	core, prerelease, _ := strings.Cut(raw, "-")
+	var parts []int
+	for index, part := range strings.Split(core, ".") {
+		if index >= 3 {
+			return nil, "", fmt.Errorf("invalid version %q", value)
+		}
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return nil, "", fmt.Errorf("invalid version %q", value)
+		}
+		parts = append(parts, number)
+	}
+	return parts, prerelease, nil
+}
+
+func fillVersion(parts []int, prerelease string) Version {
+	version := Version{Prerelease: prerelease}
+	if len(parts) > 0 {
+		version.Major = parts[0]
+	}
+	if len(parts) > 1 {
+		version.Minor = parts[1]
+	}
+	if len(parts) > 2 {
+		version.Patch = parts[2]
+	}
+	return version
+}
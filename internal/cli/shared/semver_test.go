@@ -0,0 +1,90 @@
+package shared
+
+import "testing"
+
+func TestVersionConstraintCheck(t *testing.T) {
+	cases := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{constraint: "^1.2", version: "v1.9.0", want: true},
+		{constraint: "^1.2", version: "v2.0.0", want: false},
+		{constraint: "^1.2", version: "v1.1.9", want: false},
+		{constraint: "^0.3.1", version: "0.3.9", want: true},
+		{constraint: "^0.3.1", version: "0.4.0", want: false},
+		{constraint: "~1.4.0", version: "1.4.7", want: true},
+		{constraint: "~1.4.0", version: "1.5.0", want: false},
+		{constraint: ">=1.0.0 <2.0.0", version: "1.99.0", want: true},
+		{constraint: ">=1.0.0, <2.0.0", version: "2.0.0", want: false},
+		{constraint: "1.x || 3.x", version: "3.1.0", want: true},
+		{constraint: "1.x || 3.x", version: "2.1.0", want: false},
+		{constraint: "1.2.*", version: "1.2.5", want: true},
+		{constraint: "*", version: "0.0.1", want: true},
+		{constraint: "^1.2", version: "1.3.0-rc.1", want: true},
+		{constraint: ">=1.3.0", version: "1.3.0-rc.1", want: false},
+	}
+	for _, tc := range cases {
+		constraint, err := ParseVersionConstraint(tc.constraint)
+		if err != nil {
+			t.Fatalf("ParseVersionConstraint(%q): %v", tc.constraint, err)
+		}
+		version, err := ParseVersion(tc.version)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q): %v", tc.version, err)
+		}
+		if got := constraint.Check(version); got != tc.want {
+			t.Fatalf("%q.Check(%q) = %v, want %v", tc.constraint, tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestCompareVersionsOrdersPrereleaseIdentifiers(t *testing.T) {
+	// Precedence example of SemVer §11, plus rc.2 < rc.10.
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0-rc.2",
+		"1.0.0-rc.10",
+		"1.0.0",
+	}
+	for index := 1; index < len(ordered); index++ {
+		lower, err := ParseVersion(ordered[index-1])
+		if err != nil {
+			t.Fatalf("ParseVersion(%q): %v", ordered[index-1], err)
+		}
+		higher, err := ParseVersion(ordered[index])
+		if err != nil {
+			t.Fatalf("ParseVersion(%q): %v", ordered[index], err)
+		}
+		if got := CompareVersions(lower, higher); got != -1 {
+			t.Fatalf("CompareVersions(%q, %q) = %d, want -1", ordered[index-1], ordered[index], got)
+		}
+		if got := CompareVersions(higher, lower); got != 1 {
+			t.Fatalf("CompareVersions(%q, %q) = %d, want 1", ordered[index], ordered[index-1], got)
+		}
+	}
+}
+
+func TestIsVersionConstraint(t *testing.T) {
+	for value, want := range map[string]bool{
+		"v1.2.3":      false,
+		"nightly":     false,
+		"^1.2":        true,
+		"~1.2":        true,
+		">=1, <2":     true,
+		"1.x":         true,
+		"v2.*":        true,
+		"1.0 || 2.0":  true,
+		"release-1.2": false,
+	} {
+		if got := IsVersionConstraint(value); got != want {
+			t.Fatalf("IsVersionConstraint(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package taskrun
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pirakansa/vorbere/internal/cli/manifest"
+)
+
+const (
+	GraphFormatTree    = "tree"
+	GraphFormatDOT     = "dot"
+	GraphFormatMermaid = "mermaid"
+)
+
+// WriteTaskGraph writes the depends_on graph of root and everything it
+// pulls in, or of every task when root is empty. Edges point from a task to
+// its dependencies in depends_on order. The graph must be acyclic, as
+// ensured when the config is loaded.
+func WriteTaskGraph(w io.Writer, cfg *manifest.TaskConfig, root, format string) error {
+	var roots []string
+	if root != "" {
+		if _, ok := cfg.Tasks[root]; !ok {
+			return fmt.Errorf("task %q is not defined", root)
+		}
+		roots = []string{root}
+	} else {
+		roots = rootTaskNames(cfg)
+	}
+	names := reachableTaskNames(cfg, roots)
+
+	switch format {
+	case GraphFormatTree:
+		for _, name := range roots {
+			fmt.Fprintln(w, name)
+			writeTaskTree(w, cfg, name, "", map[string]bool{name: true})
+		}
+	case GraphFormatDOT:
+		fmt.Fprintln(w, "digraph tasks {")
+		for _, name := range names {
+			fmt.Fprintf(w, "  %s;\n", strconv.Quote(name))
+		}
+		for _, name := range names {
+			for _, dep := range cfg.Tasks[name].DependsOn {
+				fmt.Fprintf(w, "  %s -> %s;\n", strconv.Quote(name), strconv.Quote(dep))
+			}
+		}
+		fmt.Fprintln(w, "}")
+	case GraphFormatMermaid:
+		ids := make(map[string]string, len(names))
+		fmt.Fprintln(w, "flowchart TD")
+		for index, name := range names {
+			ids[name] = fmt.Sprintf("t%d", index)
+			fmt.Fprintf(w, "  %s[\"%s\"]\n", ids[name], strings.ReplaceAll(name, `"`, "#quot;"))
+		}
+		for _, name := range names {
+			for _, dep := range cfg.Tasks[name].DependsOn {
+				fmt.Fprintf(w, "  %s --> %s\n", ids[name], ids[dep])
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported graph format %q (want %s, %s or %s)", format, GraphFormatTree, GraphFormatDOT, GraphFormatMermaid)
+	}
+	return nil
+}
+
+// rootTaskNames returns the tasks no other task depends on, sorted.
+func rootTaskNames(cfg *manifest.TaskConfig) []string {
+	dependedOn := map[string]bool{}
+	for _, task := range cfg.Tasks {
+		for _, dep := range task.DependsOn {
+			dependedOn[dep] = true
+		}
+	}
+	var roots []string
+	for _, name := range ListTaskNames(cfg) {
+		if !dependedOn[name] {
+			roots = append(roots, name)
+		}
+	}
+	return roots
+}
+
+// reachableTaskNames returns roots and their transitive dependencies, sorted.
+func reachableTaskNames(cfg *manifest.TaskConfig, roots []string) []string {
+	seen := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		for _, dep := range cfg.Tasks[name].DependsOn {
+			visit(dep)
+		}
+	}
+	for _, name := range roots {
+		visit(name)
+	}
+	var names []string
+	for _, name := range ListTaskNames(cfg) {
+		if seen[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// writeTaskTree prints the dependencies of name as an indented tree. A task
+// already printed under the same root is marked with (*) and not expanded
+// again.
+func writeTaskTree(w io.Writer, cfg *manifest.TaskConfig, name, prefix string, printed map[string]bool) {
+	deps := cfg.Tasks[name].DependsOn
+	for index, dep := range deps {
+		branch, indent := "├── ", "│   "
+		if index == len(deps)-1 {
+			branch, indent = "└── ", "    "
+		}
+		if printed[dep] {
+			fmt.Fprintf(w, "%s%s%s (*)\n", prefix, branch, dep)
+			continue
+		}
+		fmt.Fprintf(w, "%s%s%s\n", prefix, branch, dep)
+		printed[dep] = true
+		writeTaskTree(w, cfg, dep, prefix+indent, printed)
+	}
+}
@@ -0,0 +1,96 @@
+package taskrun
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pirakansa/vorbere/internal/cli/manifest"
+)
+
+func graphTestConfig() *manifest.TaskConfig {
+	return &manifest.TaskConfig{
+		Version: 1,
+		Tasks: map[string]manifest.TaskDef{
+			"ci":      {DependsOn: []string{"lint", "test"}},
+			"lint":    {Run: "lint", DependsOn: []string{"fmt"}},
+			"test":    {Run: "test", DependsOn: []string{"build", "fmt"}},
+			"build":   {Run: "build", DependsOn: []string{"fmt"}},
+			"fmt":     {Run: "fmt"},
+			"release": {Run: "release", DependsOn: []string{"build"}},
+		},
+	}
+}
+
+func TestWriteTaskGraphTree(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteTaskGraph(&out, graphTestConfig(), "", GraphFormatTree); err != nil {
+		t.Fatalf("WriteTaskGraph failed: %v", err)
+	}
+	want := `ci
+├── lint
+│   └── fmt
+└── test
+    ├── build
+    │   └── fmt (*)
+    └── fmt (*)
+release
+└── build
+    └── fmt
+`
+	if out.String() != want {
+		t.Fatalf("unexpected tree:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestWriteTaskGraphDOTKeepsReachableTasks(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteTaskGraph(&out, graphTestConfig(), "test", GraphFormatDOT); err != nil {
+		t.Fatalf("WriteTaskGraph failed: %v", err)
+	}
+	want := `digraph tasks {
+  "build";
+  "fmt";
+  "test";
+  "build" -> "fmt";
+  "test" -> "build";
+  "test" -> "fmt";
+}
+`
+	if out.String() != want {
+		t.Fatalf("unexpected dot:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestWriteTaskGraphMermaid(t *testing.T) {
+	cfg := &manifest.TaskConfig{
+		Version: 1,
+		Tasks: map[string]manifest.TaskDef{
+			"ci":          {DependsOn: []string{"tools:lint"}},
+			"tools:lint":  {Run: "lint"},
+			`say "hello"`: {Run: "echo hello"},
+		},
+	}
+	var out bytes.Buffer
+	if err := WriteTaskGraph(&out, cfg, "", GraphFormatMermaid); err != nil {
+		t.Fatalf("WriteTaskGraph failed: %v", err)
+	}
+	want := `flowchart TD
+  t0["ci"]
+  t1["say #quot;hello#quot;"]
+  t2["tools:lint"]
+  t0 --> t2
+`
+	if out.String() != want {
+		t.Fatalf("unexpected mermaid:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestWriteTaskGraphRejectsUnknownTaskAndFormat(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteTaskGraph(&out, graphTestConfig(), "missing", GraphFormatTree); err == nil {
+		t.Fatalf("expected undefined task error")
+	}
+	if err := WriteTaskGraph(&out, graphTestConfig(), "", "svg"); err == nil {
+		t.Fatalf("expected unsupported format error")
+	}
+}
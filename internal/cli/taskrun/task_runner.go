@@ -1,16 +1,22 @@
 package taskrun
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/pirakansa/vorbere/internal/cli/manifest"
 )
 
+var userWorkingDirPattern = regexp.MustCompile(`\{\{\s*\.USER_WORKING_DIR\s*\}\}`)
+
 func ListTaskNames(cfg *manifest.TaskConfig) []string {
 	names := make([]string, 0, len(cfg.Tasks))
 	for name := range cfg.Tasks {
@@ -20,57 +26,141 @@ func ListTaskNames(cfg *manifest.TaskConfig) []string {
 	return names
 }
 
+// RunOptions customizes task output and reports task lifecycle events.
+type RunOptions struct {
+	Stdout   io.Writer
+	Stderr   io.Writer
+	OnStart  func(TaskEvent)
+	OnFinish func(TaskEvent)
+}
+
+// TaskEvent describes one task, including dependencies, started or finished
+// during a run. Duration, ExitCode and Err are set only on finish.
+type TaskEvent struct {
+	Task     string
+	Duration time.Duration
+	ExitCode int
+	Err      error
+}
+
+// ExpandError reports a task whose run, env or cwd failed to expand right
+// before it was run.
+type ExpandError struct {
+	Err error
+}
+
+func (e *ExpandError) Error() string { return e.Err.Error() }
+
+func (e *ExpandError) Unwrap() error { return e.Err }
+
 func RunTask(cfg *manifest.TaskConfig, name, rootDir string, args []string) error {
+	return RunTaskWithOptions(cfg, name, rootDir, args, RunOptions{})
+}
+
+func RunTaskWithOptions(cfg *manifest.TaskConfig, name, rootDir string, args []string, opts RunOptions) error {
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
+	}
 	running := map[string]bool{}
 	completed := map[string]bool{}
-	return runTask(cfg, name, rootDir, args, running, completed)
+	return runTask(cfg, name, rootDir, args, opts, running, completed)
 }
 
-func runTask(cfg *manifest.TaskConfig, name, rootDir string, args []string, running, completed map[string]bool) error {
+func runTask(cfg *manifest.TaskConfig, name, rootDir string, args []string, opts RunOptions, running, completed map[string]bool) error {
 	if completed[name] {
 		return nil
 	}
-	task, ok := cfg.Tasks[name]
-	if !ok {
+	if _, ok := cfg.Tasks[name]; !ok {
 		return fmt.Errorf("task %q is not defined", name)
 	}
+	task, err := manifest.ExpandTask(cfg, name, args)
+	if err != nil {
+		return &ExpandError{Err: err}
+	}
 	if running[name] {
 		return fmt.Errorf("task dependency cycle detected at %q", name)
 	}
 	running[name] = true
 	for _, dep := range task.DependsOn {
-		if err := runTask(cfg, dep, rootDir, nil, running, completed); err != nil {
+		if err := runTask(cfg, dep, rootDir, nil, opts, running, completed); err != nil {
 			return err
 		}
 	}
 	running[name] = false
 
-	if strings.TrimSpace(task.Run) != "" {
-		cmdLine := task.Run
-		if len(args) > 0 {
-			cmdLine += " " + strings.Join(args, " ")
-		}
-		cmd := exec.Command("bash", "-lc", cmdLine)
-		cwd := rootDir
-		if task.CWD != "" {
-			if filepath.IsAbs(task.CWD) {
-				cwd = task.CWD
-			} else {
-				cwd = filepath.Join(rootDir, task.CWD)
-			}
-		}
-		cmd.Dir = cwd
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Stdin = os.Stdin
-		cmd.Env = os.Environ()
-		for k, v := range task.Env {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
-		}
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("task %q failed: %w", name, err)
-		}
+	if opts.OnStart != nil {
+		opts.OnStart(TaskEvent{Task: name})
+	}
+	started := time.Now()
+	err = execTask(task, name, rootDir, args, opts)
+	if opts.OnFinish != nil {
+		opts.OnFinish(TaskEvent{Task: name, Duration: time.Since(started), ExitCode: taskExitCode(err), Err: err})
+	}
+	if err != nil {
+		return err
 	}
 	completed[name] = true
 	return nil
 }
+
+func execTask(task manifest.TaskDef, name, rootDir string, args []string, opts RunOptions) error {
+	if strings.TrimSpace(task.Run) == "" {
+		return nil
+	}
+	cmdLine := task.Run
+	if len(args) > 0 && !task.RunUsesArgs {
+		cmdLine += " " + strings.Join(args, " ")
+	}
+	cmd := exec.Command("bash", "-lc", cmdLine)
+	cwd, err := resolveTaskCWD(task.CWD, rootDir)
+	if err != nil {
+		return fmt.Errorf("task %q: %w", name, err)
+	}
+	cmd.Dir = cwd
+	cmd.Stdout = opts.Stdout
+	cmd.Stderr = opts.Stderr
+	cmd.Stdin = os.Stdin
+	cmd.Env = os.Environ()
+	for k, v := range task.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("task %q failed: %w", name, err)
+	}
+	return nil
+}
+
+// resolveTaskCWD expands {{.USER_WORKING_DIR}} to the directory vorbere
+// was invoked from and resolves relative paths against rootDir.
+func resolveTaskCWD(cwd, rootDir string) (string, error) {
+	if cwd == "" {
+		return rootDir, nil
+	}
+	if userWorkingDirPattern.MatchString(cwd) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		cwd = userWorkingDirPattern.ReplaceAllLiteralString(cwd, wd)
+	}
+	if filepath.IsAbs(cwd) {
+		return cwd, nil
+	}
+	return filepath.Join(rootDir, cwd), nil
+}
+
+// taskExitCode returns the command exit status, 0 on success and -1 when the
+// command could not be started or was killed by a signal.
+func taskExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
@@ -1,8 +1,10 @@
 package taskrun
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/pirakansa/vorbere/internal/cli/manifest"
@@ -101,3 +103,79 @@ func TestRunTaskAppendsArgsToCommand(t *testing.T) {
 		t.Fatalf("unexpected args output: %q", got)
 	}
 }
+
+func TestRunTaskDoesNotAppendArgsUsedByTemplate(t *testing.T) {
+	temp := t.TempDir()
+	cfg := &manifest.TaskConfig{
+		Version: 1,
+		Tasks: map[string]manifest.TaskDef{
+			"args": {Run: `echo templated > args.txt`, RunUsesArgs: true},
+		},
+	}
+
+	if err := RunTask(cfg, "args", temp, []string{"hello"}); err != nil {
+		t.Fatalf("RunTask failed: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(temp, "args.txt"))
+	if err != nil {
+		t.Fatalf("read marker: %v", err)
+	}
+	if got := string(b); got != "templated\n" {
+		t.Fatalf("unexpected args output: %q", got)
+	}
+}
+
+func TestRunTaskWithOptionsReportsEvents(t *testing.T) {
+	temp := t.TempDir()
+	cfg := &manifest.TaskConfig{
+		Version: 1,
+		Tasks: map[string]manifest.TaskDef{
+			"build": {Run: "echo built"},
+			"test":  {Run: "exit 3", DependsOn: []string{"build"}},
+		},
+	}
+
+	var stdout bytes.Buffer
+	var started []string
+	var finished []TaskEvent
+	err := RunTaskWithOptions(cfg, "test", temp, nil, RunOptions{
+		Stdout:   &stdout,
+		OnStart:  func(event TaskEvent) { started = append(started, event.Task) },
+		OnFinish: func(event TaskEvent) { finished = append(finished, event) },
+	})
+	if err == nil {
+		t.Fatalf("expected task failure")
+	}
+	if strings.Join(started, ",") != "build,test" {
+		t.Fatalf("unexpected start events: %v", started)
+	}
+	if len(finished) != 2 || finished[0].ExitCode != 0 || finished[1].ExitCode != 3 || finished[1].Err == nil {
+		t.Fatalf("unexpected finish events: %+v", finished)
+	}
+	if stdout.String() != "built\n" {
+		t.Fatalf("expected task output on custom stdout, got %q", stdout.String())
+	}
+}
+
+func TestResolveTaskCWDExpandsUserWorkingDir(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd failed: %v", err)
+	}
+	cases := map[string]string{
+		"":                            "/root/project",
+		"build":                       filepath.Join("/root/project", "build"),
+		"/tmp":                        "/tmp",
+		"{{.USER_WORKING_DIR}}":       wd,
+		"{{ .USER_WORKING_DIR }}/out": filepath.Join(wd, "out"),
+	}
+	for cwd, want := range cases {
+		got, err := resolveTaskCWD(cwd, "/root/project")
+		if err != nil {
+			t.Fatalf("resolveTaskCWD(%q) failed: %v", cwd, err)
+		}
+		if got != want {
+			t.Fatalf("resolveTaskCWD(%q): expected %s, got %s", cwd, want, got)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+)
+
+var (
+	repositoryIssuePathPattern = regexp.MustCompile(`^(repositories\[\d+\](?:\.[A-Za-z_]+|\[\d+\])*) ?(.*)$`)
+	varIssuePathPattern        = regexp.MustCompile(`^(vars\.[^ :]+):? (.*)$`)
+)
+
+// ConfigIssue is one problem reported by CheckTaskConfig.
+type ConfigIssue struct {
+	// Path is the field path of the problem, for example
+	// tasks.ci.depends_on[0].
+	Path string
+	// Origin is the include the entry was merged from, empty for the root
+	// config.
+	Origin  string
+	Message string
+}
+
+func (i ConfigIssue) Error() string {
+	return withOrigin(i.Origin, fmt.Errorf("%s %s", i.Path, i.Message)).Error()
+}
+
+// ResolveTaskConfigIssues resolves vars and expands templates like
+// ResolveVars and ExpandTaskConfigTemplatesWithOptions, but reports every
+// invalid var and failing field instead of stopping at the first one.
+// Failing entries keep their unexpanded value. Issues are ordered by field
+// path; invalid overrides are returned as err.
+func ResolveTaskConfigIssues(cfg *TaskConfig, varsOpts ResolveVarsOptions, opts ExpandOptions) ([]ConfigIssue, error) {
+	var issues []ConfigIssue
+	err := resolveVars(cfg, varsOpts, func(err error) error {
+		issue := ConfigIssue{Path: "vars", Message: err.Error()}
+		if match := varIssuePathPattern.FindStringSubmatch(err.Error()); match != nil {
+			issue.Path, issue.Message = match[1], match[2]
+		}
+		issues = append(issues, issue)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	vars := newTemplateContext(cfg, opts)
+	vars.issues = &issues
+	if err := expandTaskConfig(cfg, vars); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+	return issues, nil
+}
+
+// CheckTaskConfig reports every problem of an expanded config instead of
+// stopping at the first one: task definitions, depends_on references and
+// cycles, hook tasks and the sync config built from each repository.
+// Issues are ordered by field path.
+func CheckTaskConfig(cfg *TaskConfig, opts BuildSyncConfigOptions) []ConfigIssue {
+	var issues []ConfigIssue
+	if cfg.Version != DefaultTaskConfigVersion {
+		issues = append(issues, ConfigIssue{
+			Path:    "version",
+			Message: fmt.Sprintf("%d is not supported (supported: %d)", cfg.Version, DefaultTaskConfigVersion),
+		})
+	}
+
+	issues = append(issues, taskIssues(cfg.Tasks)...)
+	if cfg.Sync != nil {
+		issues = append(issues, hookIssues(cfg.Sync.After, cfg.Tasks, "sync.after", "")...)
+	}
+
+	builder := &syncConfigBuilder{
+		cfg:         &SyncConfig{Version: SyncConfigVersion, Sources: map[string]Source{}},
+		taskCfg:     cfg,
+		opts:        opts,
+		matchedOnly: map[string]bool{},
+		blocks:      map[string]string{},
+	}
+	for repoIndex, repo := range cfg.Repositories {
+		report := func(err error) { issues = append(issues, repositoryIssue(repoIndex, repo.Origin, err)) }
+		builder.report = report
+
+		// Hooks are checked here to report all of them, so the builder gets
+		// the repository without them.
+		repoPath := fmt.Sprintf("repositories[%d]", repoIndex)
+		issues = append(issues, hookIssues(repo.OnChange, cfg.Tasks, repoPath+".on_change", repo.Origin)...)
+		checked := repo
+		checked.OnChange = nil
+		checked.Files = slices.Clone(repo.Files)
+		for fileIndex, file := range repo.Files {
+			filePath := fmt.Sprintf("%s.files[%d].on_change", repoPath, fileIndex)
+			issues = append(issues, hookIssues(file.OnChange, cfg.Tasks, filePath, repo.Origin)...)
+			checked.Files[fileIndex].OnChange = nil
+		}
+		if err := builder.addRepository(repoIndex, checked); err != nil {
+			report(err)
+		}
+	}
+	if len(issues) == 0 {
+		if err := ValidateSyncConfig(builder.cfg); err != nil {
+			issues = append(issues, ConfigIssue{Path: "repositories", Message: err.Error()})
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+	return issues
+}
+
+func hookIssues(hooks []string, tasks map[string]TaskDef, fieldPath, origin string) []ConfigIssue {
+	var issues []ConfigIssue
+	for index, name := range hooks {
+		if _, ok := tasks[name]; !ok {
+			issues = append(issues, ConfigIssue{
+				Path:    fmt.Sprintf("%s[%d]", fieldPath, index),
+				Origin:  origin,
+				Message: fmt.Sprintf("references undefined task %q", name),
+			})
+		}
+	}
+	return issues
+}
+
+// repositoryIssue splits a builder error into its field path and message.
+func repositoryIssue(repoIndex int, origin string, err error) ConfigIssue {
+	match := repositoryIssuePathPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return ConfigIssue{Path: fmt.Sprintf("repositories[%d]", repoIndex), Origin: origin, Message: err.Error()}
+	}
+	return ConfigIssue{Path: match[1], Origin: origin, Message: match[2]}
+}
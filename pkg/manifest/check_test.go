@@ -0,0 +1,77 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckTaskConfigReportsAllIssues(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Tasks: map[string]TaskDef{
+			"ci":    {DependsOn: []string{"lint", "test"}},
+			"test":  {DependsOn: []string{"build"}},
+			"build": {Run: "go build", DependsOn: []string{"test"}},
+			"empty": {},
+		},
+		Sync: &SyncSettings{After: []string{"ci", "missing"}},
+		Repositories: []Repository{{
+			URL:      "https://example.com/",
+			OnChange: []string{"nope"},
+			Files: []RepositoryFile{
+				{FileName: "a.txt", OutDir: ".", Encoding: "bogus"},
+				{FileName: "b.txt", OutDir: ".", OnChange: []string{"gone"}},
+				{FileName: "c.txt"},
+			},
+		}},
+	}
+	issues := CheckTaskConfig(cfg, BuildSyncConfigOptions{})
+	got := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		got = append(got, issue.Error())
+	}
+	want := []string{
+		`repositories[0].files[0].encoding must be one of "zstd", "tar+gzip", "tar+xz"`,
+		`repositories[0].files[1].on_change[0] references undefined task "gone"`,
+		`repositories[0].files[2].out_dir is required`,
+		`repositories[0].on_change[0] references undefined task "nope"`,
+		`sync.after[1] references undefined task "missing"`,
+		`tasks.build.depends_on dependency cycle: build -> test -> build`,
+		`tasks.ci.depends_on[0] references undefined task "lint"`,
+		`tasks.empty must have run or depends_on`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected issues:\n got: %s\nwant: %s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCheckTaskConfigReportsIssueOrigin(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Tasks: map[string]TaskDef{
+			"lint": {DependsOn: []string{"fmt"}, Origin: "shared/lint.yaml"},
+		},
+	}
+	issues := CheckTaskConfig(cfg, BuildSyncConfigOptions{})
+	if len(issues) != 1 || issues[0].Origin != "shared/lint.yaml" ||
+		issues[0].Error() != `shared/lint.yaml: tasks.lint.depends_on[0] references undefined task "fmt"` {
+		t.Fatalf("unexpected issues: %+v", issues)
+	}
+}
+
+func TestCheckTaskConfigAcceptsValidConfig(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Tasks: map[string]TaskDef{
+			"build": {Run: "go build"},
+			"ci":    {DependsOn: []string{"build"}},
+		},
+		Repositories: []Repository{{
+			URL:   "https://example.com/",
+			Files: []RepositoryFile{{FileName: "a.txt", OutDir: ".", OnChange: []string{"build"}}},
+		}},
+	}
+	if issues := CheckTaskConfig(cfg, BuildSyncConfigOptions{}); len(issues) != 0 {
+		t.Fatalf("expected no issues, got %+v", issues)
+	}
+}
@@ -1,14 +1,16 @@
 package manifest
 
 import (
-	"encoding/hex"
 	"errors"
 	"fmt"
+	"maps"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 )
@@ -21,16 +23,36 @@ const (
 	EncodingTarXz            = "tar+xz"
 	DigestAlgorithmBLAKE3    = "blake3"
 	DigestAlgorithmSHA256    = "sha256"
+	DigestAlgorithmSHA384    = "sha384"
+	DigestAlgorithmSHA512    = "sha512"
+	DigestAlgorithmSHA1      = "sha1"
 	DigestAlgorithmMD5       = "md5"
+	RepositoryTypeHTTP       = "http"
+	RepositoryTypeGitHub     = "github-release"
+	RepositoryTypeGit        = "git"
+	RepositoryTypeOCI        = "oci"
+	SourceTypeFile           = "file"
+	DefaultGitRef            = "HEAD"
+	GitHubReleaseTagLatest   = "latest"
+	DefaultGitHubAPIURL      = "https://api.github.com"
+	TemplateEngineGo         = "go"
+	MergeThreeWay            = "three-way"
+	MergeJSON                = "json"
+	MergeYAML                = "yaml"
+	MergeTOML                = "toml"
+	MergeArraysReplace       = "replace"
+	MergeArraysAppend        = "append"
+	MergeArraysUnion         = "union"
 )
 
 var headerEnvPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
-var varsTemplatePattern = regexp.MustCompile(`\$\{\{\s*\.vars\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
 var varsReferencePattern = regexp.MustCompile(`\$\{\{\s*\.vars\.([^}\s]+)\s*\}\}`)
 var varsKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
 
 type BuildSyncConfigOptions struct {
 	ExpandRepositoryHeaderEnv bool
+	RejectLocalSources        bool
+	Filter                    SyncFilter
 }
 
 func NormalizeTaskConfig(cfg *TaskConfig) {
@@ -49,106 +71,75 @@ func NormalizeTaskConfig(cfg *TaskConfig) {
 }
 
 func ExpandTaskConfigTemplates(cfg *TaskConfig) error {
-	for name, task := range cfg.Tasks {
-		expandedTask, err := expandTaskDefTemplates(name, task, cfg.Vars)
-		if err != nil {
-			return err
-		}
-		cfg.Tasks[name] = expandedTask
-	}
-
-	for repoIndex, repo := range cfg.Repositories {
-		expandedRepo, err := expandRepositoryTemplates(repoIndex, repo, cfg.Vars)
-		if err != nil {
-			return err
-		}
-		cfg.Repositories[repoIndex] = expandedRepo
-	}
-
-	return nil
+	return ExpandTaskConfigTemplatesWithOptions(cfg, ExpandOptions{})
 }
 
-func expandTaskDefTemplates(taskName string, task TaskDef, vars map[string]string) (TaskDef, error) {
-	run, err := expandVarsTemplate(task.Run, vars, "tasks."+taskName+".run")
-	if err != nil {
-		return TaskDef{}, err
-	}
-	task.Run = run
-
-	cwd, err := expandVarsTemplate(task.CWD, vars, "tasks."+taskName+".cwd")
-	if err != nil {
-		return TaskDef{}, err
-	}
-	task.CWD = cwd
-
-	for key, value := range task.Env {
-		expanded, expandErr := expandVarsTemplate(value, vars, "tasks."+taskName+".env."+key)
-		if expandErr != nil {
-			return TaskDef{}, expandErr
-		}
-		task.Env[key] = expanded
-	}
-	return task, nil
+// ExpandTaskConfigTemplatesWithOptions evaluates ${{ }} expressions in
+// every string field of cfg, except vars and includes which are resolved
+// before expansion.
+func ExpandTaskConfigTemplatesWithOptions(cfg *TaskConfig, opts ExpandOptions) error {
+	return expandTaskConfig(cfg, newTemplateContext(cfg, opts))
 }
 
-func expandRepositoryTemplates(repoIndex int, repo Repository, vars map[string]string) (Repository, error) {
-	urlValue, err := expandVarsTemplate(repo.URL, vars, fmt.Sprintf("repositories[%d].url", repoIndex))
-	if err != nil {
-		return Repository{}, err
+// expandTaskConfig expands tasks in name order and repositories in config
+// order, so the first error is the same on every run.
+func expandTaskConfig(cfg *TaskConfig, vars *templateContext) error {
+	var skip []string
+	if vars.opts.DeferTasks {
+		skip = deferredTaskFields
+		deferred := vars.opts
+		cfg.deferredExpand = &deferred
+	}
+	for _, name := range sortedTaskNames(cfg.Tasks) {
+		task := cfg.Tasks[name]
+		if usesArgs(task.Run) {
+			task.RunUsesArgs = true
+		}
+		taskVars := vars.forOrigin(task.Origin)
+		if err := taskVars.expandFields(reflect.ValueOf(&task).Elem(), "tasks."+name, name, skip...); err != nil {
+			return withOrigin(task.Origin, err)
+		}
+		if vars.opts.DeferTasks && vars.issues != nil {
+			// Deferred fields are only checked, as .args is not known yet.
+			checked := *taskVars
+			checked.checkOnly = true
+			if err := checked.expandFields(reflect.ValueOf(&task).Elem(), "tasks."+name, name, loadTaskFields...); err != nil {
+				return withOrigin(task.Origin, err)
+			}
+		}
+		cfg.Tasks[name] = task
 	}
-	repo.URL = urlValue
 
-	for fileIndex, file := range repo.Files {
-		expandedFile, expandErr := expandRepositoryFileTemplates(repoIndex, fileIndex, file, vars)
-		if expandErr != nil {
-			return Repository{}, expandErr
+	for repoIndex := range cfg.Repositories {
+		repo := &cfg.Repositories[repoIndex]
+		repoVars := vars.forOrigin(repo.Origin)
+		if err := repoVars.expandFields(reflect.ValueOf(repo).Elem(), fmt.Sprintf("repositories[%d]", repoIndex), ""); err != nil {
+			return withRepositoryOrigin(cfg.Repositories, repoIndex, err)
 		}
-		repo.Files[fileIndex] = expandedFile
 	}
-	return repo, nil
-}
 
-func expandRepositoryFileTemplates(repoIndex, fileIndex int, file RepositoryFile, vars map[string]string) (RepositoryFile, error) {
-	fileName, fileNameErr := expandVarsTemplate(
-		file.FileName,
-		vars,
-		fmt.Sprintf("repositories[%d].files[%d].file_name", repoIndex, fileIndex),
-	)
-	if fileNameErr != nil {
-		return RepositoryFile{}, fileNameErr
-	}
-	file.FileName = fileName
+	return vars.expandFields(reflect.ValueOf(cfg).Elem(), "", "", "tasks", "repositories")
+}
 
-	outDir, outDirErr := expandVarsTemplate(
-		file.OutDir,
-		vars,
-		fmt.Sprintf("repositories[%d].files[%d].out_dir", repoIndex, fileIndex),
-	)
-	if outDirErr != nil {
-		return RepositoryFile{}, outDirErr
+// ExpandTask returns the task name of cfg with the fields deferred by
+// ExpandOptions.DeferTasks expanded and args exposed as .args. Tasks of a
+// config expanded without DeferTasks are returned as is.
+func ExpandTask(cfg *TaskConfig, name string, args []string) (TaskDef, error) {
+	task, ok := cfg.Tasks[name]
+	if !ok {
+		return TaskDef{}, fmt.Errorf("task %q is not defined", name)
 	}
-	file.OutDir = outDir
-
-	rename, renameErr := expandVarsTemplate(
-		file.Rename,
-		vars,
-		fmt.Sprintf("repositories[%d].files[%d].rename", repoIndex, fileIndex),
-	)
-	if renameErr != nil {
-		return RepositoryFile{}, renameErr
+	if cfg.deferredExpand == nil {
+		return task, nil
 	}
-	file.Rename = rename
-
-	extract, extractErr := expandVarsTemplate(
-		file.Extract,
-		vars,
-		fmt.Sprintf("repositories[%d].files[%d].extract", repoIndex, fileIndex),
-	)
-	if extractErr != nil {
-		return RepositoryFile{}, extractErr
+	opts := *cfg.deferredExpand
+	opts.Args = args
+	task.Env = maps.Clone(task.Env)
+	vars := newTemplateContext(cfg, opts).forOrigin(task.Origin)
+	if err := vars.expandFields(reflect.ValueOf(&task).Elem(), "tasks."+name, name, loadTaskFields...); err != nil {
+		return TaskDef{}, withOrigin(task.Origin, err)
 	}
-	file.Extract = extract
-	return file, nil
+	return task, nil
 }
 
 func IsRemoteConfigLocation(value string) bool {
@@ -159,14 +150,15 @@ func IsRemoteConfigLocation(value string) bool {
 	return parsed.Scheme == "http" || parsed.Scheme == "https"
 }
 
+// ValidateTaskConfig checks the config version and the task graph: every
+// task has run or depends_on, depends_on entries name defined tasks and
+// there are no dependency cycles. The first problem is returned.
 func ValidateTaskConfig(cfg *TaskConfig) error {
 	if cfg.Version != DefaultTaskConfigVersion {
 		return fmt.Errorf("unsupported config version %d (supported: %d)", cfg.Version, DefaultTaskConfigVersion)
 	}
-	for name, task := range cfg.Tasks {
-		if task.Run == "" && len(task.DependsOn) == 0 {
-			return fmt.Errorf("task %q must have run or depends_on", name)
-		}
+	if issues := taskIssues(cfg.Tasks); len(issues) > 0 {
+		return issues[0]
 	}
 	return nil
 }
@@ -187,21 +179,37 @@ func ValidateSyncConfig(cfg *SyncConfig) error {
 		if rule.Path == "" {
 			return fmt.Errorf("files[%d].path is required", i)
 		}
+		if rule.DownloadChecksumFrom != "" {
+			if _, ok := cfg.Sources[rule.DownloadChecksumFrom]; !ok {
+				return fmt.Errorf("files[%d].download_checksum_from %q not found in sources", i, rule.DownloadChecksumFrom)
+			}
+		}
+		if rule.Signature != nil {
+			if _, ok := cfg.Sources[rule.Signature.Source]; !ok {
+				return fmt.Errorf("files[%d].signature.source %q not found in sources", i, rule.Signature.Source)
+			}
+		}
 	}
 	return nil
 }
 
+// BuildSyncConfig expands the templates of a local config and builds its
+// sync config.
 func BuildSyncConfig(taskCfg *TaskConfig) (*SyncConfig, error) {
+	NormalizeTaskConfig(taskCfg)
+	if err := ExpandTaskConfigTemplates(taskCfg); err != nil {
+		return nil, err
+	}
 	return BuildSyncConfigWithOptions(taskCfg, BuildSyncConfigOptions{
 		ExpandRepositoryHeaderEnv: true,
 	})
 }
 
+// BuildSyncConfigWithOptions builds the sync config of an already expanded
+// config. Templates are not evaluated again: expanded values, including vars
+// overrides and sh output, may contain a literal ${{.
 func BuildSyncConfigWithOptions(taskCfg *TaskConfig, opts BuildSyncConfigOptions) (*SyncConfig, error) {
 	NormalizeTaskConfig(taskCfg)
-	if err := ExpandTaskConfigTemplates(taskCfg); err != nil {
-		return nil, err
-	}
 	if err := ValidateTaskConfig(taskCfg); err != nil {
 		return nil, err
 	}
@@ -211,74 +219,166 @@ func BuildSyncConfigWithOptions(taskCfg *TaskConfig, opts BuildSyncConfigOptions
 		Sources: map[string]Source{},
 	}
 
-	for repoIndex, repo := range taskCfg.Repositories {
-		if strings.TrimSpace(repo.URL) == "" {
-			return nil, fmt.Errorf("repositories[%d].url is required", repoIndex)
-		}
-		if opts.ExpandRepositoryHeaderEnv {
-			resolvedHeaders, err := expandRepositoryHeaders(repo.Headers, repoIndex)
-			if err != nil {
-				return nil, err
-			}
-			repo.Headers = resolvedHeaders
+	if taskCfg.Sync != nil {
+		after, err := appendHookTasks(nil, taskCfg.Sync.After, taskCfg.Tasks, "sync.after")
+		if err != nil {
+			return nil, err
 		}
-		for fileIndex, file := range repo.Files {
-			sourceID, source, rule, err := buildSyncEntry(repo, file, repoIndex, fileIndex)
-			if err != nil {
-				return nil, err
-			}
-			cfg.Sources[sourceID] = source
-			cfg.Files = append(cfg.Files, rule)
+		cfg.After = after
+	}
+
+	builder := &syncConfigBuilder{
+		cfg:         cfg,
+		taskCfg:     taskCfg,
+		opts:        opts,
+		matchedOnly: map[string]bool{},
+		blocks:      map[string]string{},
+	}
+	for repoIndex, repo := range taskCfg.Repositories {
+		if err := builder.addRepository(repoIndex, repo); err != nil {
+			return nil, withRepositoryOrigin(taskCfg.Repositories, repoIndex, err)
 		}
 	}
+	if err := opts.Filter.unmatchedOnly(builder.matchedOnly); err != nil {
+		return nil, err
+	}
 
 	return cfg, nil
 }
 
-func expandVarsTemplate(value string, vars map[string]string, fieldPath string) (string, error) {
-	if value == "" {
-		return value, nil
-	}
+// syncConfigBuilder accumulates sources and file rules repository by
+// repository.
+type syncConfigBuilder struct {
+	cfg         *SyncConfig
+	taskCfg     *TaskConfig
+	opts        BuildSyncConfigOptions
+	matchedOnly map[string]bool
+	blocks      map[string]string
+	// report, when set, receives file errors and the remaining files of the
+	// repository are still added.
+	report func(error)
+}
 
-	missing := map[string]struct{}{}
-	expanded := varsTemplatePattern.ReplaceAllStringFunc(value, func(match string) string {
-		matches := varsTemplatePattern.FindStringSubmatch(match)
-		if len(matches) < 2 {
-			return match
+func (b *syncConfigBuilder) addRepository(repoIndex int, repo Repository) error {
+	normalizedRepo, err := normalizeRepositorySource(repo, repoIndex)
+	if err != nil {
+		return err
+	}
+	repo = normalizedRepo
+	if repo.Tags, err = validateTags(repo.Tags, fmt.Sprintf("repositories[%d]", repoIndex)); err != nil {
+		return err
+	}
+	repoHooks, err := appendHookTasks(nil, repo.OnChange, b.taskCfg.Tasks, fmt.Sprintf("repositories[%d].on_change", repoIndex))
+	if err != nil {
+		return err
+	}
+	// Repositories of a remote config or include must not reach into the
+	// local filesystem.
+	remote := b.opts.RejectLocalSources || IsRemoteConfigLocation(repo.Origin)
+	if remote {
+		if err := rejectLocalRepositorySource(repo, repoIndex); err != nil {
+			return err
 		}
-		name := matches[1]
-		resolved, ok := vars[name]
-		if !ok {
-			missing[name] = struct{}{}
-			return ""
+	}
+	if b.opts.ExpandRepositoryHeaderEnv {
+		resolvedHeaders, err := expandRepositoryHeaders(repo.Headers, repoIndex)
+		if err != nil {
+			return err
 		}
-		return resolved
-	})
-
-	if len(missing) > 0 {
-		return "", fmt.Errorf("%s references undefined var(s): %s", fieldPath, strings.Join(sortedSetKeys(missing), ", "))
+		repo.Headers = resolvedHeaders
 	}
-
-	invalidKeys := map[string]struct{}{}
-	refs := varsReferencePattern.FindAllStringSubmatch(expanded, -1)
-	for _, ref := range refs {
-		if len(ref) < 2 {
-			continue
+	forwardHosts, err := normalizeHeaderForwardHosts(repo.AllowHeaderForwardTo, repoIndex)
+	if err != nil {
+		return err
+	}
+	repo.AllowHeaderForwardTo = forwardHosts
+	companionSourceIDs := map[string]string{}
+	addCompanionSource := func(location, fieldPath string) (string, error) {
+		if id, ok := companionSourceIDs[location]; ok {
+			return id, nil
+		}
+		companion, err := buildCompanionSource(repo, location)
+		if err != nil {
+			return "", fmt.Errorf("%s %w", fieldPath, err)
+		}
+		id := fmt.Sprintf("r%dc%d", repoIndex, len(companionSourceIDs))
+		companionSourceIDs[location] = id
+		b.cfg.Sources[id] = companion
+		return id, nil
+	}
+	addFile := func(fileIndex int, file RepositoryFile) error {
+		filePath := fmt.Sprintf("repositories[%d].files[%d]", repoIndex, fileIndex)
+		if file.Tags, err = validateTags(file.Tags, filePath); err != nil {
+			return err
+		}
+		sourceID, source, rule, err := buildSyncEntry(repo, file, repoIndex, fileIndex)
+		if err != nil {
+			return err
+		}
+		rule.Origin = repo.Origin
+		rule.OriginRepositoryIndex = OriginRepositoryIndex(b.taskCfg.Repositories, repoIndex)
+		rule.Remote = remote
+		declaredPath := originPath(rule.Origin, rule.FieldPath())
+		if rule.OnChange, err = appendHookTasks(slices.Clone(repoHooks), file.OnChange, b.taskCfg.Tasks, filePath+".on_change"); err != nil {
+			return err
+		}
+		if rule.Block != nil {
+			key := filepath.Clean(rule.Path) + "\x00" + rule.Block.Begin
+			if previous, ok := b.blocks[key]; ok {
+				return fmt.Errorf("%s.block %q is already managed by %s", filePath, rule.Block.Begin, previous)
+			}
+			b.blocks[key] = declaredPath
+		}
+		if !b.opts.Filter.isEmpty() && !b.opts.Filter.selects(repo, file, rule.Path, b.matchedOnly) {
+			return nil
 		}
-		key := ref[1]
-		if varsKeyPattern.MatchString(key) {
-			continue
+		if rule.Render {
+			vars, err := (&varScope{cfg: b.taskCfg}).all()
+			if err != nil {
+				return err
+			}
+			rule.Vars = vars
+		}
+		b.cfg.Sources[sourceID] = source
+		b.cfg.Warnings = append(b.cfg.Warnings, deprecatedDigestWarnings(rule.DownloadChecksum, declaredPath+".download_digest")...)
+		b.cfg.Warnings = append(b.cfg.Warnings, deprecatedDigestWarnings(rule.OutputChecksum, declaredPath+".output_digest")...)
+		if location := strings.TrimSpace(file.DownloadDigestFrom); location != "" {
+			if rule.DownloadChecksumFrom, err = addCompanionSource(location, filePath+".download_digest_from"); err != nil {
+				return err
+			}
+		}
+		if file.Signature != nil {
+			fieldPath := filePath + ".signature"
+			signature, err := normalizeSignature(*file.Signature, repositoryFileSourceName(repo.Type, file))
+			if err != nil {
+				return fmt.Errorf("%s %w", fieldPath, err)
+			}
+			if remote && signature.KeyFile != "" {
+				return fmt.Errorf("%s.key_file cannot be used when the config is loaded remotely; use key", fieldPath)
+			}
+			signatureSourceID, err := addCompanionSource(signature.File, fieldPath+".file")
+			if err != nil {
+				return err
+			}
+			rule.Signature = &SignatureRule{
+				Type:    signature.Type,
+				Source:  signatureSourceID,
+				Key:     signature.Key,
+				KeyFile: signature.KeyFile,
+			}
 		}
-		invalidKeys[key] = struct{}{}
+		b.cfg.Files = append(b.cfg.Files, rule)
+		return nil
 	}
-	if len(invalidKeys) > 0 {
-		return "", fmt.Errorf(
-			"%s references invalid var key(s): %s (allowed pattern: [A-Za-z_][A-Za-z0-9_]*)",
-			fieldPath,
-			strings.Join(sortedSetKeys(invalidKeys), ", "),
-		)
+	for fileIndex, file := range repo.Files {
+		if err := addFile(fileIndex, file); err != nil {
+			if b.report == nil {
+				return err
+			}
+			b.report(err)
+		}
 	}
-	return expanded, nil
+	return nil
 }
 
 func sortedSetKeys(values map[string]struct{}) []string {
@@ -322,16 +422,48 @@ func expandHeaderValue(rawValue string) (string, error) {
 	return "", fmt.Errorf("references undefined environment variable(s): %s", strings.Join(sortedSetKeys(missing), ", "))
 }
 
+func normalizeHeaderForwardHosts(hosts []string, repoIndex int) ([]string, error) {
+	if len(hosts) == 0 {
+		return nil, nil
+	}
+	normalized := make([]string, 0, len(hosts))
+	for hostIndex, rawHost := range hosts {
+		host := strings.TrimSpace(strings.ToLower(rawHost))
+		if err := validateHeaderForwardHost(host); err != nil {
+			return nil, fmt.Errorf("repositories[%d].allow_header_forward_to[%d] %w", repoIndex, hostIndex, err)
+		}
+		normalized = append(normalized, host)
+	}
+	return normalized, nil
+}
+
+func validateHeaderForwardHost(host string) error {
+	if host == "" {
+		return errors.New("must not be empty")
+	}
+	if strings.ContainsAny(host, "/?#@ ") {
+		return fmt.Errorf("must be a host name without scheme or path: %q", host)
+	}
+	name := strings.TrimPrefix(host, "*.")
+	if name == "" || strings.Contains(name, "*") {
+		return fmt.Errorf("wildcard is only allowed as a leading %q label: %q", "*.", host)
+	}
+	return nil
+}
+
 func buildSyncEntry(repo Repository, file RepositoryFile, repoIndex, fileIndex int) (string, Source, FileRule, error) {
-	encoding, extract, err := validateRepositoryFile(file, repoIndex, fileIndex)
+	encoding, extract, err := validateRepositoryFile(file, repo.Type, repoIndex, fileIndex)
 	if err != nil {
 		return "", Source{}, FileRule{}, err
 	}
+	sourceName := repositoryFileSourceName(repo.Type, file)
 
 	expandArchive := isArchiveEncoding(encoding) && extract == ""
+	expandDirectory := repo.Type == RepositoryTypeGit && IsGitDirectorySelector(file.FileName)
+	multiOutput := expandArchive || expandDirectory
 	targetName := strings.TrimSpace(file.Rename)
-	if !expandArchive && targetName == "" {
-		derivedName, err := deriveTargetName(file.FileName, encoding, extract)
+	if !multiOutput && targetName == "" {
+		derivedName, err := deriveTargetName(sourceName, encoding, extract)
 		if err != nil {
 			return "", Source{}, FileRule{}, fmt.Errorf(
 				"repositories[%d].files[%d] %w",
@@ -340,7 +472,7 @@ func buildSyncEntry(repo Repository, file RepositoryFile, repoIndex, fileIndex i
 		}
 		targetName = derivedName
 	}
-	if !expandArchive && (targetName == "." || targetName == "/" || targetName == "") {
+	if !multiOutput && (targetName == "." || targetName == "/" || targetName == "") {
 		return "", Source{}, FileRule{}, fmt.Errorf(
 			"repositories[%d].files[%d] could not determine output filename",
 			repoIndex, fileIndex,
@@ -348,14 +480,11 @@ func buildSyncEntry(repo Repository, file RepositoryFile, repoIndex, fileIndex i
 	}
 
 	targetPath := os.ExpandEnv(file.OutDir)
-	if !expandArchive {
+	if !multiOutput {
 		targetPath = filepath.Join(targetPath, targetName)
 	}
 	sourceID := fmt.Sprintf("r%df%d", repoIndex, fileIndex)
-	source := Source{
-		URL:     joinURL(repo.URL, file.FileName),
-		Headers: repo.Headers,
-	}
+	source := buildSource(repo, file)
 	rule := FileRule{
 		Source:           sourceID,
 		Path:             targetPath,
@@ -365,20 +494,57 @@ func buildSyncEntry(repo Repository, file RepositoryFile, repoIndex, fileIndex i
 		Encoding:         encoding,
 		Extract:          extract,
 		ExpandArchive:    expandArchive,
+		ExpandDirectory:  expandDirectory,
+		RepositoryIndex:  repoIndex,
+		FileIndex:        fileIndex,
 	}
-	downloadChecksum, err := normalizeDigest(file.DownloadDigest)
+	downloadChecksum, err := normalizeDigests(file.DownloadDigest)
 	if err != nil {
 		return "", Source{}, FileRule{}, fmt.Errorf("repositories[%d].files[%d].download_digest %w", repoIndex, fileIndex, err)
 	}
-	outputChecksum, err := normalizeDigest(file.OutputDigest)
+	outputChecksum, err := normalizeDigests(file.OutputDigest)
 	if err != nil {
 		return "", Source{}, FileRule{}, fmt.Errorf("repositories[%d].files[%d].output_digest %w", repoIndex, fileIndex, err)
 	}
 	rule.DownloadChecksum = downloadChecksum
 	rule.OutputChecksum = outputChecksum
-	if rule.ExpandArchive {
+	if multiOutput {
 		rule.Mode = ""
 	}
+	if rule.ExpandDirectory && (rule.DownloadChecksum != "" || rule.OutputChecksum != "" ||
+		strings.TrimSpace(file.DownloadDigestFrom) != "" || file.Signature != nil || strings.TrimSpace(file.Rename) != "") {
+		return "", Source{}, FileRule{}, fmt.Errorf(
+			"repositories[%d].files[%d] download_digest, download_digest_from, signature, output_digest and rename cannot be used for git directory or glob selections",
+			repoIndex, fileIndex,
+		)
+	}
+	if rule.Render, err = normalizeRender(file, repoIndex, fileIndex); err != nil {
+		return "", Source{}, FileRule{}, err
+	}
+	if rule.Merge, rule.MergeArrays, err = normalizeMerge(file, repoIndex, fileIndex); err != nil {
+		return "", Source{}, FileRule{}, err
+	}
+	if rule.Merge != "" && multiOutput {
+		return "", Source{}, FileRule{}, fmt.Errorf(
+			"repositories[%d].files[%d].merge cannot be used when the entry resolves to multiple files",
+			repoIndex, fileIndex,
+		)
+	}
+	if rule.Block, err = normalizePlacement(file, repoIndex, fileIndex); err != nil {
+		return "", Source{}, FileRule{}, err
+	}
+	if rule.Block != nil && (multiOutput || rule.Merge != "") {
+		return "", Source{}, FileRule{}, fmt.Errorf(
+			"repositories[%d].files[%d].placement %q cannot be combined with merge or entries that resolve to multiple files",
+			repoIndex, fileIndex, PlacementBlock,
+		)
+	}
+	if rule.Render && multiOutput {
+		return "", Source{}, FileRule{}, fmt.Errorf(
+			"repositories[%d].files[%d].render cannot be used when the entry resolves to multiple files",
+			repoIndex, fileIndex,
+		)
+	}
 	if rule.ExpandArchive && rule.OutputChecksum != "" {
 		return "", Source{}, FileRule{}, fmt.Errorf(
 			"repositories[%d].files[%d].output_digest cannot be used when extract is omitted for archive encodings",
@@ -389,41 +555,332 @@ func buildSyncEntry(repo Repository, file RepositoryFile, repoIndex, fileIndex i
 	return sourceID, source, rule, nil
 }
 
-func normalizeDigest(value string) (string, error) {
-	raw := strings.TrimSpace(strings.ToLower(value))
-	if raw == "" {
-		return "", nil
+// normalizeRender reports whether the file is rendered as a template.
+// template: go is an alias of render: true.
+func normalizeRender(file RepositoryFile, repoIndex, fileIndex int) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(file.Template)) {
+	case "":
+		return file.Render, nil
+	case TemplateEngineGo:
+		return true, nil
+	default:
+		return false, fmt.Errorf("repositories[%d].files[%d].template must be %q", repoIndex, fileIndex, TemplateEngineGo)
 	}
-	algorithm, digest, ok := strings.Cut(raw, ":")
-	if !ok || strings.TrimSpace(algorithm) == "" || strings.TrimSpace(digest) == "" {
-		return "", fmt.Errorf(
-			"must be in format %q, %q, or %q",
-			DigestAlgorithmBLAKE3+":<hex>",
-			DigestAlgorithmSHA256+":<hex>",
-			DigestAlgorithmMD5+":<hex>",
+}
+
+// normalizeMerge returns the merge strategy and, for structured merges, the
+// array handling (default replace).
+func normalizeMerge(file RepositoryFile, repoIndex, fileIndex int) (string, string, error) {
+	merge := strings.ToLower(strings.TrimSpace(file.Merge))
+	arrays := strings.ToLower(strings.TrimSpace(file.MergeArrays))
+	switch merge {
+	case "", MergeThreeWay:
+		if arrays != "" {
+			return "", "", fmt.Errorf("repositories[%d].files[%d].merge_arrays requires merge %q, %q or %q", repoIndex, fileIndex, MergeJSON, MergeYAML, MergeTOML)
+		}
+		return merge, "", nil
+	case MergeJSON, MergeYAML, MergeTOML:
+	default:
+		return "", "", fmt.Errorf(
+			"repositories[%d].files[%d].merge must be one of %q, %q, %q, %q",
+			repoIndex, fileIndex, MergeThreeWay, MergeJSON, MergeYAML, MergeTOML,
+		)
+	}
+	switch arrays {
+	case "":
+		return merge, MergeArraysReplace, nil
+	case MergeArraysReplace, MergeArraysAppend, MergeArraysUnion:
+		return merge, arrays, nil
+	default:
+		return "", "", fmt.Errorf(
+			"repositories[%d].files[%d].merge_arrays must be one of %q, %q, %q",
+			repoIndex, fileIndex, MergeArraysReplace, MergeArraysAppend, MergeArraysUnion,
 		)
 	}
-	if !isSupportedDigestAlgorithm(algorithm) {
-		return "", fmt.Errorf("unsupported algorithm %q", algorithm)
+}
+
+// validateRepositoryFileSelector checks the fields that select the source
+// object of one file entry, which differ by repository type.
+func validateRepositoryFileSelector(file RepositoryFile, repoType string, repoIndex, fileIndex int) error {
+	typedFields := []struct{ name, value, repoType string }{
+		{name: "asset", value: file.Asset, repoType: RepositoryTypeGitHub},
+		{name: "media_type", value: file.MediaType, repoType: RepositoryTypeOCI},
 	}
-	if _, err := hex.DecodeString(digest); err != nil {
-		return "", errors.New("must contain lowercase hex digest")
+	for _, field := range typedFields {
+		if field.repoType != repoType && strings.TrimSpace(field.value) != "" {
+			return fmt.Errorf("repositories[%d].files[%d].%s requires type %q", repoIndex, fileIndex, field.name, field.repoType)
+		}
 	}
-	return algorithm + ":" + digest, nil
+
+	fileName := strings.TrimSpace(file.FileName)
+	switch repoType {
+	case RepositoryTypeGitHub:
+		asset := strings.TrimSpace(file.Asset)
+		if asset == "" {
+			return fmt.Errorf("repositories[%d].files[%d].asset is required", repoIndex, fileIndex)
+		}
+		if _, err := path.Match(asset, ""); err != nil {
+			return fmt.Errorf("repositories[%d].files[%d].asset %w", repoIndex, fileIndex, err)
+		}
+		if fileName != "" {
+			return fmt.Errorf(
+				"repositories[%d].files[%d].file_name cannot be used with type %q; use asset",
+				repoIndex, fileIndex, RepositoryTypeGitHub,
+			)
+		}
+	case RepositoryTypeGit:
+		if fileName == "" {
+			return fmt.Errorf("repositories[%d].files[%d].file_name is required", repoIndex, fileIndex)
+		}
+		if _, err := path.Match(fileName, ""); err != nil {
+			return fmt.Errorf("repositories[%d].files[%d].file_name %w", repoIndex, fileIndex, err)
+		}
+		if _, err := normalizeExtractPath(fileName); err != nil {
+			return fmt.Errorf("repositories[%d].files[%d].file_name %w", repoIndex, fileIndex, err)
+		}
+		if IsGitDirectorySelector(fileName) && strings.TrimSpace(file.Encoding) != "" {
+			return fmt.Errorf(
+				"repositories[%d].files[%d].encoding cannot be used for git directory or glob selections",
+				repoIndex, fileIndex,
+			)
+		}
+	case RepositoryTypeOCI:
+		if fileName == "" && strings.TrimSpace(file.MediaType) == "" {
+			return fmt.Errorf("repositories[%d].files[%d] requires file_name or media_type to select a layer", repoIndex, fileIndex)
+		}
+		if _, err := path.Match(fileName, ""); err != nil {
+			return fmt.Errorf("repositories[%d].files[%d].file_name %w", repoIndex, fileIndex, err)
+		}
+	default:
+		if fileName == "" {
+			return fmt.Errorf("repositories[%d].files[%d].file_name is required", repoIndex, fileIndex)
+		}
+	}
+	return nil
 }
 
-func isSupportedDigestAlgorithm(value string) bool {
-	switch value {
-	case DigestAlgorithmBLAKE3, DigestAlgorithmSHA256, DigestAlgorithmMD5:
-		return true
+func normalizeRepositorySource(repo Repository, repoIndex int) (Repository, error) {
+	repo.Type = strings.TrimSpace(strings.ToLower(repo.Type))
+	if repo.Type == "" {
+		repo.Type = RepositoryTypeHTTP
+	}
+	if err := rejectForeignRepositoryFields(repo, repoIndex); err != nil {
+		return Repository{}, err
+	}
+	switch repo.Type {
+	case RepositoryTypeHTTP:
+		if strings.TrimSpace(repo.URL) == "" {
+			return Repository{}, fmt.Errorf("repositories[%d].url is required", repoIndex)
+		}
+		if IsLocalSourceLocation(repo.URL) && (len(repo.Headers) > 0 || len(repo.AllowHeaderForwardTo) > 0) {
+			return Repository{}, fmt.Errorf(
+				"repositories[%d] headers and allow_header_forward_to cannot be used with a local url",
+				repoIndex,
+			)
+		}
+	case RepositoryTypeGit:
+		repo.URL = strings.TrimSpace(repo.URL)
+		if repo.URL == "" {
+			return Repository{}, fmt.Errorf("repositories[%d].url is required", repoIndex)
+		}
+		repo.Ref = strings.TrimSpace(repo.Ref)
+		if repo.Ref == "" {
+			repo.Ref = DefaultGitRef
+		}
+		if strings.HasPrefix(repo.URL, "-") || strings.HasPrefix(repo.Ref, "-") {
+			return Repository{}, fmt.Errorf("repositories[%d] url and ref must not start with %q", repoIndex, "-")
+		}
+	case RepositoryTypeOCI:
+		repo.URL = strings.TrimSpace(repo.URL)
+		if repo.URL == "" {
+			return Repository{}, fmt.Errorf("repositories[%d].url is required", repoIndex)
+		}
+		if _, err := ParseOCIReference(repo.URL); err != nil {
+			return Repository{}, fmt.Errorf("repositories[%d].url %w", repoIndex, err)
+		}
+	case RepositoryTypeGitHub:
+		repo.Repo = strings.TrimSpace(repo.Repo)
+		owner, name, ok := strings.Cut(repo.Repo, "/")
+		if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+			return Repository{}, fmt.Errorf("repositories[%d].repo must be in format %q", repoIndex, "owner/repo")
+		}
+		repo.Tag = strings.TrimSpace(repo.Tag)
+		if repo.Tag == "" {
+			repo.Tag = GitHubReleaseTagLatest
+		}
+		repo.URL = strings.TrimSpace(repo.URL)
+		if repo.URL == "" {
+			repo.URL = DefaultGitHubAPIURL
+		}
+		repo.Checksums = strings.TrimSpace(repo.Checksums)
+		if repo.Checksums != "" {
+			if _, err := path.Match(repo.Checksums, ""); err != nil {
+				return Repository{}, fmt.Errorf("repositories[%d].checksums %w", repoIndex, err)
+			}
+		}
 	default:
+		return Repository{}, fmt.Errorf(
+			"repositories[%d].type must be one of %q, %q, %q, %q",
+			repoIndex, RepositoryTypeHTTP, RepositoryTypeGitHub, RepositoryTypeGit, RepositoryTypeOCI,
+		)
+	}
+	return repo, nil
+}
+
+// rejectForeignRepositoryFields reports fields that belong to another repository type.
+func rejectForeignRepositoryFields(repo Repository, repoIndex int) error {
+	typedFields := []struct{ name, value, repoType string }{
+		{name: "repo", value: repo.Repo, repoType: RepositoryTypeGitHub},
+		{name: "tag", value: repo.Tag, repoType: RepositoryTypeGitHub},
+		{name: "checksums", value: repo.Checksums, repoType: RepositoryTypeGitHub},
+		{name: "ref", value: repo.Ref, repoType: RepositoryTypeGit},
+	}
+	for _, field := range typedFields {
+		if field.repoType != repo.Type && strings.TrimSpace(field.value) != "" {
+			return fmt.Errorf("repositories[%d].%s requires type %q", repoIndex, field.name, field.repoType)
+		}
+	}
+	return nil
+}
+
+// IsGitDirectorySelector reports whether a git file_name selects several
+// files: a directory written with a trailing "/" or a glob pattern.
+func IsGitDirectorySelector(fileName string) bool {
+	value := strings.TrimSpace(fileName)
+	return strings.HasSuffix(value, "/") || hasGlobMeta(value)
+}
+
+func repositoryFileSourceName(repoType string, file RepositoryFile) string {
+	if repoType == RepositoryTypeGitHub {
+		return file.Asset
+	}
+	return file.FileName
+}
+
+func buildSource(repo Repository, file RepositoryFile) Source {
+	source := Source{
+		Type:                 repo.Type,
+		Headers:              repo.Headers,
+		AllowHeaderForwardTo: repo.AllowHeaderForwardTo,
+	}
+	switch repo.Type {
+	case RepositoryTypeGitHub:
+		source.URL = strings.TrimRight(repo.URL, "/")
+		source.GitHubRelease = &GitHubReleaseSource{
+			Repo:      repo.Repo,
+			Tag:       repo.Tag,
+			Asset:     strings.TrimSpace(file.Asset),
+			Checksums: repo.Checksums,
+		}
+	case RepositoryTypeOCI:
+		source.URL = repo.URL
+		source.OCI = &OCISource{
+			Title:     strings.TrimSpace(file.FileName),
+			MediaType: strings.TrimSpace(file.MediaType),
+		}
+	case RepositoryTypeGit:
+		source.URL = repo.URL
+		source.Git = &GitSource{
+			Ref:  repo.Ref,
+			Path: strings.TrimSpace(file.FileName),
+		}
+	default:
+		source.URL = joinURL(repo.URL, file.FileName)
+		if IsLocalSourceLocation(repo.URL) {
+			source.Type = SourceTypeFile
+		}
+	}
+	return source
+}
+
+// buildCompanionSource resolves download_digest_from and signature.file: an
+// http(s) URL is used as-is, anything else names a file of the repository like
+// file_name (or asset for github-release). Repository headers are only sent to
+// a URL on the repository host.
+func buildCompanionSource(repo Repository, location string) (Source, error) {
+	if IsRemoteConfigLocation(location) {
+		source := Source{Type: RepositoryTypeHTTP, URL: location}
+		if repo.Type == RepositoryTypeHTTP && sameURLHost(repo.URL, location) {
+			source.Headers = repo.Headers
+			source.AllowHeaderForwardTo = repo.AllowHeaderForwardTo
+		}
+		return source, nil
+	}
+	switch repo.Type {
+	case RepositoryTypeGit:
+		if IsGitDirectorySelector(location) {
+			return Source{}, fmt.Errorf("must name a single file, got %q", location)
+		}
+	case RepositoryTypeGitHub, RepositoryTypeOCI:
+		if _, err := path.Match(location, ""); err != nil {
+			return Source{}, err
+		}
+	}
+	return buildSource(repo, RepositoryFile{FileName: location, Asset: location}), nil
+}
+
+func sameURLHost(left, right string) bool {
+	leftURL, leftErr := url.Parse(strings.TrimSpace(left))
+	rightURL, rightErr := url.Parse(strings.TrimSpace(right))
+	if leftErr != nil || rightErr != nil {
+		return false
+	}
+	return strings.EqualFold(leftURL.Host, rightURL.Host)
+}
+
+// IsLocalSourceLocation reports whether a repository url refers to the local
+// filesystem: a file:// URL or a path without URL scheme.
+func IsLocalSourceLocation(value string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return true
+	}
+	if parsed.Scheme == SourceTypeFile {
+		return true
+	}
+	// A single-letter scheme is a Windows drive letter such as C:\tools.
+	return len(parsed.Scheme) <= 1
+}
+
+// rejectLocalRepositorySource reports a repository url that points at the
+// local filesystem, for a repository of a remote config.
+func rejectLocalRepositorySource(repo Repository, repoIndex int) error {
+	switch repo.Type {
+	case RepositoryTypeHTTP:
+		if IsLocalSourceLocation(repo.URL) {
+			return fmt.Errorf("repositories[%d].url must be an http(s) URL when the config is loaded remotely", repoIndex)
+		}
+	case RepositoryTypeGitHub:
+		if strings.TrimSpace(repo.URL) != "" && IsLocalSourceLocation(repo.URL) {
+			return fmt.Errorf("repositories[%d].url must be an http(s) URL when the config is loaded remotely", repoIndex)
+		}
+	case RepositoryTypeGit:
+		if isLocalGitRemote(repo.URL) {
+			return fmt.Errorf("repositories[%d].url must be a remote git URL when the config is loaded remotely", repoIndex)
+		}
+	}
+	return nil
+}
+
+// isLocalGitRemote reports whether remote is a local repository path or a
+// file:// URL rather than a URL (scheme://...) or scp-like remote
+// (host:path).
+func isLocalGitRemote(remote string) bool {
+	remote = strings.TrimSpace(remote)
+	if strings.HasPrefix(strings.ToLower(remote), SourceTypeFile+":") {
+		return true
+	}
+	if strings.Contains(remote, "://") {
 		return false
 	}
+	// A one-letter host is a Windows drive letter such as C:\repo.
+	colon := strings.Index(remote, ":")
+	return colon <= 1 || strings.Contains(remote[:colon], "/")
 }
 
-func validateRepositoryFile(file RepositoryFile, repoIndex, fileIndex int) (string, string, error) {
-	if strings.TrimSpace(file.FileName) == "" {
-		return "", "", fmt.Errorf("repositories[%d].files[%d].file_name is required", repoIndex, fileIndex)
+func validateRepositoryFile(file RepositoryFile, repoType string, repoIndex, fileIndex int) (string, string, error) {
+	if err := validateRepositoryFileSelector(file, repoType, repoIndex, fileIndex); err != nil {
+		return "", "", err
 	}
 	if strings.TrimSpace(file.OutDir) == "" {
 		return "", "", fmt.Errorf("repositories[%d].files[%d].out_dir is required", repoIndex, fileIndex)
@@ -501,6 +958,8 @@ func deriveTargetName(fileName, encoding, extract string) (string, error) {
 			return "", errors.New("could not determine output filename from extract")
 		}
 		return name, nil
+	case hasGlobMeta(path.Base(fileName)):
+		return "", errors.New("rename is required when the source name is a glob pattern")
 	case encoding == EncodingZstd:
 		base := path.Base(fileName)
 		switch {
@@ -515,3 +974,7 @@ func deriveTargetName(fileName, encoding, extract string) (string, error) {
 		return path.Base(fileName), nil
 	}
 }
+
+func hasGlobMeta(value string) bool {
+	return strings.ContainsAny(value, "*?[")
+}
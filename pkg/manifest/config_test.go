@@ -403,3 +403,682 @@ func TestBuildSyncConfigRejectsUndefinedVarsInExtract(t *testing.T) {
 		t.Fatalf("expected unresolved key in error, got: %v", err)
 	}
 }
+
+func TestBuildSyncConfigNormalizesAllowHeaderForwardTo(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{{
+			URL:                  "https://github.com/o/r/releases/download/v1/",
+			AllowHeaderForwardTo: []string{" *.GitHubUserContent.com ", "objects.example.com:8443"},
+			Files: []RepositoryFile{{
+				FileName: "tool.txt",
+				OutDir:   ".",
+			}},
+		}},
+	}
+
+	resolved, err := BuildSyncConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildSyncConfig returned error: %v", err)
+	}
+	src := resolved.Sources[resolved.Files[0].Source]
+	want := []string{"*.githubusercontent.com", "objects.example.com:8443"}
+	if strings.Join(src.AllowHeaderForwardTo, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected allow_header_forward_to: got=%v want=%v", src.AllowHeaderForwardTo, want)
+	}
+}
+
+func TestBuildSyncConfigRejectsInvalidAllowHeaderForwardTo(t *testing.T) {
+	for _, host := range []string{"", "https://example.com", "example.com/path", "*", "a.*.example.com"} {
+		cfg := &TaskConfig{
+			Version: 1,
+			Repositories: []Repository{{
+				URL:                  "https://example.com/base/",
+				AllowHeaderForwardTo: []string{host},
+				Files: []RepositoryFile{{
+					FileName: "a.txt",
+					OutDir:   ".",
+				}},
+			}},
+		}
+
+		_, err := BuildSyncConfig(cfg)
+		if err == nil {
+			t.Fatalf("expected validation error for host %q", host)
+		}
+		if !strings.Contains(err.Error(), "repositories[0].allow_header_forward_to[0]") {
+			t.Fatalf("expected field path in error for host %q, got: %v", host, err)
+		}
+	}
+}
+
+func TestBuildSyncConfigBuildsGitHubReleaseSource(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{{
+			Type:      "github-release",
+			Repo:      "example/tool",
+			Checksums: "checksums.txt",
+			Files: []RepositoryFile{
+				{
+					Asset:    "tool_*_linux_amd64.tar.gz",
+					Encoding: "tar+gzip",
+					Extract:  "tool",
+					OutDir:   "bin",
+				},
+				{
+					Asset:  "tool.1",
+					OutDir: "man",
+				},
+			},
+		}},
+	}
+
+	resolved, err := BuildSyncConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildSyncConfig returned error: %v", err)
+	}
+	rule := resolved.Files[0]
+	if rule.Path != filepath.Join("bin", "tool") {
+		t.Fatalf("unexpected rule path: %s", rule.Path)
+	}
+	src := resolved.Sources[rule.Source]
+	if src.Type != RepositoryTypeGitHub || src.URL != DefaultGitHubAPIURL {
+		t.Fatalf("unexpected source: %+v", src)
+	}
+	if src.GitHubRelease == nil || src.GitHubRelease.Tag != GitHubReleaseTagLatest || src.GitHubRelease.Asset != "tool_*_linux_amd64.tar.gz" {
+		t.Fatalf("unexpected github release metadata: %+v", src.GitHubRelease)
+	}
+	if got := resolved.Files[1].Path; got != filepath.Join("man", "tool.1") {
+		t.Fatalf("unexpected path for literal asset: %s", got)
+	}
+}
+
+func TestBuildSyncConfigValidatesGitHubReleaseRepository(t *testing.T) {
+	cases := []struct {
+		name    string
+		repo    Repository
+		message string
+	}{
+		{
+			name:    "invalid repo",
+			repo:    Repository{Type: "github-release", Repo: "tool", Files: []RepositoryFile{{Asset: "a", OutDir: "."}}},
+			message: "repositories[0].repo",
+		},
+		{
+			name:    "missing asset",
+			repo:    Repository{Type: "github-release", Repo: "o/r", Files: []RepositoryFile{{OutDir: "."}}},
+			message: "repositories[0].files[0].asset is required",
+		},
+		{
+			name:    "glob without rename",
+			repo:    Repository{Type: "github-release", Repo: "o/r", Files: []RepositoryFile{{Asset: "tool_*", OutDir: "."}}},
+			message: "rename is required",
+		},
+		{
+			name:    "asset on http repository",
+			repo:    Repository{URL: "https://example.com/", Files: []RepositoryFile{{FileName: "a", Asset: "a", OutDir: "."}}},
+			message: "asset requires type",
+		},
+		{
+			name:    "tag on http repository",
+			repo:    Repository{URL: "https://example.com/", Tag: "v1", Files: []RepositoryFile{{FileName: "a", OutDir: "."}}},
+			message: "repositories[0].tag requires type",
+		},
+		{
+			name:    "unknown type",
+			repo:    Repository{Type: "ftp", URL: "ftp://example.com/", Files: []RepositoryFile{{FileName: "a", OutDir: "."}}},
+			message: "repositories[0].type must be one of",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := BuildSyncConfig(&TaskConfig{Version: 1, Repositories: []Repository{tc.repo}})
+			if err == nil {
+				t.Fatalf("expected validation error")
+			}
+			if !strings.Contains(err.Error(), tc.message) {
+				t.Fatalf("expected %q in error, got: %v", tc.message, err)
+			}
+		})
+	}
+}
+
+func TestBuildSyncConfigBuildsGitSources(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{{
+			Type: "git",
+			URL:  "git@github.com:example/bootkit.git",
+			Files: []RepositoryFile{
+				{FileName: "AGENTS.md", OutDir: "."},
+				{FileName: ".devcontainer/", OutDir: ".devcontainer"},
+				{FileName: "configs/*.json", OutDir: "configs"},
+			},
+		}},
+	}
+
+	resolved, err := BuildSyncConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildSyncConfig returned error: %v", err)
+	}
+	first := resolved.Files[0]
+	if first.Path != "AGENTS.md" || first.ExpandDirectory {
+		t.Fatalf("unexpected single-file rule: %+v", first)
+	}
+	src := resolved.Sources[first.Source]
+	if src.Git == nil || src.Git.Ref != DefaultGitRef || src.Git.Path != "AGENTS.md" {
+		t.Fatalf("unexpected git source: %+v", src.Git)
+	}
+	for _, rule := range resolved.Files[1:] {
+		if !rule.ExpandDirectory {
+			t.Fatalf("expected directory expansion for %+v", rule)
+		}
+	}
+	if got := resolved.Files[1].Path; got != ".devcontainer" {
+		t.Fatalf("unexpected directory rule path: %s", got)
+	}
+}
+
+func TestBuildSyncConfigRejectsDigestForGitDirectorySelection(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{{
+			Type: "git",
+			URL:  "https://example.com/repo.git",
+			Ref:  "main",
+			Files: []RepositoryFile{{
+				FileName:       "templates/",
+				OutDir:         ".",
+				DownloadDigest: "sha256:abcdef",
+			}},
+		}},
+	}
+
+	if _, err := BuildSyncConfig(cfg); err == nil {
+		t.Fatalf("expected git directory digest validation error")
+	}
+}
+
+func TestBuildSyncConfigDetectsLocalRepositoryURLs(t *testing.T) {
+	cases := map[string]string{
+		"../bootkit":                SourceTypeFile,
+		"/srv/bootkit":              SourceTypeFile,
+		"file:///srv/bootkit":       SourceTypeFile,
+		"https://example.com/base/": RepositoryTypeHTTP,
+	}
+	for location, wantType := range cases {
+		cfg := &TaskConfig{
+			Version: 1,
+			Repositories: []Repository{{
+				URL:   location,
+				Files: []RepositoryFile{{FileName: "AGENTS.md", OutDir: "."}},
+			}},
+		}
+		resolved, err := BuildSyncConfig(cfg)
+		if err != nil {
+			t.Fatalf("BuildSyncConfig(%s) returned error: %v", location, err)
+		}
+		src := resolved.Sources[resolved.Files[0].Source]
+		if src.Type != wantType {
+			t.Fatalf("unexpected source type for %s: got=%q want=%q", location, src.Type, wantType)
+		}
+	}
+}
+
+func TestBuildSyncConfigRejectsLocalRepositoryURLs(t *testing.T) {
+	withHeaders := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{{
+			URL:     "../bootkit",
+			Headers: map[string]string{"Authorization": "token"},
+			Files:   []RepositoryFile{{FileName: "AGENTS.md", OutDir: "."}},
+		}},
+	}
+	if _, err := BuildSyncConfig(withHeaders); err == nil {
+		t.Fatalf("expected headers to be rejected for local url")
+	}
+
+	remoteConfig := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{{
+			URL:   "file:///etc",
+			Files: []RepositoryFile{{FileName: "passwd", OutDir: "."}},
+		}},
+	}
+	_, err := BuildSyncConfigWithOptions(remoteConfig, BuildSyncConfigOptions{RejectLocalSources: true})
+	if err == nil {
+		t.Fatalf("expected local url to be rejected for remote config")
+	}
+	if !strings.Contains(err.Error(), "repositories[0].url") {
+		t.Fatalf("expected field path in error, got: %v", err)
+	}
+}
+
+func TestBuildSyncConfigRejectsLocalSourcesOfRemoteConfigs(t *testing.T) {
+	file := func(signature *SignatureSpec) []RepositoryFile {
+		return []RepositoryFile{{FileName: "tool", OutDir: ".", Signature: signature}}
+	}
+	keyFile := &SignatureSpec{Type: SignatureTypeMinisign, KeyFile: "/etc/key.pub"}
+	tests := []struct {
+		name   string
+		repo   Repository
+		remote bool
+		want   string
+	}{
+		{name: "git path", repo: Repository{Type: RepositoryTypeGit, URL: "../repo", Files: file(nil)}, remote: true, want: "repositories[0].url must be a remote git URL"},
+		{name: "git file url", repo: Repository{Type: RepositoryTypeGit, URL: "file:///srv/repo", Files: file(nil)}, remote: true, want: "repositories[0].url must be a remote git URL"},
+		{name: "git https", repo: Repository{Type: RepositoryTypeGit, URL: "https://example.com/repo.git", Files: file(nil)}, remote: true},
+		{name: "git scp-like", repo: Repository{Type: RepositoryTypeGit, URL: "git@example.com:org/repo.git", Files: file(nil)}, remote: true},
+		{name: "github-release api", repo: Repository{Type: RepositoryTypeGitHub, Repo: "org/repo", URL: "file:///srv/api", Files: []RepositoryFile{{Asset: "tool", OutDir: "."}}}, remote: true, want: "repositories[0].url must be an http(s) URL"},
+		{name: "key_file", repo: Repository{URL: "https://example.com/", Files: file(keyFile)}, remote: true, want: "repositories[0].files[0].signature.key_file cannot be used"},
+		{name: "key_file of a local config", repo: Repository{URL: "https://example.com/", Files: file(keyFile)}},
+		{name: "remote include", repo: Repository{URL: "../tools", Origin: "https://example.com/shared.yaml", Files: file(nil)}, want: "https://example.com/shared.yaml: repositories[0].url must be an http(s) URL"},
+		{name: "local include", repo: Repository{URL: "../tools", Origin: "/work/shared.yaml", Files: file(nil)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &TaskConfig{Version: 1, Repositories: []Repository{tt.repo}}
+			_, err := BuildSyncConfigWithOptions(cfg, BuildSyncConfigOptions{RejectLocalSources: tt.remote})
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Fatalf("expected error starting with %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestBuildSyncConfigBuildsDownloadDigestFromSources(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{
+			{
+				URL:     "https://example.com/releases/v1/",
+				Headers: map[string]string{"Authorization": "token"},
+				Files: []RepositoryFile{
+					{FileName: "tool_linux", DownloadDigestFrom: "SHA256SUMS", OutDir: "."},
+					{FileName: "tool_darwin", DownloadDigestFrom: "SHA256SUMS", OutDir: "."},
+					{FileName: "tool_windows", DownloadDigestFrom: "https://mirror.example.org/SHA256SUMS", OutDir: "."},
+				},
+			},
+			{
+				Type:  "github-release",
+				Repo:  "o/r",
+				Files: []RepositoryFile{{Asset: "tool.tar.gz", DownloadDigestFrom: "*_checksums.txt", OutDir: "."}},
+			},
+		},
+	}
+
+	resolved, err := BuildSyncConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildSyncConfig returned error: %v", err)
+	}
+	first, second := resolved.Files[0], resolved.Files[1]
+	if first.DownloadChecksumFrom == "" || first.DownloadChecksumFrom != second.DownloadChecksumFrom {
+		t.Fatalf("expected files to share one checksum source: %q %q", first.DownloadChecksumFrom, second.DownloadChecksumFrom)
+	}
+	sums := resolved.Sources[first.DownloadChecksumFrom]
+	if sums.URL != "https://example.com/releases/v1/SHA256SUMS" || sums.Headers["Authorization"] != "token" {
+		t.Fatalf("unexpected checksum source: %+v", sums)
+	}
+	mirror := resolved.Sources[resolved.Files[2].DownloadChecksumFrom]
+	if mirror.URL != "https://mirror.example.org/SHA256SUMS" || len(mirror.Headers) != 0 {
+		t.Fatalf("expected mirror checksum source without headers: %+v", mirror)
+	}
+	release := resolved.Sources[resolved.Files[3].DownloadChecksumFrom]
+	if release.GitHubRelease == nil || release.GitHubRelease.Asset != "*_checksums.txt" {
+		t.Fatalf("unexpected github checksum source: %+v", release)
+	}
+}
+
+func TestBuildSyncConfigRejectsDownloadDigestFromForGitDirectorySelection(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{{
+			Type: "git",
+			URL:  "https://example.com/repo.git",
+			Files: []RepositoryFile{{
+				FileName:           "templates/",
+				OutDir:             ".",
+				DownloadDigestFrom: "SHA256SUMS",
+			}},
+		}},
+	}
+
+	if _, err := BuildSyncConfig(cfg); err == nil {
+		t.Fatalf("expected git directory download_digest_from validation error")
+	}
+}
+
+func TestBuildSyncConfigBuildsSignatureRules(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{{
+			URL: "https://example.com/releases/",
+			Files: []RepositoryFile{
+				{FileName: "tool", OutDir: "bin", Signature: &SignatureSpec{Type: "Minisign", Key: "RWQ..."}},
+				{FileName: "other", OutDir: "bin", Signature: &SignatureSpec{Type: "openpgp", File: "SHA256SUMS.asc", KeyFile: "keys/release.asc"}},
+			},
+		}},
+	}
+
+	resolved, err := BuildSyncConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildSyncConfig returned error: %v", err)
+	}
+	first := resolved.Files[0].Signature
+	if first == nil || first.Type != SignatureTypeMinisign || first.Key != "RWQ..." {
+		t.Fatalf("unexpected signature rule: %+v", first)
+	}
+	if got := resolved.Sources[first.Source].URL; got != "https://example.com/releases/tool.minisig" {
+		t.Fatalf("unexpected default signature url: %s", got)
+	}
+	second := resolved.Files[1].Signature
+	if got := resolved.Sources[second.Source].URL; got != "https://example.com/releases/SHA256SUMS.asc" || second.KeyFile != "keys/release.asc" {
+		t.Fatalf("unexpected signature rule: %+v url=%s", second, got)
+	}
+}
+
+func TestBuildSyncConfigValidatesSignature(t *testing.T) {
+	cases := []struct {
+		name      string
+		signature SignatureSpec
+		message   string
+	}{
+		{name: "unknown type", signature: SignatureSpec{Type: "x509", Key: "k"}, message: "signature type must be one of"},
+		{name: "missing key", signature: SignatureSpec{Type: "cosign"}, message: "exactly one of key or key_file"},
+		{name: "both keys", signature: SignatureSpec{Type: "cosign", Key: "k", KeyFile: "k.pub"}, message: "exactly one of key or key_file"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			signature := tc.signature
+			cfg := &TaskConfig{
+				Version: 1,
+				Repositories: []Repository{{
+					URL:   "https://example.com/",
+					Files: []RepositoryFile{{FileName: "tool", OutDir: ".", Signature: &signature}},
+				}},
+			}
+			_, err := BuildSyncConfig(cfg)
+			if err == nil || !strings.Contains(err.Error(), tc.message) {
+				t.Fatalf("expected %q in error, got: %v", tc.message, err)
+			}
+		})
+	}
+}
+
+func TestBuildSyncConfigAppliesSyncFilter(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{
+			{
+				Comment: "docs",
+				URL:     "https://example.com/docs/",
+				Files: []RepositoryFile{
+					{FileName: "README.md", OutDir: "."},
+					{FileName: "CONTRIBUTING.md", OutDir: "docs"},
+				},
+			},
+			{
+				URL:  "https://example.com/tools/",
+				Tags: []string{"tools"},
+				Files: []RepositoryFile{
+					{FileName: "lint", OutDir: "bin", Tags: []string{"ci"}},
+					{FileName: "fmt", OutDir: "bin"},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		name   string
+		filter SyncFilter
+		paths  []string
+	}{
+		{name: "repository tag", filter: SyncFilter{Only: []string{"tools"}}, paths: []string{"bin/lint", "bin/fmt"}},
+		{name: "file tag", filter: SyncFilter{Only: []string{"ci"}}, paths: []string{"bin/lint"}},
+		{name: "comment", filter: SyncFilter{Only: []string{"docs"}}, paths: []string{"README.md", "docs/CONTRIBUTING.md"}},
+		{name: "path glob", filter: SyncFilter{Only: []string{"*.md"}}, paths: []string{"README.md", "docs/CONTRIBUTING.md"}},
+		{name: "only and skip", filter: SyncFilter{Only: []string{"tools"}, Skip: []string{"ci"}}, paths: []string{"bin/fmt"}},
+		{name: "skip path", filter: SyncFilter{Skip: []string{"docs/*"}}, paths: []string{"README.md", "bin/lint", "bin/fmt"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolved, err := BuildSyncConfigWithOptions(cfg, BuildSyncConfigOptions{Filter: tc.filter})
+			if err != nil {
+				t.Fatalf("BuildSyncConfigWithOptions returned error: %v", err)
+			}
+			var paths []string
+			for _, rule := range resolved.Files {
+				paths = append(paths, rule.Path)
+				if _, ok := resolved.Sources[rule.Source]; !ok {
+					t.Fatalf("missing source for %s", rule.Path)
+				}
+			}
+			if strings.Join(paths, ",") != strings.Join(tc.paths, ",") {
+				t.Fatalf("expected %v, got %v", tc.paths, paths)
+			}
+			if len(resolved.Sources) != len(tc.paths) {
+				t.Fatalf("expected only selected sources, got %d", len(resolved.Sources))
+			}
+		})
+	}
+}
+
+func TestBuildSyncConfigRejectsUnmatchedOnlyFilter(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{{
+			URL:   "https://example.com/",
+			Files: []RepositoryFile{{FileName: "a.txt", OutDir: "."}},
+		}},
+	}
+	_, err := BuildSyncConfigWithOptions(cfg, BuildSyncConfigOptions{Filter: SyncFilter{Only: []string{"a.txt", "missing"}}})
+	if err == nil || !strings.Contains(err.Error(), `only filter "missing" matches no repository file`) {
+		t.Fatalf("expected unmatched only filter error, got: %v", err)
+	}
+}
+
+func TestBuildSyncConfigRejectsEmptyTags(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{{
+			URL:   "https://example.com/",
+			Files: []RepositoryFile{{FileName: "a.txt", OutDir: ".", Tags: []string{"ok", " "}}},
+		}},
+	}
+	_, err := BuildSyncConfig(cfg)
+	if err == nil || !strings.Contains(err.Error(), "repositories[0].files[0].tags[1] must not be empty") {
+		t.Fatalf("expected empty tag error, got: %v", err)
+	}
+}
+
+func TestBuildSyncConfigEnablesRenderWithVars(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Vars:    map[string]string{"NAME": "api"},
+		Repositories: []Repository{{
+			URL: "https://example.com/",
+			Files: []RepositoryFile{
+				{FileName: "AGENTS.md", OutDir: ".", Render: true},
+				{FileName: "devcontainer.json", OutDir: ".devcontainer", Template: "Go"},
+				{FileName: "README.md", OutDir: "."},
+			},
+		}},
+	}
+	resolved, err := BuildSyncConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildSyncConfig returned error: %v", err)
+	}
+	for index, want := range []bool{true, true, false} {
+		rule := resolved.Files[index]
+		if rule.Render != want || (rule.Vars["NAME"] == "api") != want {
+			t.Fatalf("unexpected render rule %d: %+v", index, rule)
+		}
+	}
+}
+
+func TestBuildSyncConfigValidatesRenderAndMerge(t *testing.T) {
+	cases := []struct {
+		name    string
+		file    RepositoryFile
+		message string
+	}{
+		{name: "unknown template", file: RepositoryFile{FileName: "a.txt", OutDir: ".", Template: "jinja"}, message: `repositories[0].files[0].template must be "go"`},
+		{name: "multi output", file: RepositoryFile{FileName: "a.tar.gz", OutDir: ".", Encoding: "tar+gzip", Render: true}, message: "render cannot be used when the entry resolves to multiple files"},
+		{name: "unknown merge", file: RepositoryFile{FileName: "a.txt", OutDir: ".", Merge: "ours"}, message: `repositories[0].files[0].merge must be one of "three-way", "json", "yaml", "toml"`},
+		{name: "unknown merge arrays", file: RepositoryFile{FileName: "a.json", OutDir: ".", Merge: "json", MergeArrays: "zip"}, message: `repositories[0].files[0].merge_arrays must be one of "replace", "append", "union"`},
+		{name: "merge arrays without structured merge", file: RepositoryFile{FileName: "a.txt", OutDir: ".", MergeArrays: "union"}, message: "merge_arrays requires merge"},
+		{name: "multi output merge", file: RepositoryFile{FileName: "a.tar.gz", OutDir: ".", Encoding: "tar+gzip", Merge: "three-way"}, message: "merge cannot be used when the entry resolves to multiple files"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &TaskConfig{
+				Version:      1,
+				Repositories: []Repository{{URL: "https://example.com/", Files: []RepositoryFile{tc.file}}},
+			}
+			_, err := BuildSyncConfig(cfg)
+			if err == nil || !strings.Contains(err.Error(), tc.message) {
+				t.Fatalf("expected %q in error, got: %v", tc.message, err)
+			}
+		})
+	}
+}
+
+func TestBuildSyncConfigResolvesChangeHooks(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Tasks: map[string]TaskDef{
+			"chmod":  {Run: "chmod +x bin/*"},
+			"npm-ci": {Run: "npm ci"},
+			"notify": {Run: "echo synced"},
+		},
+		Sync: &SyncSettings{After: []string{"notify"}},
+		Repositories: []Repository{{
+			URL:      "https://example.com/",
+			OnChange: []string{"chmod"},
+			Files: []RepositoryFile{
+				{FileName: "tool", OutDir: "bin", OnChange: []string{"npm-ci", "chmod"}},
+				{FileName: "other", OutDir: "bin"},
+			},
+		}},
+	}
+	resolved, err := BuildSyncConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildSyncConfig returned error: %v", err)
+	}
+	if got := strings.Join(resolved.Files[0].OnChange, ","); got != "chmod,npm-ci" {
+		t.Fatalf("unexpected file hooks: %s", got)
+	}
+	if got := strings.Join(resolved.Files[1].OnChange, ","); got != "chmod" {
+		t.Fatalf("unexpected inherited hooks: %s", got)
+	}
+	if got := strings.Join(resolved.After, ","); got != "notify" {
+		t.Fatalf("unexpected sync.after: %s", got)
+	}
+}
+
+func TestBuildSyncConfigRejectsUndefinedHookTasks(t *testing.T) {
+	cases := []struct {
+		name    string
+		cfg     TaskConfig
+		message string
+	}{
+		{
+			name:    "sync after",
+			cfg:     TaskConfig{Version: 1, Sync: &SyncSettings{After: []string{"missing"}}},
+			message: `sync.after[0] references undefined task "missing"`,
+		},
+		{
+			name: "repository",
+			cfg: TaskConfig{Version: 1, Repositories: []Repository{{
+				URL: "https://example.com/", OnChange: []string{"missing"},
+				Files: []RepositoryFile{{FileName: "a", OutDir: "."}},
+			}}},
+			message: `repositories[0].on_change[0] references undefined task "missing"`,
+		},
+		{
+			name: "file",
+			cfg: TaskConfig{Version: 1, Repositories: []Repository{{
+				URL:   "https://example.com/",
+				Files: []RepositoryFile{{FileName: "a", OutDir: ".", OnChange: []string{"missing"}}},
+			}}},
+			message: `repositories[0].files[0].on_change[0] references undefined task "missing"`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := tc.cfg
+			_, err := BuildSyncConfig(&cfg)
+			if err == nil || !strings.Contains(err.Error(), tc.message) {
+				t.Fatalf("expected %q in error, got: %v", tc.message, err)
+			}
+		})
+	}
+}
+
+func TestBuildSyncConfigBuildsManagedBlocks(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{{
+			URL: "https://example.com/",
+			Files: []RepositoryFile{
+				{FileName: "gitignore", OutDir: ".", Rename: ".gitignore", Placement: "block", Block: &BlockSpec{ID: "org"}},
+				{FileName: "agents.md", OutDir: ".", Rename: "AGENTS.md", Placement: "block", Block: &BlockSpec{Begin: "<!-- vorbere:begin -->", End: "<!-- vorbere:end -->"}},
+				{FileName: "editorconfig", OutDir: ".", Rename: ".editorconfig", Placement: "block"},
+			},
+		}},
+	}
+	resolved, err := BuildSyncConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildSyncConfig returned error: %v", err)
+	}
+	want := []BlockRule{
+		{Begin: "# >>> vorbere:org", End: "# <<< vorbere:org"},
+		{Begin: "<!-- vorbere:begin -->", End: "<!-- vorbere:end -->"},
+		{Begin: "# >>> vorbere:managed", End: "# <<< vorbere:managed"},
+	}
+	for index, rule := range resolved.Files {
+		if rule.Block == nil || *rule.Block != want[index] {
+			t.Fatalf("unexpected block rule %d: %+v", index, rule.Block)
+		}
+	}
+}
+
+func TestBuildSyncConfigValidatesManagedBlocks(t *testing.T) {
+	cases := []struct {
+		name    string
+		files   []RepositoryFile
+		message string
+	}{
+		{name: "unknown placement", files: []RepositoryFile{{FileName: "a", OutDir: ".", Placement: "line"}}, message: `repositories[0].files[0].placement must be "file" or "block"`},
+		{name: "block without placement", files: []RepositoryFile{{FileName: "a", OutDir: ".", Block: &BlockSpec{ID: "x"}}}, message: `repositories[0].files[0].block requires placement "block"`},
+		{name: "same markers", files: []RepositoryFile{{FileName: "a", OutDir: ".", Placement: "block", Block: &BlockSpec{Begin: "#", End: "#"}}}, message: "begin and end markers must differ"},
+		{name: "with merge", files: []RepositoryFile{{FileName: "a", OutDir: ".", Placement: "block", Merge: "three-way"}}, message: "cannot be combined with merge"},
+		{
+			name: "duplicate block",
+			files: []RepositoryFile{
+				{FileName: "a", OutDir: ".", Rename: ".gitignore", Placement: "block"},
+				{FileName: "b", OutDir: ".", Rename: ".gitignore", Placement: "block"},
+			},
+			message: `repositories[0].files[1].block "# >>> vorbere:managed" is already managed by repositories[0].files[0]`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &TaskConfig{
+				Version:      1,
+				Repositories: []Repository{{URL: "https://example.com/", Files: tc.files}},
+			}
+			_, err := BuildSyncConfig(cfg)
+			if err == nil || !strings.Contains(err.Error(), tc.message) {
+				t.Fatalf("expected %q in error, got: %v", tc.message, err)
+			}
+		})
+	}
+}
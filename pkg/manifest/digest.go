@@ -0,0 +1,143 @@
+package manifest
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Digests holds one digest or several digests that must all match. In YAML it
+// is a scalar or a sequence; entries are stored comma separated.
+type Digests string
+
+// UnmarshalYAML accepts a single digest or a list of digests.
+func (d *Digests) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		var entries []string
+		if err := value.Decode(&entries); err != nil {
+			return err
+		}
+		*d = Digests(strings.Join(entries, ","))
+		return nil
+	}
+	var entry string
+	if err := value.Decode(&entry); err != nil {
+		return err
+	}
+	*d = Digests(entry)
+	return nil
+}
+
+// List returns the individual digest entries.
+func (d Digests) List() []string {
+	entries := make([]string, 0)
+	for _, entry := range strings.Split(string(d), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// digestSizes maps supported algorithms to their digest length in bytes,
+// used to validate SRI digests.
+var digestSizes = map[string]int{
+	DigestAlgorithmBLAKE3: 32,
+	DigestAlgorithmSHA256: 32,
+	DigestAlgorithmSHA384: 48,
+	DigestAlgorithmSHA512: 64,
+	DigestAlgorithmSHA1:   20,
+	DigestAlgorithmMD5:    16,
+}
+
+// normalizeDigests normalizes every entry to "<algorithm>:<hex>" and joins
+// them with commas.
+func normalizeDigests(value Digests) (string, error) {
+	entries := value.List()
+	normalized := make([]string, 0, len(entries))
+	for index, entry := range entries {
+		digest, err := normalizeDigest(entry)
+		if err != nil {
+			if len(entries) > 1 {
+				return "", fmt.Errorf("[%d] %w", index, err)
+			}
+			return "", err
+		}
+		normalized = append(normalized, digest)
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+// normalizeDigest accepts "<algorithm>:<hex>" and SRI style
+// "<algorithm>-<base64>" (sha256, sha384 and sha512 only).
+func normalizeDigest(value string) (string, error) {
+	raw := strings.TrimSpace(value)
+	if raw == "" {
+		return "", nil
+	}
+	if algorithm, encoded, ok := strings.Cut(raw, "-"); ok && !strings.Contains(raw, ":") {
+		return normalizeSRIDigest(strings.ToLower(algorithm), encoded)
+	}
+
+	raw = strings.ToLower(raw)
+	algorithm, digest, ok := strings.Cut(raw, ":")
+	if !ok || strings.TrimSpace(algorithm) == "" || strings.TrimSpace(digest) == "" {
+		return "", fmt.Errorf(
+			"must be in format %q or %q",
+			"<algorithm>:<hex>",
+			"<algorithm>-<base64>",
+		)
+	}
+	if !isSupportedDigestAlgorithm(algorithm) {
+		return "", fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", errors.New("must contain lowercase hex digest")
+	}
+	return algorithm + ":" + digest, nil
+}
+
+func normalizeSRIDigest(algorithm, encoded string) (string, error) {
+	switch algorithm {
+	case DigestAlgorithmSHA256, DigestAlgorithmSHA384, DigestAlgorithmSHA512:
+	default:
+		return "", fmt.Errorf("unsupported SRI algorithm %q", algorithm)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errors.New("must contain base64 digest in SRI format")
+	}
+	if len(decoded) != digestSizes[algorithm] {
+		return "", fmt.Errorf("%s digest must be %d bytes", algorithm, digestSizes[algorithm])
+	}
+	return algorithm + ":" + hex.EncodeToString(decoded), nil
+}
+
+func isSupportedDigestAlgorithm(value string) bool {
+	_, ok := digestSizes[value]
+	return ok
+}
+
+func isDeprecatedDigestAlgorithm(value string) bool {
+	return value == DigestAlgorithmSHA1 || value == DigestAlgorithmMD5
+}
+
+// deprecatedDigestWarnings reports normalized digest entries that use an
+// algorithm kept only for legacy mirrors.
+func deprecatedDigestWarnings(normalized, fieldPath string) []string {
+	var warnings []string
+	for _, entry := range Digests(normalized).List() {
+		algorithm, _, _ := strings.Cut(entry, ":")
+		if isDeprecatedDigestAlgorithm(algorithm) {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s uses deprecated algorithm %q; prefer %s or stronger",
+				fieldPath, algorithm, DigestAlgorithmSHA256,
+			))
+		}
+	}
+	return warnings
+}
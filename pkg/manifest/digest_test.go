@@ -0,0 +1,93 @@
+package manifest
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDigestsUnmarshalAcceptsScalarAndList(t *testing.T) {
+	var file RepositoryFile
+	body := `
+file_name: tool
+download_digest:
+  - sha256:abcdef
+  - sha512:012345
+output_digest: blake3:fedcba
+`
+	if err := yaml.Unmarshal([]byte(body), &file); err != nil {
+		t.Fatalf("yaml.Unmarshal returned error: %v", err)
+	}
+	if got := file.DownloadDigest.List(); len(got) != 2 || got[1] != "sha512:012345" {
+		t.Fatalf("unexpected download digests: %v", got)
+	}
+	if got := file.OutputDigest.List(); len(got) != 1 || got[0] != "blake3:fedcba" {
+		t.Fatalf("unexpected output digests: %v", got)
+	}
+}
+
+func TestNormalizeDigestAcceptsSRI(t *testing.T) {
+	sum := sha512.Sum512([]byte("tool"))
+	got, err := normalizeDigest("sha512-" + base64.StdEncoding.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatalf("normalizeDigest returned error: %v", err)
+	}
+	if want := "sha512:" + hex.EncodeToString(sum[:]); got != want {
+		t.Fatalf("expected %s got %s", want, got)
+	}
+
+	for _, value := range []string{"sha512-notbase64!", "sha512-" + base64.StdEncoding.EncodeToString(sum[:16]), "md5-AAAA"} {
+		if _, err := normalizeDigest(value); err == nil {
+			t.Fatalf("expected error for %q", value)
+		}
+	}
+}
+
+func TestBuildSyncConfigNormalizesDigestListsAndWarnsOnDeprecatedAlgorithms(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{{
+			URL: "https://example.com/base/",
+			Files: []RepositoryFile{
+				{FileName: "a.txt", OutDir: ".", DownloadDigest: "SHA384:ABCDEF,sha1:012345"},
+				{FileName: "b.txt", OutDir: ".", OutputDigest: "md5:abcdef"},
+				{FileName: "c.txt", OutDir: ".", DownloadDigest: "sha512:abcdef"},
+			},
+		}},
+	}
+
+	resolved, err := BuildSyncConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildSyncConfig returned error: %v", err)
+	}
+	if got := resolved.Files[0].DownloadChecksum; got != "sha384:abcdef,sha1:012345" {
+		t.Fatalf("unexpected checksum list: %s", got)
+	}
+	if len(resolved.Warnings) != 2 {
+		t.Fatalf("expected 2 deprecation warnings, got %v", resolved.Warnings)
+	}
+	if !strings.Contains(resolved.Warnings[0], `repositories[0].files[0].download_digest uses deprecated algorithm "sha1"`) {
+		t.Fatalf("unexpected warning: %s", resolved.Warnings[0])
+	}
+	if !strings.Contains(resolved.Warnings[1], `repositories[0].files[1].output_digest uses deprecated algorithm "md5"`) {
+		t.Fatalf("unexpected warning: %s", resolved.Warnings[1])
+	}
+}
+
+func TestBuildSyncConfigRejectsInvalidDigestListEntry(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{{
+			URL:   "https://example.com/base/",
+			Files: []RepositoryFile{{FileName: "a.txt", OutDir: ".", DownloadDigest: "sha256:abcdef,crc32:0000"}},
+		}},
+	}
+	_, err := BuildSyncConfig(cfg)
+	if err == nil || !strings.Contains(err.Error(), `download_digest [1] unsupported algorithm "crc32"`) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package manifest
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SyncFilter selects repository files before download. Each value matches a
+// tag of the repository or file, a repository _comment, or a glob against
+// the output path or its base name.
+type SyncFilter struct {
+	Only []string
+	Skip []string
+}
+
+func (f SyncFilter) isEmpty() bool {
+	return len(f.Only) == 0 && len(f.Skip) == 0
+}
+
+// selects reports whether the file passes the filter and records which Only
+// values matched.
+func (f SyncFilter) selects(repo Repository, file RepositoryFile, targetPath string, matchedOnly map[string]bool) bool {
+	selected := len(f.Only) == 0
+	for _, value := range f.Only {
+		if matchesSyncFilter(value, repo, file, targetPath) {
+			matchedOnly[value] = true
+			selected = true
+		}
+	}
+	if !selected {
+		return false
+	}
+	for _, value := range f.Skip {
+		if matchesSyncFilter(value, repo, file, targetPath) {
+			return false
+		}
+	}
+	return true
+}
+
+// unmatchedOnly reports the first Only value that selected no file.
+func (f SyncFilter) unmatchedOnly(matchedOnly map[string]bool) error {
+	for _, value := range f.Only {
+		if !matchedOnly[value] {
+			return fmt.Errorf("only filter %q matches no repository file", value)
+		}
+	}
+	return nil
+}
+
+func matchesSyncFilter(value string, repo Repository, file RepositoryFile, targetPath string) bool {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false
+	}
+	for _, tags := range [][]string{repo.Tags, file.Tags} {
+		for _, tag := range tags {
+			if tag == value {
+				return true
+			}
+		}
+	}
+	if strings.TrimSpace(repo.Comment) == value {
+		return true
+	}
+	target := filepath.ToSlash(filepath.Clean(targetPath))
+	pattern := strings.TrimPrefix(value, "./")
+	for _, candidate := range []string{target, path.Base(target)} {
+		if ok, _ := path.Match(pattern, candidate); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func validateTags(tags []string, fieldPath string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	normalized := make([]string, 0, len(tags))
+	for index, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return nil, fmt.Errorf("%s.tags[%d] must not be empty", fieldPath, index)
+		}
+		normalized = append(normalized, tag)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,108 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// taskIssues checks that every task has run or depends_on, then the task
+// graph.
+func taskIssues(tasks map[string]TaskDef) []ConfigIssue {
+	var issues []ConfigIssue
+	for _, name := range sortedTaskNames(tasks) {
+		task := tasks[name]
+		if task.Run == "" && len(task.DependsOn) == 0 {
+			issues = append(issues, ConfigIssue{Path: "tasks." + name, Origin: task.Origin, Message: "must have run or depends_on"})
+		}
+	}
+	return append(issues, graphIssues(tasks)...)
+}
+
+// ValidateTaskGraph checks that depends_on entries name defined tasks and
+// that there are no dependency cycles. The first problem is returned.
+func ValidateTaskGraph(cfg *TaskConfig) error {
+	if issues := graphIssues(cfg.Tasks); len(issues) > 0 {
+		return issues[0]
+	}
+	return nil
+}
+
+// graphIssues reports undefined depends_on entries in task name order, then
+// dependency cycles.
+func graphIssues(tasks map[string]TaskDef) []ConfigIssue {
+	var issues []ConfigIssue
+	for _, name := range sortedTaskNames(tasks) {
+		task := tasks[name]
+		for index, dep := range task.DependsOn {
+			if _, ok := tasks[dep]; !ok {
+				issues = append(issues, ConfigIssue{
+					Path:    fmt.Sprintf("tasks.%s.depends_on[%d]", name, index),
+					Origin:  task.Origin,
+					Message: fmt.Sprintf("references undefined task %q", dep),
+				})
+			}
+		}
+	}
+	for _, cycle := range dependencyCycles(tasks) {
+		issues = append(issues, ConfigIssue{
+			Path:    "tasks." + cycle[0] + ".depends_on",
+			Origin:  tasks[cycle[0]].Origin,
+			Message: "dependency cycle: " + strings.Join(cycle, " -> "),
+		})
+	}
+	return issues
+}
+
+func sortedTaskNames(tasks map[string]TaskDef) []string {
+	names := make([]string, 0, len(tasks))
+	for name := range tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dependencyCycles returns each depends_on cycle once as the path from its
+// first visited task back to itself, for example [a b a]. Tasks are visited
+// in name order.
+func dependencyCycles(tasks map[string]TaskDef) [][]string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var stack []string
+	var cycles [][]string
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, dep := range tasks[name].DependsOn {
+			if _, ok := tasks[dep]; !ok {
+				continue
+			}
+			switch state[dep] {
+			case unvisited:
+				visit(dep)
+			case visiting:
+				start := len(stack) - 1
+				for stack[start] != dep {
+					start--
+				}
+				cycle := append(append([]string{}, stack[start:]...), dep)
+				cycles = append(cycles, cycle)
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+	}
+
+	for _, name := range sortedTaskNames(tasks) {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+	return cycles
+}
@@ -0,0 +1,68 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDependencyCyclesReportsEachCycleOnce(t *testing.T) {
+	cycles := dependencyCycles(map[string]TaskDef{
+		"a":    {DependsOn: []string{"b"}},
+		"b":    {DependsOn: []string{"a", "c"}},
+		"c":    {DependsOn: []string{"c"}},
+		"d":    {DependsOn: []string{"a"}},
+		"leaf": {},
+	})
+	got := make([]string, 0, len(cycles))
+	for _, cycle := range cycles {
+		got = append(got, strings.Join(cycle, " -> "))
+	}
+	if strings.Join(got, "; ") != "a -> b -> a; c -> c" {
+		t.Fatalf("unexpected cycles: %v", got)
+	}
+}
+
+func TestValidateTaskConfigRejectsUndefinedDependency(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Tasks: map[string]TaskDef{
+			"ci":   {DependsOn: []string{"build", "lint"}},
+			"lint": {DependsOn: []string{"fmt"}, Origin: "shared/lint.yaml"},
+		},
+	}
+	err := ValidateTaskConfig(cfg)
+	if err == nil || err.Error() != `tasks.ci.depends_on[0] references undefined task "build"` {
+		t.Fatalf("expected undefined dependency error, got %v", err)
+	}
+	delete(cfg.Tasks, "ci")
+	err = ValidateTaskConfig(cfg)
+	if err == nil || err.Error() != `shared/lint.yaml: tasks.lint.depends_on[0] references undefined task "fmt"` {
+		t.Fatalf("expected undefined dependency error with origin, got %v", err)
+	}
+}
+
+func TestValidateTaskConfigRejectsDependencyCycle(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Tasks: map[string]TaskDef{
+			"ci":    {DependsOn: []string{"test"}},
+			"test":  {Run: "go test", DependsOn: []string{"build"}},
+			"build": {Run: "go build", DependsOn: []string{"test"}},
+		},
+	}
+	err := ValidateTaskConfig(cfg)
+	if err == nil || err.Error() != "tasks.build.depends_on dependency cycle: build -> test -> build" {
+		t.Fatalf("expected dependency cycle error, got %v", err)
+	}
+}
+
+func TestValidateTaskGraphIgnoresTasksWithoutRun(t *testing.T) {
+	cfg := &TaskConfig{Tasks: map[string]TaskDef{"docs": {Desc: "documentation only"}}}
+	if err := ValidateTaskGraph(cfg); err != nil {
+		t.Fatalf("ValidateTaskGraph returned error: %v", err)
+	}
+	cfg.Tasks["ci"] = TaskDef{DependsOn: []string{"docs", "ci"}}
+	if err := ValidateTaskGraph(cfg); err == nil || err.Error() != "tasks.ci.depends_on dependency cycle: ci -> ci" {
+		t.Fatalf("expected dependency cycle error, got %v", err)
+	}
+}
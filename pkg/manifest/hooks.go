@@ -0,0 +1,20 @@
+package manifest
+
+import (
+	"fmt"
+	"slices"
+)
+
+// appendHookTasks appends hook task names to dst, skipping duplicates, after
+// checking that each one names a defined task.
+func appendHookTasks(dst, hooks []string, tasks map[string]TaskDef, fieldPath string) ([]string, error) {
+	for index, name := range hooks {
+		if _, ok := tasks[name]; !ok {
+			return nil, fmt.Errorf("%s[%d] references undefined task %q", fieldPath, index, name)
+		}
+		if !slices.Contains(dst, name) {
+			dst = append(dst, name)
+		}
+	}
+	return dst, nil
+}
@@ -0,0 +1,119 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IncludeNamespaceSeparator joins an include namespace and a task name.
+const IncludeNamespaceSeparator = ":"
+
+var includeNamespacePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// IncludeSpec references another manifest whose tasks, vars and
+// repositories are merged into the including one.
+type IncludeSpec struct {
+	Path      string  `yaml:"path"`
+	Digest    Digests `yaml:"digest"`
+	Namespace string  `yaml:"namespace"`
+}
+
+// UnmarshalYAML accepts a bare path or URL as shorthand for {path: ...}.
+func (s *IncludeSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = IncludeSpec{Path: node.Value}
+		return nil
+	}
+	type plain IncludeSpec
+	var decoded plain
+	if err := node.Decode(&decoded); err != nil {
+		return err
+	}
+	*s = IncludeSpec(decoded)
+	return nil
+}
+
+// Normalize validates the include and returns it with a normalized digest.
+func (s IncludeSpec) Normalize() (IncludeSpec, error) {
+	s.Path = strings.TrimSpace(s.Path)
+	if s.Path == "" {
+		return IncludeSpec{}, errors.New("path is required")
+	}
+	s.Namespace = strings.TrimSpace(s.Namespace)
+	if s.Namespace != "" && !includeNamespacePattern.MatchString(s.Namespace) {
+		return IncludeSpec{}, fmt.Errorf("namespace %q must match %s", s.Namespace, includeNamespacePattern.String())
+	}
+	digest, err := normalizeDigests(s.Digest)
+	if err != nil {
+		return IncludeSpec{}, fmt.Errorf("digest %w", err)
+	}
+	s.Digest = Digests(digest)
+	return s, nil
+}
+
+// OriginRepositoryIndex returns the index of repos[index] among the
+// repositories declared in the same file, as recorded in Origin. Included
+// repositories come first in a merged config, so merged and declared
+// indexes differ as soon as includes are used.
+func OriginRepositoryIndex(repos []Repository, index int) int {
+	local := 0
+	for _, repo := range repos[:index] {
+		if repo.Origin == repos[index].Origin {
+			local++
+		}
+	}
+	return local
+}
+
+// FieldPath returns the path of the entry in the manifest that declares it,
+// for example "repositories[0].files[1]".
+func (r FileRule) FieldPath() string {
+	return fmt.Sprintf("repositories[%d].files[%d]", r.OriginRepositoryIndex, r.FileIndex)
+}
+
+// withRepositoryOrigin is withOrigin for an error of repos[index]. The merged
+// repositories[N] path the error starts with is rewritten to the index in the
+// declaring file, so the message points at the entry the user wrote. Root
+// repositories are rewritten too since included ones come before them.
+func withRepositoryOrigin(repos []Repository, index int, err error) error {
+	origin := repos[index].Origin
+	rest, ok := strings.CutPrefix(err.Error(), fmt.Sprintf("repositories[%d]", index))
+	if !ok {
+		return withOrigin(origin, err)
+	}
+	return &originError{
+		message: originPath(origin, fmt.Sprintf("repositories[%d]%s", OriginRepositoryIndex(repos, index), rest)),
+		err:     err,
+	}
+}
+
+// originPath prefixes a field path with the manifest it was included from.
+func originPath(origin, path string) string {
+	if origin == "" {
+		return path
+	}
+	return origin + ": " + path
+}
+
+// originError keeps the wrapped error of a rewritten message reachable by
+// errors.Is and errors.As.
+type originError struct {
+	message string
+	err     error
+}
+
+func (e *originError) Error() string { return e.message }
+
+func (e *originError) Unwrap() error { return e.err }
+
+// withOrigin prefixes err with the manifest an entry was included from.
+func withOrigin(origin string, err error) error {
+	if origin == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", origin, err)
+}
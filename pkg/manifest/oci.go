@@ -0,0 +1,107 @@
+package manifest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultOCIRegistry    = "docker.io"
+	dockerHubOCIRegistry  = "registry-1.docker.io"
+	defaultOCITag         = "latest"
+	ociReferencePrefix    = "oci://"
+	ociInsecureHTTPPrefix = "http://"
+)
+
+// OCIReference is a parsed registry artifact reference such as
+// "ghcr.io/org/tool:1.2.3" or "ghcr.io/org/tool@sha256:<hex>".
+type OCIReference struct {
+	Scheme     string
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseOCIReference parses registry/repository[:tag|@digest]. An optional
+// "oci://" or "https://" prefix is accepted, and "http://" selects plain HTTP
+// for local registries. References without a registry host use Docker Hub.
+func ParseOCIReference(value string) (OCIReference, error) {
+	raw := strings.TrimSpace(value)
+	ref := OCIReference{Scheme: "https"}
+	switch {
+	case strings.HasPrefix(raw, ociInsecureHTTPPrefix):
+		ref.Scheme = "http"
+		raw = strings.TrimPrefix(raw, ociInsecureHTTPPrefix)
+	case strings.HasPrefix(raw, "https://"):
+		raw = strings.TrimPrefix(raw, "https://")
+	default:
+		raw = strings.TrimPrefix(raw, ociReferencePrefix)
+	}
+	if raw == "" {
+		return OCIReference{}, fmt.Errorf("invalid oci reference %q", value)
+	}
+
+	name := raw
+	if before, digest, ok := strings.Cut(raw, "@"); ok {
+		algorithm, encoded, valid := strings.Cut(digest, ":")
+		if !valid || algorithm == "" {
+			return OCIReference{}, fmt.Errorf("invalid oci reference digest %q", digest)
+		}
+		if _, err := hex.DecodeString(encoded); err != nil || encoded == "" {
+			return OCIReference{}, fmt.Errorf("invalid oci reference digest %q", digest)
+		}
+		name = before
+		ref.Digest = digest
+	}
+	if slash := strings.LastIndex(name, "/"); strings.LastIndex(name, ":") > slash {
+		colon := strings.LastIndex(name, ":")
+		ref.Tag = name[colon+1:]
+		name = name[:colon]
+	}
+
+	registry, repository, ok := strings.Cut(name, "/")
+	if !ok || !strings.ContainsAny(registry, ".:") && registry != "localhost" {
+		registry = defaultOCIRegistry
+		repository = name
+	}
+	if registry == defaultOCIRegistry {
+		registry = dockerHubOCIRegistry
+		if !strings.Contains(repository, "/") {
+			repository = "library/" + repository
+		}
+	}
+	if repository == "" || strings.HasPrefix(repository, "/") || strings.HasSuffix(repository, "/") {
+		return OCIReference{}, fmt.Errorf("invalid oci reference %q", value)
+	}
+	if repository != strings.ToLower(repository) {
+		return OCIReference{}, fmt.Errorf("invalid oci reference %q: repository must be lowercase", value)
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = defaultOCITag
+	}
+	ref.Registry = registry
+	ref.Repository = repository
+	return ref, nil
+}
+
+// ManifestReference returns the digest when pinned, otherwise the tag.
+func (r OCIReference) ManifestReference() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// String formats the reference as registry/repository[:tag][@digest].
+func (r OCIReference) String() string {
+	value := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		value += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		value += "@" + r.Digest
+	}
+	return value
+}
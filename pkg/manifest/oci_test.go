@@ -0,0 +1,110 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOCIReference(t *testing.T) {
+	digest := "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	cases := []struct {
+		value string
+		want  OCIReference
+	}{
+		{
+			value: "ghcr.io/org/tool:1.2.3",
+			want:  OCIReference{Scheme: "https", Registry: "ghcr.io", Repository: "org/tool", Tag: "1.2.3"},
+		},
+		{
+			value: "oci://ghcr.io/org/tool@" + digest,
+			want:  OCIReference{Scheme: "https", Registry: "ghcr.io", Repository: "org/tool", Digest: digest},
+		},
+		{
+			value: "http://localhost:5000/tool",
+			want:  OCIReference{Scheme: "http", Registry: "localhost:5000", Repository: "tool", Tag: "latest"},
+		},
+		{
+			value: "alpine:3.20",
+			want:  OCIReference{Scheme: "https", Registry: "registry-1.docker.io", Repository: "library/alpine", Tag: "3.20"},
+		},
+	}
+	for _, tc := range cases {
+		got, err := ParseOCIReference(tc.value)
+		if err != nil {
+			t.Fatalf("ParseOCIReference(%q) returned error: %v", tc.value, err)
+		}
+		if got != tc.want {
+			t.Fatalf("ParseOCIReference(%q): expected %+v, got %+v", tc.value, tc.want, got)
+		}
+	}
+}
+
+func TestParseOCIReferenceRejectsInvalidReferences(t *testing.T) {
+	for _, value := range []string{"", "oci://", "ghcr.io/Org/Tool:1", "ghcr.io/org/tool@sha256:xyz", "ghcr.io/org/tool@:abc"} {
+		if _, err := ParseOCIReference(value); err == nil {
+			t.Fatalf("expected error for %q", value)
+		}
+	}
+}
+
+func TestBuildSyncConfigBuildsOCISource(t *testing.T) {
+	cfg := &TaskConfig{
+		Version: 1,
+		Repositories: []Repository{{
+			Type: "oci",
+			URL:  "ghcr.io/org/tool:1.2.3",
+			Files: []RepositoryFile{
+				{FileName: "tool_linux_*.tar.gz", Encoding: EncodingTarGzip, Extract: "tool", OutDir: "bin"},
+				{MediaType: "text/markdown", Rename: "TOOL.md", OutDir: "."},
+			},
+		}},
+	}
+
+	resolved, err := BuildSyncConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildSyncConfig returned error: %v", err)
+	}
+	first := resolved.Sources[resolved.Files[0].Source]
+	if first.Type != RepositoryTypeOCI || first.OCI == nil || first.OCI.Title != "tool_linux_*.tar.gz" {
+		t.Fatalf("unexpected oci source: %+v", first)
+	}
+	second := resolved.Sources[resolved.Files[1].Source]
+	if second.OCI == nil || second.OCI.MediaType != "text/markdown" || resolved.Files[1].Path != "TOOL.md" {
+		t.Fatalf("unexpected media type selection: %+v %+v", second.OCI, resolved.Files[1])
+	}
+}
+
+func TestBuildSyncConfigValidatesOCIRepository(t *testing.T) {
+	cases := []struct {
+		name    string
+		repo    Repository
+		message string
+	}{
+		{
+			name:    "missing selector",
+			repo:    Repository{Type: "oci", URL: "ghcr.io/org/tool", Files: []RepositoryFile{{Rename: "tool", OutDir: "."}}},
+			message: "requires file_name or media_type",
+		},
+		{
+			name:    "invalid reference",
+			repo:    Repository{Type: "oci", URL: "ghcr.io/Org/Tool", Files: []RepositoryFile{{FileName: "tool", OutDir: "."}}},
+			message: "repositories[0].url",
+		},
+		{
+			name:    "media type on http",
+			repo:    Repository{URL: "https://example.com", Files: []RepositoryFile{{FileName: "tool", MediaType: "text/plain", OutDir: "."}}},
+			message: "media_type requires type",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := BuildSyncConfig(&TaskConfig{Version: 1, Repositories: []Repository{tc.repo}})
+			if err == nil {
+				t.Fatalf("expected error containing %q", tc.message)
+			}
+			if !strings.Contains(err.Error(), tc.message) {
+				t.Fatalf("expected %q in error, got: %v", tc.message, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	PlacementFile  = "file"
+	PlacementBlock = "block"
+	DefaultBlockID = "managed"
+)
+
+// normalizePlacement returns the managed block rule for placement: block,
+// or nil when the whole file is synced.
+func normalizePlacement(file RepositoryFile, repoIndex, fileIndex int) (*BlockRule, error) {
+	fieldPath := fmt.Sprintf("repositories[%d].files[%d]", repoIndex, fileIndex)
+	switch strings.ToLower(strings.TrimSpace(file.Placement)) {
+	case "", PlacementFile:
+		if file.Block != nil {
+			return nil, fmt.Errorf("%s.block requires placement %q", fieldPath, PlacementBlock)
+		}
+		return nil, nil
+	case PlacementBlock:
+	default:
+		return nil, fmt.Errorf("%s.placement must be %q or %q", fieldPath, PlacementFile, PlacementBlock)
+	}
+
+	spec := BlockSpec{}
+	if file.Block != nil {
+		spec = *file.Block
+	}
+	rule, err := normalizeBlock(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%s.block %w", fieldPath, err)
+	}
+	return rule, nil
+}
+
+func normalizeBlock(spec BlockSpec) (*BlockRule, error) {
+	id := strings.TrimSpace(spec.ID)
+	if id == "" {
+		id = DefaultBlockID
+	}
+	rule := &BlockRule{
+		Begin: strings.TrimSpace(spec.Begin),
+		End:   strings.TrimSpace(spec.End),
+	}
+	if rule.Begin == "" {
+		rule.Begin = "# >>> vorbere:" + id
+	}
+	if rule.End == "" {
+		rule.End = "# <<< vorbere:" + id
+	}
+	if strings.ContainsAny(rule.Begin+rule.End, "\r\n") {
+		return nil, errors.New("markers must be single lines")
+	}
+	if rule.Begin == rule.End {
+		return nil, errors.New("begin and end markers must differ")
+	}
+	return rule, nil
+}
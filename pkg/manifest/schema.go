@@ -0,0 +1,85 @@
+package manifest
+
+import (
+	"reflect"
+	"strings"
+)
+
+// JSONSchema returns a JSON Schema (draft 2020-12) for vorbere.yaml, derived
+// from the yaml tags of TaskConfig so that new fields are covered
+// automatically. Enumerated values are not listed, as any string may be a
+// ${{ }} template.
+func JSONSchema() map[string]any {
+	schema := schemaFor(reflect.TypeFor[TaskConfig]())
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "vorbere.yaml"
+	return schema
+}
+
+// schemaOverride describes types whose UnmarshalYAML accepts more than
+// their Go shape.
+func schemaOverride(t reflect.Type) (map[string]any, bool) {
+	switch t {
+	case reflect.TypeFor[Digests]():
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		}}, true
+	case reflect.TypeFor[IncludeSpec]():
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "string"},
+			schemaForStruct(t),
+		}}, true
+	case reflect.TypeFor[VarDef]():
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": []any{"string", "number", "boolean"}},
+			schemaForStruct(t),
+		}}, true
+	}
+	return nil, false
+}
+
+func schemaFor(t reflect.Type) map[string]any {
+	if override, ok := schemaOverride(t); ok {
+		return override
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem())
+	case reflect.Struct:
+		return schemaForStruct(t)
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
+
+func schemaForStruct(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		properties[name] = schemaFor(field.Type)
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
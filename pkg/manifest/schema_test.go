@@ -0,0 +1,57 @@
+package manifest
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONSchemaFollowsYAMLTags(t *testing.T) {
+	body, err := json.Marshal(JSONSchema())
+	if err != nil {
+		t.Fatalf("marshal schema: %v", err)
+	}
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(body, &schema); err != nil {
+		t.Fatalf("unmarshal schema: %v", err)
+	}
+	for _, name := range []string{"version", "includes", "workspaces", "vars", "tasks", "sync", "repositories"} {
+		if _, ok := schema.Properties[name]; !ok {
+			t.Fatalf("expected property %q in schema, got %v", name, schema.Properties)
+		}
+	}
+	if len(schema.Properties) != 7 {
+		t.Fatalf("expected yaml:\"-\" fields to be omitted, got %d properties", len(schema.Properties))
+	}
+
+	var tasks struct {
+		AdditionalProperties struct {
+			Properties           map[string]map[string]any `json:"properties"`
+			AdditionalProperties bool                      `json:"additionalProperties"`
+		} `json:"additionalProperties"`
+	}
+	if err := json.Unmarshal(schema.Properties["tasks"], &tasks); err != nil {
+		t.Fatalf("unmarshal tasks schema: %v", err)
+	}
+	dependsOn := tasks.AdditionalProperties.Properties["depends_on"]
+	if dependsOn["type"] != "array" || tasks.AdditionalProperties.AdditionalProperties {
+		t.Fatalf("unexpected task schema: %+v", tasks.AdditionalProperties)
+	}
+	if _, ok := tasks.AdditionalProperties.Properties["origin"]; ok {
+		t.Fatalf("expected origin to be omitted from task schema")
+	}
+}
+
+func TestJSONSchemaAcceptsShorthandForms(t *testing.T) {
+	schema := JSONSchema()
+	properties := schema["properties"].(map[string]any)
+	vars := properties["vars"].(map[string]any)["additionalProperties"].(map[string]any)
+	if _, ok := vars["oneOf"]; !ok {
+		t.Fatalf("expected vars to accept scalar and mapping forms, got %v", vars)
+	}
+	includes := properties["includes"].(map[string]any)["items"].(map[string]any)
+	if _, ok := includes["oneOf"]; !ok {
+		t.Fatalf("expected includes to accept scalar and mapping forms, got %v", includes)
+	}
+}
@@ -0,0 +1,53 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	SignatureTypeMinisign = "minisign"
+	SignatureTypeEd25519  = "ed25519"
+	SignatureTypeCosign   = "cosign"
+	SignatureTypeOpenPGP  = "openpgp"
+)
+
+// signatureFileExtensions are appended to the source name when signature.file
+// is omitted, following the naming used by each signing tool.
+var signatureFileExtensions = map[string]string{
+	SignatureTypeMinisign: ".minisig",
+	SignatureTypeEd25519:  ".sig",
+	SignatureTypeCosign:   ".sig",
+	SignatureTypeOpenPGP:  ".asc",
+}
+
+// normalizeSignature validates a signature spec and returns the normalized
+// spec with file defaulted from sourceName.
+func normalizeSignature(spec SignatureSpec, sourceName string) (SignatureSpec, error) {
+	spec.Type = strings.TrimSpace(strings.ToLower(spec.Type))
+	extension, ok := signatureFileExtensions[spec.Type]
+	if !ok {
+		return SignatureSpec{}, fmt.Errorf(
+			"type must be one of %q, %q, %q, %q",
+			SignatureTypeMinisign, SignatureTypeEd25519, SignatureTypeCosign, SignatureTypeOpenPGP,
+		)
+	}
+
+	spec.Key = strings.TrimSpace(spec.Key)
+	spec.KeyFile = strings.TrimSpace(os.ExpandEnv(spec.KeyFile))
+	if (spec.Key == "") == (spec.KeyFile == "") {
+		return SignatureSpec{}, errors.New("requires exactly one of key or key_file")
+	}
+
+	spec.File = strings.TrimSpace(spec.File)
+	if spec.File == "" {
+		name := strings.TrimSpace(sourceName)
+		if name == "" || strings.HasSuffix(name, "/") {
+			return SignatureSpec{}, errors.New("file is required when it cannot be derived from the source name")
+		}
+		spec.File = name + extension
+	}
+	return spec, nil
+}
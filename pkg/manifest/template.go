@@ -0,0 +1,411 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+const (
+	templateLeftDelim  = "${{"
+	templateRightDelim = "}}"
+)
+
+// ExpandOptions customizes ExpandTaskConfigTemplatesWithOptions.
+type ExpandOptions struct {
+	// Dir is the base directory of relative paths given to sha256file and
+	// exists. Empty means the current directory.
+	Dir string
+	// Args are exposed as .args, typically the arguments after "--" of
+	// vorbere run.
+	Args []string
+	// Remote hides the process environment from .env, as the config was
+	// loaded from a URL. Entries included from a URL are always treated so.
+	Remote bool
+	// DeferTasks leaves the run, env and cwd of tasks unexpanded; ExpandTask
+	// expands them for the task about to run. A failing template, such as
+	// one indexing .args, then only affects its own task.
+	DeferTasks bool
+}
+
+// deferredTaskFields are the task fields left to ExpandTask by
+// ExpandOptions.DeferTasks, and loadTaskFields the ones expanded at load.
+var (
+	deferredTaskFields = []string{"run", "env", "cwd"}
+	loadTaskFields     = []string{"desc", "depends_on"}
+)
+
+// TemplateFuncs returns the function library available in ${{ }}
+// expressions and rendered files. Relative paths resolve against dir.
+func TemplateFuncs(dir string) template.FuncMap {
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) || dir == "" {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	return template.FuncMap{
+		"default": func(fallback, value any) any {
+			if isEmptyTemplateValue(value) {
+				return fallback
+			}
+			return value
+		},
+		"upper":      strings.ToUpper,
+		"trimPrefix": func(prefix, value string) string { return strings.TrimPrefix(value, prefix) },
+		"semverMajor": func(version string) (string, error) {
+			major, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".")
+			if _, err := strconv.ParseUint(major, 10, 64); err != nil {
+				return "", fmt.Errorf("%q is not a semantic version", version)
+			}
+			return major, nil
+		},
+		"osArch": func() string { return runtime.GOOS + "-" + runtime.GOARCH },
+		"sha256file": func(path string) (string, error) {
+			content, err := os.ReadFile(resolve(path))
+			if err != nil {
+				return "", err
+			}
+			sum := sha256.Sum256(content)
+			return hex.EncodeToString(sum[:]), nil
+		},
+		"exists": func(path string) bool {
+			_, err := os.Stat(resolve(path))
+			return err == nil
+		},
+	}
+}
+
+func isEmptyTemplateValue(value any) bool {
+	if value == nil {
+		return true
+	}
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return reflected.Len() == 0
+	case reflect.Bool:
+		return !reflected.Bool()
+	case reflect.Pointer, reflect.Interface:
+		return reflected.IsNil()
+	default:
+		return reflected.IsZero()
+	}
+}
+
+// templateContext evaluates ${{ }} expressions of one config.
+type templateContext struct {
+	vars  *varScope
+	funcs template.FuncMap
+	env   map[string]string
+	args  []string
+	// origin is the include the expanded entry was merged from.
+	origin string
+	// issues collects expansion errors when set. The failing field keeps
+	// its value and expansion continues.
+	issues *[]ConfigIssue
+	// checkOnly parses templates and checks their vars without executing
+	// them, for fields whose .args are not known yet.
+	checkOnly bool
+	opts      ExpandOptions
+}
+
+// forOrigin returns the context for an entry included from origin. The
+// environment is hidden from remote entries.
+func (c *templateContext) forOrigin(origin string) *templateContext {
+	scoped := *c
+	scoped.origin = origin
+	if IsRemoteConfigLocation(origin) && len(c.env) > 0 {
+		scoped.env = map[string]string{}
+	}
+	return &scoped
+}
+
+func newTemplateContext(cfg *TaskConfig, opts ExpandOptions) *templateContext {
+	args := opts.Args
+	if args == nil {
+		args = []string{}
+	}
+	env := map[string]string{}
+	if !opts.Remote {
+		env = EnvironMap()
+	}
+	return &templateContext{
+		vars:  &varScope{cfg: cfg},
+		funcs: TemplateFuncs(opts.Dir),
+		env:   env,
+		args:  args,
+		opts:  opts,
+	}
+}
+
+// expand evaluates value as a text/template with ${{ }} delimiters. task is
+// the task name exposed as .task.name, or "" outside of tasks. Undefined
+// .vars references are reported with fieldPath.
+func (c *templateContext) expand(value, fieldPath, task string) (string, error) {
+	if !strings.Contains(value, templateLeftDelim) {
+		return value, nil
+	}
+	if err := checkVarKeys(value, fieldPath); err != nil {
+		return "", err
+	}
+	tmpl, err := template.New(fieldPath).
+		Delims(templateLeftDelim, templateRightDelim).
+		Option("missingkey=error").
+		Funcs(c.funcs).
+		Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("%s invalid template: %w", fieldPath, err)
+	}
+
+	refs := collectTemplateRefs(tmpl)
+	if refs.allVars {
+		if _, err := c.vars.all(); err != nil {
+			return "", err
+		}
+	}
+	missing := map[string]struct{}{}
+	for name := range refs.vars {
+		if _, ok, err := c.vars.lookup(name); err != nil {
+			return "", err
+		} else if !ok {
+			missing[name] = struct{}{}
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%s references undefined var(s): %s", fieldPath, strings.Join(sortedSetKeys(missing), ", "))
+	}
+	if c.checkOnly {
+		return value, nil
+	}
+
+	data := map[string]any{
+		"vars": c.vars.cfg.Vars,
+		"env":  c.env,
+		"os":   runtime.GOOS,
+		"arch": runtime.GOARCH,
+		"args": c.args,
+	}
+	if task != "" {
+		data["task"] = map[string]string{"name": task}
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// expandFields expands every string reachable from the struct value, which
+// must be addressable. Fields tagged yaml:"-" or expand:"-", unexported
+// fields and the yaml names in skip are left untouched. Paths of nested
+// values follow the yaml names, [i] for list items and .key for map values.
+func (c *templateContext) expandFields(value reflect.Value, fieldPath, task string, skip ...string) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if !field.IsExported() || name == "-" || field.Tag.Get("expand") == "-" || slices.Contains(skip, name) {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if fieldPath != "" {
+			name = fieldPath + "." + name
+		}
+		if err := c.expandValue(value.Field(i), name, task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *templateContext) expandValue(value reflect.Value, fieldPath, task string) error {
+	switch value.Kind() {
+	case reflect.String:
+		expanded, err := c.expand(value.String(), fieldPath, task)
+		if err != nil && c.issues != nil {
+			*c.issues = append(*c.issues, ConfigIssue{
+				Path:    fieldPath,
+				Origin:  c.origin,
+				Message: strings.TrimPrefix(err.Error(), fieldPath+" "),
+			})
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		value.SetString(expanded)
+	case reflect.Pointer:
+		if !value.IsNil() {
+			return c.expandValue(value.Elem(), fieldPath, task)
+		}
+	case reflect.Struct:
+		return c.expandFields(value, fieldPath, task)
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			if err := c.expandValue(value.Index(i), fmt.Sprintf("%s[%d]", fieldPath, i), task); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, key := range keys {
+			// Map entries are not addressable; expand a copy and store it back.
+			entry := reflect.New(value.Type().Elem()).Elem()
+			entry.Set(value.MapIndex(key))
+			if err := c.expandValue(entry, fmt.Sprintf("%s.%v", fieldPath, key), task); err != nil {
+				return err
+			}
+			value.SetMapIndex(key, entry)
+		}
+	}
+	return nil
+}
+
+// usesArgs reports whether value references .args.
+func usesArgs(value string) bool {
+	if !strings.Contains(value, templateLeftDelim) {
+		return false
+	}
+	tmpl, err := template.New("").Delims(templateLeftDelim, templateRightDelim).Funcs(TemplateFuncs("")).Parse(value)
+	if err != nil {
+		return false
+	}
+	return collectTemplateRefs(tmpl).args
+}
+
+// TemplateVars reports whether value is a ${{ }} template and returns the
+// sorted names of the vars it references.
+func TemplateVars(value string) ([]string, bool) {
+	if !strings.Contains(value, templateLeftDelim) {
+		return nil, false
+	}
+	tmpl, err := template.New("").Delims(templateLeftDelim, templateRightDelim).Funcs(TemplateFuncs("")).Parse(value)
+	if err != nil {
+		return nil, true
+	}
+	return sortedSetKeys(collectTemplateRefs(tmpl).vars), true
+}
+
+// checkVarKeys keeps the explicit error for references such as
+// ${{ .vars.TOOL-VERSION }}, which would otherwise parse as a subtraction.
+func checkVarKeys(value, fieldPath string) error {
+	invalidKeys := map[string]struct{}{}
+	for _, ref := range varsReferencePattern.FindAllStringSubmatch(value, -1) {
+		if len(ref) < 2 || varsKeyPattern.MatchString(ref[1]) {
+			continue
+		}
+		invalidKeys[ref[1]] = struct{}{}
+	}
+	if len(invalidKeys) == 0 {
+		return nil
+	}
+	return fmt.Errorf(
+		"%s references invalid var key(s): %s (allowed pattern: [A-Za-z_][A-Za-z0-9_]*)",
+		fieldPath,
+		strings.Join(sortedSetKeys(invalidKeys), ", "),
+	)
+}
+
+// templateRefs collects .vars and .args references of a parse tree.
+// allVars is set when .vars is used as a whole, for example with index.
+type templateRefs struct {
+	vars    map[string]struct{}
+	allVars bool
+	args    bool
+}
+
+func collectTemplateRefs(tmpl *template.Template) templateRefs {
+	refs := templateRefs{vars: map[string]struct{}{}}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			refs.walk(t.Tree.Root)
+		}
+	}
+	return refs
+}
+
+func (r *templateRefs) walk(node parse.Node) {
+	switch n := node.(type) {
+	case nil:
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			r.walk(child)
+		}
+	case *parse.ActionNode:
+		r.walk(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			r.walk(cmd)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			r.walk(arg)
+		}
+	case *parse.FieldNode:
+		r.field(n.Ident)
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			r.field(n.Ident[1:])
+		}
+	case *parse.ChainNode:
+		r.walk(n.Node)
+	case *parse.IfNode:
+		r.walkBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		r.walkBranch(&n.BranchNode)
+	case *parse.WithNode:
+		r.walkBranch(&n.BranchNode)
+	case *parse.TemplateNode:
+		r.walk(n.Pipe)
+	}
+}
+
+func (r *templateRefs) walkBranch(n *parse.BranchNode) {
+	r.walk(n.Pipe)
+	r.walk(n.List)
+	r.walk(n.ElseList)
+}
+
+func (r *templateRefs) field(ident []string) {
+	switch {
+	case len(ident) >= 2 && ident[0] == "vars":
+		r.vars[ident[1]] = struct{}{}
+	case len(ident) == 1 && ident[0] == "vars":
+		r.allVars = true
+	case len(ident) >= 1 && ident[0] == "args":
+		r.args = true
+	}
+}
+
+// EnvironMap returns the process environment as the .env template data.
+func EnvironMap() map[string]string {
+	env := map[string]string{}
+	for _, entry := range os.Environ() {
+		key, value, ok := strings.Cut(entry, "=")
+		if ok {
+			env[key] = value
+		}
+	}
+	return env
+}
@@ -0,0 +1,210 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestExpandTaskConfigTemplatesEvaluatesGoTemplates(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "VERSION"), []byte("1.0.0\n"), 0o644); err != nil {
+		t.Fatalf("write VERSION: %v", err)
+	}
+	t.Setenv("TEMPLATE_TEST_USER", "dev")
+	cfg := &TaskConfig{
+		Vars: map[string]string{"VERSION": "v2.3.4", "EMPTY": ""},
+		Tasks: map[string]TaskDef{
+			"build": {
+				Run: `echo ${{ .task.name }} ${{ semverMajor .vars.VERSION }} ${{ .vars.VERSION | trimPrefix "v" | upper }}`,
+				Env: map[string]string{
+					"CHANNEL":  `${{ .vars.EMPTY | default "stable" }}`,
+					"USER":     `${{ .env.TEMPLATE_TEST_USER }}`,
+					"OPTIONAL": `${{ index .env "TEMPLATE_TEST_UNSET" | default "none" }}`,
+					"TARGET":   `${{ if eq .os "windows" }}win${{ else }}${{ osArch }}${{ end }}`,
+					"DIGEST":   `${{ sha256file "VERSION" }}`,
+					"FLAGS":    `${{ if exists "VERSION" }}versioned${{ end }}${{ if exists "missing" }}missing${{ end }}`,
+				},
+			},
+			"test": {Run: `go test ${{ range .args }}-run ${{ . }} ${{ end }}./...`},
+			"lint": {Run: `echo '{{ keep }}' ${{ .arch }}`},
+		},
+	}
+	if err := ExpandTaskConfigTemplatesWithOptions(cfg, ExpandOptions{Dir: dir, Args: []string{"TestA"}}); err != nil {
+		t.Fatalf("ExpandTaskConfigTemplatesWithOptions returned error: %v", err)
+	}
+	build := cfg.Tasks["build"]
+	if build.Run != "echo build 2 2.3.4" {
+		t.Fatalf("unexpected run: %q", build.Run)
+	}
+	target := runtime.GOOS + "-" + runtime.GOARCH
+	if runtime.GOOS == "windows" {
+		target = "win"
+	}
+	want := map[string]string{
+		"CHANNEL":  "stable",
+		"USER":     "dev",
+		"OPTIONAL": "none",
+		"TARGET":   target,
+		"DIGEST":   "59854984853104df5c353e2f681a15fc7924742f9a2e468c29af248dce45ce03",
+		"FLAGS":    "versioned",
+	}
+	for key, value := range want {
+		if build.Env[key] != value {
+			t.Fatalf("expected env %s=%q, got %q", key, value, build.Env[key])
+		}
+	}
+	if build.RunUsesArgs {
+		t.Fatalf("expected build not to use args")
+	}
+	test := cfg.Tasks["test"]
+	if test.Run != "go test -run TestA ./..." || !test.RunUsesArgs {
+		t.Fatalf("unexpected args expansion: %q uses=%v", test.Run, test.RunUsesArgs)
+	}
+	if got := cfg.Tasks["lint"].Run; got != "echo '{{ keep }}' "+runtime.GOARCH {
+		t.Fatalf("expected plain braces to stay literal, got %q", got)
+	}
+}
+
+func TestExpandTaskConfigTemplatesReportsFieldPath(t *testing.T) {
+	cases := []struct {
+		run  string
+		want []string
+	}{
+		{run: `${{ if .vars.MISSING }}x${{ end }}`, want: []string{"tasks.t.run references undefined var(s): MISSING"}},
+		{run: `${{ .env.TEMPLATE_TEST_UNSET }}`, want: []string{"tasks.t.run", `TEMPLATE_TEST_UNSET`}},
+		{run: `${{ semverMajor "main" }}`, want: []string{"tasks.t.run", `"main" is not a semantic version`}},
+		{run: `${{ if }}`, want: []string{"tasks.t.run invalid template"}},
+	}
+	for _, tc := range cases {
+		cfg := &TaskConfig{Vars: map[string]string{}, Tasks: map[string]TaskDef{"t": {Run: tc.run}}}
+		err := ExpandTaskConfigTemplates(cfg)
+		if err == nil {
+			t.Fatalf("expected error for %q", tc.run)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Fatalf("expected %q in error for %q, got %v", want, tc.run, err)
+			}
+		}
+	}
+
+	cfg := &TaskConfig{Repositories: []Repository{{URL: "${{ .task.name }}"}}}
+	if err := ExpandTaskConfigTemplates(cfg); err == nil || !strings.Contains(err.Error(), "repositories[0].url") {
+		t.Fatalf("expected .task to be undefined outside tasks, got %v", err)
+	}
+}
+
+func TestExpandTaskConfigTemplatesHidesEnvFromRemoteEntries(t *testing.T) {
+	t.Setenv("TEMPLATE_TEST_SECRET", "secret")
+	cfg := &TaskConfig{Tasks: map[string]TaskDef{
+		"local":  {Run: `${{ index .env "TEMPLATE_TEST_SECRET" }}`},
+		"remote": {Run: `x${{ index .env "TEMPLATE_TEST_SECRET" }}`, Origin: "https://example.com/tasks.yaml"},
+	}}
+	if err := ExpandTaskConfigTemplates(cfg); err != nil {
+		t.Fatalf("ExpandTaskConfigTemplates returned error: %v", err)
+	}
+	if cfg.Tasks["local"].Run != "secret" || cfg.Tasks["remote"].Run != "x" {
+		t.Fatalf("expected env to be hidden from remote entries, got %q %q", cfg.Tasks["local"].Run, cfg.Tasks["remote"].Run)
+	}
+
+	cfg = &TaskConfig{Tasks: map[string]TaskDef{"t": {Run: `${{ .env.TEMPLATE_TEST_SECRET }}`}}}
+	if err := ExpandTaskConfigTemplatesWithOptions(cfg, ExpandOptions{Remote: true}); err == nil {
+		t.Fatalf("expected .env to be empty for a remote config")
+	}
+}
+
+func TestExpandTaskConfigTemplatesCoversEveryStringField(t *testing.T) {
+	const value = "${{ .vars.V }}"
+	cases := []struct {
+		path string
+		set  func(*TaskConfig)
+		get  func(*TaskConfig) string
+	}{
+		{"workspaces[0]", func(c *TaskConfig) { c.Workspaces = []string{value} }, func(c *TaskConfig) string { return c.Workspaces[0] }},
+		{"sync.after[0]", func(c *TaskConfig) { c.Sync = &SyncSettings{After: []string{value}} }, func(c *TaskConfig) string { return c.Sync.After[0] }},
+		{"tasks.t.run", func(c *TaskConfig) { c.Tasks["t"] = TaskDef{Run: value} }, func(c *TaskConfig) string { return c.Tasks["t"].Run }},
+		{"tasks.t.desc", func(c *TaskConfig) { c.Tasks["t"] = TaskDef{Desc: value} }, func(c *TaskConfig) string { return c.Tasks["t"].Desc }},
+		{"tasks.t.env.K", func(c *TaskConfig) { c.Tasks["t"] = TaskDef{Env: map[string]string{"K": value}} }, func(c *TaskConfig) string { return c.Tasks["t"].Env["K"] }},
+		{"tasks.t.cwd", func(c *TaskConfig) { c.Tasks["t"] = TaskDef{CWD: value} }, func(c *TaskConfig) string { return c.Tasks["t"].CWD }},
+		{"tasks.t.depends_on[0]", func(c *TaskConfig) { c.Tasks["t"] = TaskDef{DependsOn: []string{value}} }, func(c *TaskConfig) string { return c.Tasks["t"].DependsOn[0] }},
+		{"repositories[0]._comment", func(c *TaskConfig) { c.Repositories[0].Comment = value }, func(c *TaskConfig) string { return c.Repositories[0].Comment }},
+		{"repositories[0].tags[0]", func(c *TaskConfig) { c.Repositories[0].Tags = []string{value} }, func(c *TaskConfig) string { return c.Repositories[0].Tags[0] }},
+		{"repositories[0].type", func(c *TaskConfig) { c.Repositories[0].Type = value }, func(c *TaskConfig) string { return c.Repositories[0].Type }},
+		{"repositories[0].url", func(c *TaskConfig) { c.Repositories[0].URL = value }, func(c *TaskConfig) string { return c.Repositories[0].URL }},
+		{"repositories[0].repo", func(c *TaskConfig) { c.Repositories[0].Repo = value }, func(c *TaskConfig) string { return c.Repositories[0].Repo }},
+		{"repositories[0].tag", func(c *TaskConfig) { c.Repositories[0].Tag = value }, func(c *TaskConfig) string { return c.Repositories[0].Tag }},
+		{"repositories[0].ref", func(c *TaskConfig) { c.Repositories[0].Ref = value }, func(c *TaskConfig) string { return c.Repositories[0].Ref }},
+		{"repositories[0].checksums", func(c *TaskConfig) { c.Repositories[0].Checksums = value }, func(c *TaskConfig) string { return c.Repositories[0].Checksums }},
+		{"repositories[0].headers.Authorization", func(c *TaskConfig) { c.Repositories[0].Headers = map[string]string{"Authorization": value} }, func(c *TaskConfig) string { return c.Repositories[0].Headers["Authorization"] }},
+		{"repositories[0].allow_header_forward_to[0]", func(c *TaskConfig) { c.Repositories[0].AllowHeaderForwardTo = []string{value} }, func(c *TaskConfig) string { return c.Repositories[0].AllowHeaderForwardTo[0] }},
+		{"repositories[0].on_change[0]", func(c *TaskConfig) { c.Repositories[0].OnChange = []string{value} }, func(c *TaskConfig) string { return c.Repositories[0].OnChange[0] }},
+		{"repositories[0].files[0].file_name", func(c *TaskConfig) { c.Repositories[0].Files[0].FileName = value }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].FileName }},
+		{"repositories[0].files[0].asset", func(c *TaskConfig) { c.Repositories[0].Files[0].Asset = value }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Asset }},
+		{"repositories[0].files[0].media_type", func(c *TaskConfig) { c.Repositories[0].Files[0].MediaType = value }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].MediaType }},
+		{"repositories[0].files[0].tags[0]", func(c *TaskConfig) { c.Repositories[0].Files[0].Tags = []string{value} }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Tags[0] }},
+		{"repositories[0].files[0].download_digest", func(c *TaskConfig) { c.Repositories[0].Files[0].DownloadDigest = value }, func(c *TaskConfig) string { return string(c.Repositories[0].Files[0].DownloadDigest) }},
+		{"repositories[0].files[0].download_digest_from", func(c *TaskConfig) { c.Repositories[0].Files[0].DownloadDigestFrom = value }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].DownloadDigestFrom }},
+		{"repositories[0].files[0].output_digest", func(c *TaskConfig) { c.Repositories[0].Files[0].OutputDigest = value }, func(c *TaskConfig) string { return string(c.Repositories[0].Files[0].OutputDigest) }},
+		{"repositories[0].files[0].encoding", func(c *TaskConfig) { c.Repositories[0].Files[0].Encoding = value }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Encoding }},
+		{"repositories[0].files[0].extract", func(c *TaskConfig) { c.Repositories[0].Files[0].Extract = value }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Extract }},
+		{"repositories[0].files[0].out_dir", func(c *TaskConfig) { c.Repositories[0].Files[0].OutDir = value }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].OutDir }},
+		{"repositories[0].files[0].rename", func(c *TaskConfig) { c.Repositories[0].Files[0].Rename = value }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Rename }},
+		{"repositories[0].files[0].mode", func(c *TaskConfig) { c.Repositories[0].Files[0].Mode = value }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Mode }},
+		{"repositories[0].files[0].signature.type", func(c *TaskConfig) { c.Repositories[0].Files[0].Signature = &SignatureSpec{Type: value} }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Signature.Type }},
+		{"repositories[0].files[0].signature.file", func(c *TaskConfig) { c.Repositories[0].Files[0].Signature = &SignatureSpec{File: value} }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Signature.File }},
+		{"repositories[0].files[0].signature.key", func(c *TaskConfig) { c.Repositories[0].Files[0].Signature = &SignatureSpec{Key: value} }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Signature.Key }},
+		{"repositories[0].files[0].signature.key_file", func(c *TaskConfig) { c.Repositories[0].Files[0].Signature = &SignatureSpec{KeyFile: value} }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Signature.KeyFile }},
+		{"repositories[0].files[0].template", func(c *TaskConfig) { c.Repositories[0].Files[0].Template = value }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Template }},
+		{"repositories[0].files[0].merge", func(c *TaskConfig) { c.Repositories[0].Files[0].Merge = value }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Merge }},
+		{"repositories[0].files[0].merge_arrays", func(c *TaskConfig) { c.Repositories[0].Files[0].MergeArrays = value }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].MergeArrays }},
+		{"repositories[0].files[0].placement", func(c *TaskConfig) { c.Repositories[0].Files[0].Placement = value }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Placement }},
+		{"repositories[0].files[0].block.id", func(c *TaskConfig) { c.Repositories[0].Files[0].Block = &BlockSpec{ID: value} }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Block.ID }},
+		{"repositories[0].files[0].block.begin", func(c *TaskConfig) { c.Repositories[0].Files[0].Block = &BlockSpec{Begin: value} }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Block.Begin }},
+		{"repositories[0].files[0].block.end", func(c *TaskConfig) { c.Repositories[0].Files[0].Block = &BlockSpec{End: value} }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Block.End }},
+		{"repositories[0].files[0].on_change[0]", func(c *TaskConfig) { c.Repositories[0].Files[0].OnChange = []string{value} }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].OnChange[0] }},
+		{"repositories[0].files[0].symlink.link", func(c *TaskConfig) { c.Repositories[0].Files[0].Symlink = &SymlinkSpec{Link: value} }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Symlink.Link }},
+		{"repositories[0].files[0].symlink.target", func(c *TaskConfig) { c.Repositories[0].Files[0].Symlink = &SymlinkSpec{Target: value} }, func(c *TaskConfig) string { return c.Repositories[0].Files[0].Symlink.Target }},
+	}
+	newConfig := func(vars map[string]string) *TaskConfig {
+		return &TaskConfig{
+			Vars:         vars,
+			Tasks:        map[string]TaskDef{},
+			Repositories: []Repository{{Files: []RepositoryFile{{}}}},
+		}
+	}
+	for _, tc := range cases {
+		cfg := newConfig(map[string]string{"V": "expanded"})
+		tc.set(cfg)
+		if err := ExpandTaskConfigTemplates(cfg); err != nil {
+			t.Fatalf("%s: ExpandTaskConfigTemplates returned error: %v", tc.path, err)
+		}
+		if got := tc.get(cfg); got != "expanded" {
+			t.Fatalf("%s: expected expanded value, got %q", tc.path, got)
+		}
+
+		cfg = newConfig(map[string]string{})
+		tc.set(cfg)
+		err := ExpandTaskConfigTemplates(cfg)
+		if err == nil || err.Error() != tc.path+" references undefined var(s): V" {
+			t.Fatalf("%s: expected undefined var error with field path, got %v", tc.path, err)
+		}
+	}
+}
+
+func TestExpandTaskConfigTemplatesSkipsVarDefsAndIncludes(t *testing.T) {
+	raw := "${{ .vars.V }}"
+	cfg := &TaskConfig{
+		Includes: []IncludeSpec{{Path: raw}},
+		VarDefs:  map[string]VarDef{"V": StringVar(raw)},
+		Vars:     map[string]string{"V": raw},
+	}
+	if err := ExpandTaskConfigTemplates(cfg); err != nil {
+		t.Fatalf("ExpandTaskConfigTemplates returned error: %v", err)
+	}
+	if cfg.Includes[0].Path != raw || *cfg.VarDefs["V"].Value != raw || cfg.Vars["V"] != raw {
+		t.Fatalf("expected includes and vars to be left untouched, got %+v", cfg)
+	}
+}
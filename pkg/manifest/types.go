@@ -3,39 +3,91 @@ package manifest
 // TaskConfig is the repository-level task configuration in vorbere.yaml.
 type TaskConfig struct {
 	Version      int                `yaml:"version"`
-	Vars         map[string]string  `yaml:"vars"`
+	Includes     []IncludeSpec      `yaml:"includes" expand:"-"`
+	Workspaces   []string           `yaml:"workspaces"`
+	VarDefs      map[string]VarDef  `yaml:"vars" expand:"-"`
+	Vars         map[string]string  `yaml:"-"`
 	Tasks        map[string]TaskDef `yaml:"tasks"`
+	Sync         *SyncSettings      `yaml:"sync"`
 	Repositories []Repository       `yaml:"repositories"`
+
+	shellVars map[string]shellVar
+	// deferredExpand holds the options of ExpandTask when task fields were
+	// deferred at load.
+	deferredExpand *ExpandOptions
+}
+
+// SyncSettings holds top-level sync options.
+type SyncSettings struct {
+	After []string `yaml:"after"`
 }
 
 // TaskDef defines one runnable task.
 type TaskDef struct {
-	Run       string            `yaml:"run"`
-	Desc      string            `yaml:"desc"`
-	Env       map[string]string `yaml:"env"`
-	CWD       string            `yaml:"cwd"`
-	DependsOn []string          `yaml:"depends_on"`
+	Run         string            `yaml:"run"`
+	Desc        string            `yaml:"desc"`
+	Env         map[string]string `yaml:"env"`
+	CWD         string            `yaml:"cwd"`
+	DependsOn   []string          `yaml:"depends_on"`
+	Origin      string            `yaml:"-"`
+	RunUsesArgs bool              `yaml:"-"`
 }
 
 // Repository groups downloadable file entries under one base URL.
 type Repository struct {
-	Comment string            `yaml:"_comment"`
-	URL     string            `yaml:"url"`
-	Headers map[string]string `yaml:"headers"`
-	Files   []RepositoryFile  `yaml:"files"`
+	Comment              string            `yaml:"_comment"`
+	Tags                 []string          `yaml:"tags"`
+	Type                 string            `yaml:"type"`
+	URL                  string            `yaml:"url"`
+	Repo                 string            `yaml:"repo"`
+	Tag                  string            `yaml:"tag"`
+	Ref                  string            `yaml:"ref"`
+	Checksums            string            `yaml:"checksums"`
+	Headers              map[string]string `yaml:"headers"`
+	AllowHeaderForwardTo []string          `yaml:"allow_header_forward_to"`
+	OnChange             []string          `yaml:"on_change"`
+	Files                []RepositoryFile  `yaml:"files"`
+	Origin               string            `yaml:"-"`
 }
 
 // RepositoryFile defines one fetch-and-place operation.
 type RepositoryFile struct {
-	FileName       string       `yaml:"file_name"`
-	DownloadDigest string       `yaml:"download_digest"`
-	OutputDigest   string       `yaml:"output_digest"`
-	Encoding       string       `yaml:"encoding"`
-	Extract        string       `yaml:"extract"`
-	OutDir         string       `yaml:"out_dir"`
-	Rename         string       `yaml:"rename"`
-	Mode           string       `yaml:"mode"`
-	Symlink        *SymlinkSpec `yaml:"symlink"`
+	FileName           string         `yaml:"file_name"`
+	Asset              string         `yaml:"asset"`
+	MediaType          string         `yaml:"media_type"`
+	Tags               []string       `yaml:"tags"`
+	DownloadDigest     Digests        `yaml:"download_digest"`
+	DownloadDigestFrom string         `yaml:"download_digest_from"`
+	OutputDigest       Digests        `yaml:"output_digest"`
+	Encoding           string         `yaml:"encoding"`
+	Extract            string         `yaml:"extract"`
+	OutDir             string         `yaml:"out_dir"`
+	Rename             string         `yaml:"rename"`
+	Mode               string         `yaml:"mode"`
+	Signature          *SignatureSpec `yaml:"signature"`
+	Render             bool           `yaml:"render"`
+	Template           string         `yaml:"template"`
+	Merge              string         `yaml:"merge"`
+	MergeArrays        string         `yaml:"merge_arrays"`
+	Placement          string         `yaml:"placement"`
+	Block              *BlockSpec     `yaml:"block"`
+	OnChange           []string       `yaml:"on_change"`
+	Symlink            *SymlinkSpec   `yaml:"symlink"`
+}
+
+// SignatureSpec configures detached signature verification of a downloaded file.
+type SignatureSpec struct {
+	Type    string `yaml:"type"`
+	File    string `yaml:"file"`
+	Key     string `yaml:"key"`
+	KeyFile string `yaml:"key_file"`
+}
+
+// BlockSpec configures the markers of a managed block.
+type BlockSpec struct {
+	ID    string `yaml:"id"`
+	Begin string `yaml:"begin"`
+	End   string `yaml:"end"`
 }
 
 // SymlinkSpec is kept for schema compatibility; currently unsupported.
@@ -46,25 +98,84 @@ type SymlinkSpec struct {
 
 // SyncConfig is the normalized internal sync manifest.
 type SyncConfig struct {
-	Version string            `yaml:"version"`
-	Sources map[string]Source `yaml:"sources"`
-	Files   []FileRule        `yaml:"files"`
+	Version  string            `yaml:"version"`
+	Sources  map[string]Source `yaml:"sources"`
+	Files    []FileRule        `yaml:"files"`
+	After    []string          `yaml:"after,omitempty"`
+	Warnings []string          `yaml:"-"`
 }
 
 // Source defines downloadable resource metadata.
 type Source struct {
-	URL     string            `yaml:"url"`
-	Headers map[string]string `yaml:"headers"`
+	Type                 string               `yaml:"type"`
+	URL                  string               `yaml:"url"`
+	Headers              map[string]string    `yaml:"headers"`
+	AllowHeaderForwardTo []string             `yaml:"allow_header_forward_to"`
+	GitHubRelease        *GitHubReleaseSource `yaml:"github_release"`
+	Git                  *GitSource           `yaml:"git"`
+	OCI                  *OCISource           `yaml:"oci"`
+}
+
+// GitHubReleaseSource identifies one release asset resolved through the GitHub REST API.
+type GitHubReleaseSource struct {
+	Repo      string `yaml:"repo"`
+	Tag       string `yaml:"tag"`
+	Asset     string `yaml:"asset"`
+	Checksums string `yaml:"checksums"`
+}
+
+// GitSource identifies a path or glob inside a git repository at one ref.
+type GitSource struct {
+	Ref  string `yaml:"ref"`
+	Path string `yaml:"path"`
+}
+
+// OCISource selects one layer of an OCI artifact by title annotation and/or media type.
+type OCISource struct {
+	Title     string `yaml:"title"`
+	MediaType string `yaml:"media_type"`
 }
 
 // FileRule defines one target placement operation.
 type FileRule struct {
-	Source           string `yaml:"source"`
-	Path             string `yaml:"path"`
-	Mode             string `yaml:"mode"`
-	DownloadChecksum string `yaml:"download_checksum"`
-	OutputChecksum   string `yaml:"output_checksum"`
-	Encoding         string `yaml:"encoding"`
-	Extract          string `yaml:"extract"`
-	ExpandArchive    bool   `yaml:"expand_archive"`
+	Source               string            `yaml:"source"`
+	Path                 string            `yaml:"path"`
+	Mode                 string            `yaml:"mode"`
+	DownloadChecksum     string            `yaml:"download_checksum"`
+	DownloadChecksumFrom string            `yaml:"download_checksum_from"`
+	OutputChecksum       string            `yaml:"output_checksum"`
+	Encoding             string            `yaml:"encoding"`
+	Extract              string            `yaml:"extract"`
+	ExpandArchive        bool              `yaml:"expand_archive"`
+	ExpandDirectory      bool              `yaml:"expand_directory"`
+	Signature            *SignatureRule    `yaml:"signature"`
+	Render               bool              `yaml:"render"`
+	Merge                string            `yaml:"merge,omitempty"`
+	MergeArrays          string            `yaml:"merge_arrays,omitempty"`
+	Block                *BlockRule        `yaml:"block,omitempty"`
+	Vars                 map[string]string `yaml:"vars,omitempty"`
+	OnChange             []string          `yaml:"on_change,omitempty"`
+	RepositoryIndex      int               `yaml:"-"`
+	FileIndex            int               `yaml:"-"`
+	// Origin is the include the entry was merged from, empty for the root
+	// config, and OriginRepositoryIndex the index of its repository there.
+	Origin                string `yaml:"-"`
+	OriginRepositoryIndex int    `yaml:"-"`
+	// Remote reports that the entry comes from a remote config or include;
+	// rendering it gets an empty .env.
+	Remote bool `yaml:"-"`
+}
+
+// BlockRule is a normalized managed block; only the lines between Begin and End are synced.
+type BlockRule struct {
+	Begin string `yaml:"begin"`
+	End   string `yaml:"end"`
+}
+
+// SignatureRule is a normalized signature check; Source names the signature file source.
+type SignatureRule struct {
+	Type    string `yaml:"type"`
+	Source  string `yaml:"source"`
+	Key     string `yaml:"key"`
+	KeyFile string `yaml:"key_file"`
 }
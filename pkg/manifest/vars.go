@@ -0,0 +1,207 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// VarEnvPrefix prefixes environment variables that override vars
+// (VORBERE_VAR_<NAME>).
+const VarEnvPrefix = "VORBERE_VAR_"
+
+// VarDef declares one entry of vars. A scalar is shorthand for {value: ...}.
+type VarDef struct {
+	Value   *string `yaml:"value"`
+	Env     string  `yaml:"env"`
+	Sh      string  `yaml:"sh"`
+	Default *string `yaml:"default"`
+}
+
+// ResolveVarsOptions customizes ResolveVars.
+type ResolveVarsOptions struct {
+	// Overrides take precedence over definitions and VORBERE_VAR_* variables.
+	Overrides map[string]string
+	// Dir is the working directory of sh vars.
+	Dir string
+}
+
+// UnmarshalYAML accepts a scalar value or the mapping form.
+func (d *VarDef) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		value := node.Value
+		*d = VarDef{Value: &value}
+		return nil
+	}
+	type plain VarDef
+	var decoded plain
+	if err := node.Decode(&decoded); err != nil {
+		return err
+	}
+	*d = VarDef(decoded)
+	return nil
+}
+
+// StringVar returns a VarDef holding a fixed value.
+func StringVar(value string) VarDef {
+	return VarDef{Value: &value}
+}
+
+// ResolveVars fills cfg.Vars from cfg.VarDefs. VORBERE_VAR_<NAME>
+// environment variables override definitions, and opts.Overrides (from
+// --var) take precedence over both. Entries already present in cfg.Vars are
+// kept unless overridden. sh vars are not run here: they are evaluated on
+// first use during template expansion.
+func ResolveVars(cfg *TaskConfig, opts ResolveVarsOptions) error {
+	return resolveVars(cfg, opts, func(err error) error { return err })
+}
+
+// resolveVars passes the error of each invalid definition to report and
+// stops when report returns an error.
+func resolveVars(cfg *TaskConfig, opts ResolveVarsOptions, report func(error) error) error {
+	if cfg.Vars == nil {
+		cfg.Vars = map[string]string{}
+	}
+	cfg.shellVars = map[string]shellVar{}
+	names := make([]string, 0, len(cfg.VarDefs))
+	for name := range cfg.VarDefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := resolveVarDef(cfg, name, opts.Dir); err != nil {
+			if err := report(err); err != nil {
+				return err
+			}
+		}
+	}
+	for _, entry := range os.Environ() {
+		key, value, _ := strings.Cut(entry, "=")
+		name, ok := strings.CutPrefix(key, VarEnvPrefix)
+		if !ok || !varsKeyPattern.MatchString(name) {
+			continue
+		}
+		cfg.Vars[name] = value
+		delete(cfg.shellVars, name)
+	}
+	for name, value := range opts.Overrides {
+		if !varsKeyPattern.MatchString(name) {
+			return fmt.Errorf("var override %q: name must match [A-Za-z_][A-Za-z0-9_]*", name)
+		}
+		cfg.Vars[name] = value
+		delete(cfg.shellVars, name)
+	}
+	return nil
+}
+
+func resolveVarDef(cfg *TaskConfig, name, dir string) error {
+	if !varsKeyPattern.MatchString(name) {
+		return fmt.Errorf("vars.%s: key must match [A-Za-z_][A-Za-z0-9_]*", name)
+	}
+	def := cfg.VarDefs[name]
+	if def.Sh != "" {
+		if def.Value != nil {
+			return fmt.Errorf("vars.%s must not set both value and sh", name)
+		}
+		if value, ok := os.LookupEnv(def.Env); def.Env != "" && ok {
+			cfg.Vars[name] = value
+		} else {
+			cfg.shellVars[name] = shellVar{command: def.Sh, dir: dir}
+		}
+		return nil
+	}
+	value, err := def.resolve()
+	if err != nil {
+		return fmt.Errorf("vars.%s %w", name, err)
+	}
+	cfg.Vars[name] = value
+	return nil
+}
+
+func (d VarDef) resolve() (string, error) {
+	if d.Env != "" {
+		if value, ok := os.LookupEnv(d.Env); ok {
+			return value, nil
+		}
+	}
+	switch {
+	case d.Value != nil:
+		return *d.Value, nil
+	case d.Default != nil:
+		return *d.Default, nil
+	case d.Env != "":
+		return "", fmt.Errorf("env %s is not set and no default is given", d.Env)
+	default:
+		return "", fmt.Errorf("must set value, env, sh or default")
+	}
+}
+
+// shellVar is an sh var that has not been evaluated yet.
+type shellVar struct {
+	command string
+	dir     string
+}
+
+// varScope looks up vars for template expansion. sh vars are run on first
+// use and their output is cached in cfg.Vars, so each runs at most once.
+type varScope struct {
+	cfg *TaskConfig
+}
+
+func (s *varScope) lookup(name string) (string, bool, error) {
+	if value, ok := s.cfg.Vars[name]; ok {
+		return value, true, nil
+	}
+	pending, ok := s.cfg.shellVars[name]
+	if !ok {
+		return "", false, nil
+	}
+	value, err := pending.run()
+	if err != nil {
+		return "", false, fmt.Errorf("vars.%s %w", name, err)
+	}
+	delete(s.cfg.shellVars, name)
+	if s.cfg.Vars == nil {
+		s.cfg.Vars = map[string]string{}
+	}
+	s.cfg.Vars[name] = value
+	return value, true, nil
+}
+
+// all evaluates every pending sh var and returns cfg.Vars.
+func (s *varScope) all() (map[string]string, error) {
+	names := make([]string, 0, len(s.cfg.shellVars))
+	for name := range s.cfg.shellVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, _, err := s.lookup(name); err != nil {
+			return nil, err
+		}
+	}
+	return s.cfg.Vars, nil
+}
+
+// run executes the command with a non-login bash, so profile scripts do not
+// slow down config loading, and returns its stdout without trailing
+// newlines.
+func (v shellVar) run() (string, error) {
+	cmd := exec.Command("bash", "-c", v.command)
+	cmd.Dir = v.dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			return "", fmt.Errorf("sh %q failed: %w: %s", v.command, err, detail)
+		}
+		return "", fmt.Errorf("sh %q failed: %w", v.command, err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
@@ -0,0 +1,120 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestVarDefUnmarshalYAMLAcceptsScalarAndMapping(t *testing.T) {
+	var cfg TaskConfig
+	content := `
+vars:
+  PLAIN: "1.2.3"
+  NUMBER: 42
+  FROM_ENV:
+    env: TOOL_REF
+    default: main
+`
+	if err := yaml.Unmarshal([]byte(content), &cfg); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if got := cfg.VarDefs["PLAIN"]; got.Value == nil || *got.Value != "1.2.3" {
+		t.Fatalf("unexpected scalar var: %+v", got)
+	}
+	if got := cfg.VarDefs["NUMBER"]; got.Value == nil || *got.Value != "42" {
+		t.Fatalf("unexpected number var: %+v", got)
+	}
+	if got := cfg.VarDefs["FROM_ENV"]; got.Env != "TOOL_REF" || got.Default == nil || *got.Default != "main" || got.Value != nil {
+		t.Fatalf("unexpected mapping var: %+v", got)
+	}
+}
+
+func TestResolveVarsPrecedence(t *testing.T) {
+	t.Setenv("TOOL_REF", "v2")
+	t.Setenv("VORBERE_VAR_CHANNEL", "beta")
+	t.Setenv("VORBERE_VAR_OVERRIDDEN", "env")
+	fallback := "main"
+	cfg := &TaskConfig{VarDefs: map[string]VarDef{
+		"REF":        {Env: "TOOL_REF", Default: &fallback},
+		"BRANCH":     {Env: "VORBERE_TEST_UNSET", Default: &fallback},
+		"CHANNEL":    StringVar("stable"),
+		"OVERRIDDEN": StringVar("manifest"),
+	}}
+	if err := ResolveVars(cfg, ResolveVarsOptions{Overrides: map[string]string{"OVERRIDDEN": "flag", "EXTRA": "x"}}); err != nil {
+		t.Fatalf("ResolveVars returned error: %v", err)
+	}
+	want := map[string]string{"REF": "v2", "BRANCH": "main", "CHANNEL": "beta", "OVERRIDDEN": "flag", "EXTRA": "x"}
+	for name, value := range want {
+		if cfg.Vars[name] != value {
+			t.Fatalf("expected %s=%q, got %q (vars=%v)", name, value, cfg.Vars[name], cfg.Vars)
+		}
+	}
+}
+
+func TestResolveVarsRejectsMissingValue(t *testing.T) {
+	cfg := &TaskConfig{VarDefs: map[string]VarDef{"REF": {Env: "VORBERE_TEST_UNSET"}}}
+	err := ResolveVars(cfg, ResolveVarsOptions{})
+	if err == nil || err.Error() != "vars.REF env VORBERE_TEST_UNSET is not set and no default is given" {
+		t.Fatalf("expected missing env error, got %v", err)
+	}
+
+	cfg = &TaskConfig{VarDefs: map[string]VarDef{"BAD-KEY": StringVar("x")}}
+	if err := ResolveVars(cfg, ResolveVarsOptions{}); err == nil || !strings.Contains(err.Error(), "vars.BAD-KEY: key must match") {
+		t.Fatalf("expected invalid key error, got %v", err)
+	}
+
+	if err := ResolveVars(&TaskConfig{}, ResolveVarsOptions{Overrides: map[string]string{"1X": "y"}}); err == nil {
+		t.Fatalf("expected invalid override name error")
+	}
+}
+
+func TestShellVarsAreEvaluatedLazilyAndOnce(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &TaskConfig{
+		VarDefs: map[string]VarDef{
+			"COUNT":  {Sh: "echo x >> calls && wc -l < calls | tr -d ' '"},
+			"UNUSED": {Sh: "touch unused"},
+			"FAILS":  {Sh: "exit 3"},
+		},
+		Tasks: map[string]TaskDef{
+			"a": {Run: "echo ${{ .vars.COUNT }}"},
+			"b": {Run: "echo ${{ .vars.COUNT }}", Env: map[string]string{"N": "${{ .vars.COUNT }}"}},
+		},
+	}
+	if err := ResolveVars(cfg, ResolveVarsOptions{Dir: dir, Overrides: map[string]string{"FAILS": "ok"}}); err != nil {
+		t.Fatalf("ResolveVars returned error: %v", err)
+	}
+	if err := ExpandTaskConfigTemplates(cfg); err != nil {
+		t.Fatalf("ExpandTaskConfigTemplates returned error: %v", err)
+	}
+	if cfg.Tasks["a"].Run != "echo 1" || cfg.Tasks["b"].Run != "echo 1" || cfg.Tasks["b"].Env["N"] != "1" {
+		t.Fatalf("expected sh var to run once, got %q %q %v", cfg.Tasks["a"].Run, cfg.Tasks["b"].Run, cfg.Tasks["b"].Env)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "unused")); !os.IsNotExist(err) {
+		t.Fatalf("expected unreferenced sh var not to run, stat err=%v", err)
+	}
+}
+
+func TestShellVarFailureNamesVar(t *testing.T) {
+	cfg := &TaskConfig{
+		VarDefs: map[string]VarDef{"VERSION": {Sh: "echo broken >&2; exit 3"}},
+		Tasks:   map[string]TaskDef{"build": {Run: "echo ${{ .vars.VERSION }}"}},
+	}
+	if err := ResolveVars(cfg, ResolveVarsOptions{Dir: t.TempDir()}); err != nil {
+		t.Fatalf("ResolveVars returned error: %v", err)
+	}
+	err := ExpandTaskConfigTemplates(cfg)
+	if err == nil || !strings.HasPrefix(err.Error(), `vars.VERSION sh "echo broken >&2; exit 3" failed: exit status 3: `) || !strings.HasSuffix(err.Error(), "broken") {
+		t.Fatalf("expected error naming the var, got %v", err)
+	}
+
+	value := "1"
+	cfg = &TaskConfig{VarDefs: map[string]VarDef{"VERSION": {Sh: "cat VERSION", Value: &value}}}
+	if err := ResolveVars(cfg, ResolveVarsOptions{}); err == nil || err.Error() != "vars.VERSION must not set both value and sh" {
+		t.Fatalf("expected value and sh conflict, got %v", err)
+	}
+}